@@ -0,0 +1,178 @@
+// Package accounts derives test accounts from a mnemonic, so that parallel
+// tests can each issue txs from accounts of their own instead of contending
+// for the UTXOs and the nonce of the genesis key. The accounts are derived
+// along the Avalanche BIP-44 path m/44'/9000'/0'/0/i, so that the same
+// mnemonic always yields the same keys, which control the same addresses on
+// the X, P and C chains.
+package accounts
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	avalancheConstants "github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// DefaultMnemonic is the mnemonic accounts are derived from when none is
+	// set. It is public, so its accounts must only be funded on local
+	// networks.
+	DefaultMnemonic = "test test test test test test test test test test test junk"
+	// DefaultBalance is the balance of each account on each chain, in nAVAX
+	DefaultBalance = 10000 * units.Avax
+
+	// Index of hardened children in a BIP-32 path
+	hardened = 1 << 31
+)
+
+// Path of the accounts, followed by their index: m/44'/9000'/0'/0
+var path = []uint32{44 + hardened, 9000 + hardened, hardened, 0}
+
+// Account is a key controlling an address on each chain of a network
+type Account struct {
+	Index int `json:"index"`
+	// Private key in the Avalanche format (PrivateKey-...) and in the
+	// Ethereum format (hex), left out of listings that do not disclose keys
+	PrivateKey    string `json:"privateKey,omitempty"`
+	EthPrivateKey string `json:"ethPrivateKey,omitempty"`
+	// Addresses on the X and P chains, on the C-chain for atomic txs, and on
+	// the C-chain and Subnet-EVM blockchains
+	XAddress   string `json:"xAddress"`
+	PAddress   string `json:"pAddress"`
+	CAddress   string `json:"cAddress"`
+	EthAddress string `json:"ethAddress"`
+}
+
+// Derive returns the first [count] accounts of [mnemonic], with addresses
+// formatted for the network whose addresses are prefixed by [hrp] (e.g.
+// local)
+func Derive(mnemonic string, hrp string, count int) ([]Account, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("invalid number of accounts %d", count)
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(mnemonic), " "), "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	key, chainCode, err := masterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		if key, chainCode, err = child(key, chainCode, index); err != nil {
+			return nil, err
+		}
+	}
+
+	accounts := make([]Account, count)
+	for i := range accounts {
+		b, _, err := child(key, chainCode, uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		if accounts[i], err = account(i, b, hrp); err != nil {
+			return nil, err
+		}
+	}
+	return accounts, nil
+}
+
+// account returns the account at [index] controlled by the private key [b]
+func account(index int, b []byte, hrp string) (Account, error) {
+	key, err := crypto.ToECDSA(b)
+	if err != nil {
+		return Account{}, err
+	}
+	encoded, err := formatting.EncodeWithChecksum(formatting.CB58, b)
+	if err != nil {
+		return Account{}, err
+	}
+	a := Account{
+		Index:         index,
+		PrivateKey:    avalancheConstants.SecretKeyPrefix + encoded,
+		EthPrivateKey: hexutil.Encode(b),
+		EthAddress:    crypto.PubkeyToAddress(key.PublicKey).Hex(),
+	}
+	address := hashing.PubkeyBytesToAddress(crypto.CompressPubkey(&key.PublicKey))
+	for _, f := range []struct {
+		chain string
+		addr  *string
+	}{{"X", &a.XAddress}, {"P", &a.PAddress}, {"C", &a.CAddress}} {
+		if *f.addr, err = formatting.FormatAddress(f.chain, hrp, address); err != nil {
+			return Account{}, err
+		}
+	}
+	return a, nil
+}
+
+// ECDSAKey returns [privateKey], in the Avalanche format, as a key signing
+// txs on EVM chains
+func ECDSAKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	b, err := formatting.Decode(formatting.CB58, strings.TrimPrefix(privateKey, avalancheConstants.SecretKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %w", err)
+	}
+	return crypto.ToECDSA(b)
+}
+
+// masterKey returns the BIP-32 master key and chain code of [seed]
+func masterKey(seed []byte) ([]byte, []byte, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	_, _ = mac.Write(seed)
+	sum := mac.Sum(nil)
+	if !validKey(sum[:32]) {
+		return nil, nil, errors.New("invalid master key")
+	}
+	return sum[:32], sum[32:], nil
+}
+
+// child returns the BIP-32 private key and chain code of the child [index]
+// of the private key [key] with [chainCode]
+func child(key []byte, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	var data []byte
+	if index >= hardened {
+		data = append([]byte{0}, key...)
+	} else {
+		parent, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = crypto.CompressPubkey(&parent.PublicKey)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, chainCode)
+	_, _ = mac.Write(data)
+	sum := mac.Sum(nil)
+	if !validKey(sum[:32]) {
+		return nil, nil, fmt.Errorf("invalid child key %d", index)
+	}
+	n := crypto.S256().Params().N
+	k := new(big.Int).Add(new(big.Int).SetBytes(sum[:32]), new(big.Int).SetBytes(key))
+	k.Mod(k, n)
+	if k.Sign() == 0 {
+		return nil, nil, fmt.Errorf("invalid child key %d", index)
+	}
+	childKey := make([]byte, 32)
+	k.FillBytes(childKey)
+	return childKey, sum[32:], nil
+}
+
+// validKey returns true if [b] is a valid secp256k1 private key
+func validKey(b []byte) bool {
+	k := new(big.Int).SetBytes(b)
+	return k.Sign() > 0 && k.Cmp(crypto.S256().Params().N) < 0
+}
@@ -0,0 +1,203 @@
+package accounts
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test vectors 1, 2 and 3 of BIP-32
+// (https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vectors):
+// the private key and chain code of each child along a path from the master
+// key of a seed
+func TestChild(t *testing.T) {
+	type step struct {
+		index     uint32
+		key       string
+		chainCode string
+	}
+	tests := []struct {
+		name  string
+		seed  string
+		steps []step
+	}{
+		{
+			name: "vector 1",
+			seed: "000102030405060708090a0b0c0d0e0f",
+			steps: []step{
+				{0, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508"},
+				{hardened, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141"},
+				{1, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19"},
+				{2 + hardened, "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca", "04466b9cc8e161e966409ca52986c584f07e9dc81f735db683c3ff6ec7b1503f"},
+				{2, "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4", "cfb71883f01676f587d023cc53a35bc7f88f724b1f8c2892ac1275ac822a3edd"},
+				{1000000000, "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8", "c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e"},
+			},
+		},
+		{
+			name: "vector 2",
+			seed: "fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542",
+			steps: []step{
+				{0, "4b03d6fc340455b363f51020ad3ecca4f0850280cf436c70c727923f6db46c3e", "60499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd9689"},
+				{0, "abe74a98f6c7eabee0428f53798f0ab8aa1bd37873999041703c742f15ac7e1e", "f0909affaa7ee7abe5dd4e100598d4dc53cd709d5a5c2cac40e7412f232f7c9c"},
+			},
+		},
+		{
+			// Keys with leading zeros
+			name: "vector 3",
+			seed: "4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be",
+			steps: []step{
+				{0, "00ddb80b067e0d4993197fe10f2657a844a384589847602d56f0c629c81aae32", "01d28a3e53cffa419ec122c968b3259e16b65076495494d97cae10bbfec3c36f"},
+				{hardened, "491f7a2eebc7b57028e0d3faa0acda02e75c33b03c48fb288c41e2ea44e1daef", "e5fea12a97b927fc9dc3d2cb0d1ea1cf50aa5a1fdc1f933e8906bb38df3377bd"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seed, err := hex.DecodeString(test.seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The first step is the master key
+			key, chainCode, err := masterKey(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range test.steps {
+				if i > 0 {
+					if key, chainCode, err = child(key, chainCode, s.index); err != nil {
+						t.Fatalf("step %d: %v", i, err)
+					}
+				}
+				if got := hex.EncodeToString(key); got != s.key {
+					t.Fatalf("step %d: expected key %s but got %s", i, s.key, got)
+				}
+				if got := hex.EncodeToString(chainCode); got != s.chainCode {
+					t.Fatalf("step %d: expected chain code %s but got %s", i, s.chainCode, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+	// Ethereum path m/44'/60'/0'/0, to check the derivation against the
+	// accounts of well-known mnemonics
+	ethPath := []uint32{44 + hardened, 60 + hardened, hardened, 0}
+	tests := []struct {
+		name     string
+		mnemonic string
+		path     []uint32
+		expected []Account
+	}{
+		{
+			name:     "avalanche path",
+			mnemonic: DefaultMnemonic,
+			path:     path,
+			expected: []Account{
+				{
+					Index:         0,
+					PrivateKey:    "PrivateKey-Fapb8hTUMABpZc9zWurmPR7why34LQNshss5RZHgCAgiY5n83",
+					EthPrivateKey: "0x211cdc80c23ccc8eceab5d6903312391e656366a7a553e2c501b06add1729816",
+					XAddress:      "X-local1yljhuvjkmtu0y5ls6kf4exsdd8gea9mp7pshft",
+					PAddress:      "P-local1yljhuvjkmtu0y5ls6kf4exsdd8gea9mp7pshft",
+					CAddress:      "C-local1yljhuvjkmtu0y5ls6kf4exsdd8gea9mp7pshft",
+					EthAddress:    "0x5a299B0010BAc9c0339B6EF600B1f2943131b1e7",
+				},
+				{
+					Index:         1,
+					EthPrivateKey: "0x9f8799874aeb19dc930f9e5d82c71ebe361bdeea343907e87cba737589dd17eb",
+					XAddress:      "X-local18wvaf02nxrfpxhz5fwrj5yjydhhrlef6trzyrq",
+					EthAddress:    "0xFf9bc69A6554a511E92236C019a58e5aB5f0e486",
+				},
+				{
+					Index:         2,
+					EthPrivateKey: "0x9ac048b0ccc9a3d90fdee74f52b5a6e4cf28e70696f3d6e45a5f8cf34a53fd78",
+					XAddress:      "X-local1kk4tuwm7ley05828x75p8pvdcw5j3wlvqh7uqp",
+					EthAddress:    "0x9Fa1E0E5A524C7120c9e95538D2682d30BB01d98",
+				},
+			},
+		},
+		{
+			name:     "hardhat accounts",
+			mnemonic: DefaultMnemonic,
+			path:     ethPath,
+			expected: []Account{
+				{Index: 0, EthAddress: "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"},
+				{Index: 1, EthAddress: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"},
+			},
+		},
+		{
+			name:     "mnemonic with extra whitespace",
+			mnemonic: "  abandon abandon abandon abandon abandon abandon\nabandon abandon abandon abandon abandon about ",
+			path:     ethPath,
+			expected: []Account{
+				{Index: 0, EthAddress: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(p []uint32) { path = p }(path)
+			path = test.path
+
+			accts, err := Derive(test.mnemonic, "local", len(test.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(accts) != len(test.expected) {
+				t.Fatalf("expected %d accounts but got %d", len(test.expected), len(accts))
+			}
+			for i, expected := range test.expected {
+				a := accts[i]
+				// Only the fields set in the expected account are checked
+				for _, f := range []struct {
+					name, expected, actual string
+				}{
+					{"private key", expected.PrivateKey, a.PrivateKey},
+					{"eth private key", expected.EthPrivateKey, a.EthPrivateKey},
+					{"X address", expected.XAddress, a.XAddress},
+					{"P address", expected.PAddress, a.PAddress},
+					{"C address", expected.CAddress, a.CAddress},
+					{"eth address", expected.EthAddress, a.EthAddress},
+				} {
+					if len(f.expected) > 0 && f.actual != f.expected {
+						t.Errorf("account %d: expected %s %s but got %s", i, f.name, f.expected, f.actual)
+					}
+				}
+				if a.Index != expected.Index {
+					t.Errorf("account %d: expected index %d but got %d", i, expected.Index, a.Index)
+				}
+				// The Avalanche private key is the Ethereum one
+				key, err := ECDSAKey(a.PrivateKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := "0x" + hex.EncodeToString(key.D.FillBytes(make([]byte, 32))); got != a.EthPrivateKey {
+					t.Errorf("account %d: private keys %s and %s differ", i, a.PrivateKey, a.EthPrivateKey)
+				}
+			}
+		})
+	}
+}
+
+func TestDeriveInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		count    int
+		err      string
+	}{
+		{"negative count", DefaultMnemonic, -1, "invalid number of accounts"},
+		{"unknown word", "test test test test test test test test test test test jnuk", 1, "invalid mnemonic"},
+		{"bad checksum", "test test test test test test test test test test test test", 1, "invalid mnemonic"},
+		{"empty", "", 1, "invalid mnemonic"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Derive(test.mnemonic, "local", test.count)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
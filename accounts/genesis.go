@@ -0,0 +1,78 @@
+package accounts
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Wei per nAVAX, as AVAX has 18 decimals on EVM chains but 9 on the X and P
+// chains
+var weiPerNAVAX = big.NewInt(1e9)
+
+// allocation is the genesis allocation of an account of an EVM chain
+type allocation struct {
+	Balance string `json:"balance"`
+}
+
+// Allocate returns [genesis] with [balance] nAVAX allocated to each of
+// [accounts] if it is the genesis of an EVM chain (i.e. a JSON object with a
+// config and an alloc), and [genesis] as is otherwise. Accounts already
+// allocated funds by [genesis] are left untouched.
+func Allocate(genesis []byte, accounts []Account, balance uint64) []byte {
+	if len(accounts) == 0 {
+		return genesis
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(genesis, &fields); err != nil {
+		return genesis
+	}
+	raw, ok := fields["alloc"]
+	if _, isEVM := fields["config"]; !ok || !isEVM {
+		return genesis
+	}
+	alloc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &alloc); err != nil {
+		return genesis
+	}
+
+	allocated := make(map[string]bool, len(alloc))
+	for address := range alloc {
+		allocated[normalize(address)] = true
+	}
+	wei := hexutil.EncodeBig(WeiBalance(balance))
+	for _, a := range accounts {
+		address := normalize(a.EthAddress)
+		if allocated[address] {
+			continue
+		}
+		b, err := json.Marshal(allocation{Balance: wei})
+		if err != nil {
+			return genesis
+		}
+		alloc[address] = b
+	}
+	b, err := json.Marshal(alloc)
+	if err != nil {
+		return genesis
+	}
+	fields["alloc"] = b
+	withAlloc, err := json.Marshal(fields)
+	if err != nil {
+		return genesis
+	}
+	return withAlloc
+}
+
+// WeiBalance returns [balance] nAVAX in wei
+func WeiBalance(balance uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(balance), weiPerNAVAX)
+}
+
+// normalize returns the hex [address] lowercase and without its 0x prefix,
+// as in the alloc of genesis files
+func normalize(address string) string {
+	return strings.TrimPrefix(strings.ToLower(address), "0x")
+}
@@ -0,0 +1,523 @@
+// Package chaos disrupts the nodes of a running network at random, e.g. to
+// soak test a custom VM: it kills, restarts, pauses and partitions nodes while
+// keeping a quorum of them healthy.
+//
+// The actions are drawn from a schedule generated from a seed before they are
+// taken, so a run can be replayed exactly by reusing its seed on a network
+// with the same nodes, even if some actions take longer than others. Actions
+// that would leave fewer nodes than the quorum actually ready, e.g. as a node
+// exited on its own, are skipped when their time comes.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/network"
+
+	"github.com/fatih/color"
+)
+
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultDowntime = time.Minute
+
+	// Name of the file in the data dir of the network recording the actions
+	// taken
+	logFile = "chaos.log"
+)
+
+// Action disrupts nodes
+type Action string
+
+const (
+	// Kill nodes as if they crashed and start them again once their downtime
+	// is over
+	Kill Action = "kill"
+	// Restart nodes gracefully
+	Restart Action = "restart"
+	// Freeze nodes as if they hung and resume them once their downtime is
+	// over
+	Pause Action = "pause"
+	// Cut nodes off from the rest of the network and heal the links cut once
+	// their downtime is over
+	Partition Action = "partition"
+)
+
+// Actions are all the actions the scheduler can take
+var Actions = []Action{Kill, Restart, Pause, Partition}
+
+// ParseActions parses comma-separated action names
+func ParseActions(s string) ([]Action, error) {
+	var actions []Action
+	for _, name := range strings.Split(s, ",") {
+		action := Action(strings.TrimSpace(name))
+		if !action.valid() {
+			return nil, fmt.Errorf("unknown chaos action %q (expecting one of %v)", name, Actions)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+func (a Action) valid() bool {
+	for _, action := range Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Config describes the disruptions of a chaos run
+type Config struct {
+	// Seed of the schedule. Runs with the same seed and config on networks
+	// with the same nodes take the same actions in the same order at the
+	// same times.
+	Seed int64
+	// Mean time between two actions, which is drawn between half and one and
+	// a half times Interval. Defaults to [DefaultInterval].
+	Interval time.Duration
+	// Maximum time nodes stay killed, paused or partitioned, which is drawn
+	// between a quarter of Downtime and Downtime. Defaults to
+	// [DefaultDowntime].
+	Downtime time.Duration
+	// Minimum number of nodes kept running, responsive and connected to each
+	// other. Defaults to a majority of the nodes.
+	Quorum int
+	// Actions to draw from. Defaults to all the actions the network supports:
+	// killing and pausing nodes requires them to run an avalanchego binary,
+	// as does restarting them since nodes running in the ava-sim process are
+	// restarted together, and partitions require fault injection.
+	Actions []Action
+	// Time after which the run stops, once all disrupted nodes recovered. The
+	// run lasts until its context is done if 0.
+	Duration time.Duration
+}
+
+// Verify returns an error if the config is invalid
+func (c Config) Verify() error {
+	switch {
+	case c.Interval != 0 && c.Interval < time.Second:
+		return fmt.Errorf("invalid chaos interval %s (expecting at least 1s)", c.Interval)
+	case c.Downtime != 0 && c.Downtime < time.Second:
+		return fmt.Errorf("invalid chaos downtime %s (expecting at least 1s)", c.Downtime)
+	case c.Quorum < 0:
+		return fmt.Errorf("invalid chaos quorum %d", c.Quorum)
+	case c.Duration < 0:
+		return fmt.Errorf("invalid chaos duration %s", c.Duration)
+	}
+	for _, action := range c.Actions {
+		if !action.valid() {
+			return fmt.Errorf("unknown chaos action %q", action)
+		}
+	}
+	return nil
+}
+
+// Event is an action of the schedule
+type Event struct {
+	// Time of the action since the start of the run
+	At     time.Duration
+	Action Action
+	Nodes  []string
+	// Time until the nodes recover, which is 0 for restarts
+	Downtime time.Duration
+}
+
+func (e Event) String() string {
+	desc := fmt.Sprintf("%s %s", e.Action, strings.Join(e.Nodes, ","))
+	if e.Downtime > 0 {
+		desc += fmt.Sprintf(" for %s", e.Downtime)
+	}
+	return desc
+}
+
+// Target is a node the scheduler can disrupt
+type Target struct {
+	Name string
+	// Whether the node runs in its own avalanchego process, in which case it
+	// can be killed, paused and restarted on its own
+	Process bool
+}
+
+// Scheduler generates the events of a chaos run from its seed
+type Scheduler struct {
+	config  Config
+	rng     *rand.Rand
+	targets []Target
+	actions []Action
+	// Time of the last event and the times disrupted nodes recover at
+	now        time.Duration
+	recoveries map[string]time.Duration
+	// Time the ongoing partition heals at
+	partitionEnd time.Duration
+}
+
+// NewScheduler returns the scheduler of the events disrupting [targets]
+// according to [config]. [faults] is true if partitions can be injected.
+func NewScheduler(config Config, targets []Target, faults bool) (*Scheduler, error) {
+	if err := config.Verify(); err != nil {
+		return nil, err
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Downtime == 0 {
+		config.Downtime = DefaultDowntime
+	}
+	if config.Quorum == 0 {
+		config.Quorum = len(targets)/2 + 1
+	}
+	if config.Quorum >= len(targets) {
+		return nil, fmt.Errorf("chaos quorum %d leaves none of the %d nodes to disrupt", config.Quorum, len(targets))
+	}
+	targets = append([]Target(nil), targets...)
+	sort.Slice(targets, func(i, j int) bool {
+		return nodeNum(targets[i].Name) < nodeNum(targets[j].Name)
+	})
+	processes := false
+	for _, target := range targets {
+		processes = processes || target.Process
+	}
+
+	supported := func(action Action) error {
+		switch {
+		case action == Partition && !faults:
+			return errors.New("partitions require fault injection (see --faults)")
+		case action != Partition && !processes:
+			return fmt.Errorf("%s requires nodes running an avalanchego binary (see --avalanchego-path)", action)
+		}
+		return nil
+	}
+	var actions []Action
+	if len(config.Actions) == 0 {
+		for _, action := range Actions {
+			if supported(action) == nil {
+				actions = append(actions, action)
+			}
+		}
+		if len(actions) == 0 {
+			return nil, errors.New("no chaos action is supported: run nodes with --avalanchego-path or start the network with --faults")
+		}
+	}
+	for _, action := range config.Actions {
+		if err := supported(action); err != nil {
+			return nil, fmt.Errorf("cannot %s nodes: %w", action, err)
+		}
+		actions = append(actions, action)
+	}
+	return &Scheduler{
+		config:     config,
+		rng:        rand.New(rand.NewSource(config.Seed)), // #nosec G404
+		targets:    targets,
+		actions:    actions,
+		recoveries: make(map[string]time.Duration),
+	}, nil
+}
+
+// nodeNum returns the number of the node named [name] (e.g. 2 for node2), so
+// that node10 comes after node9
+func nodeNum(name string) int {
+	var num int
+	_, _ = fmt.Sscanf(name, "node%d", &num)
+	return num
+}
+
+// Config returns the config of the scheduler with its defaults applied
+func (s *Scheduler) Config() Config {
+	return s.config
+}
+
+// Next returns the next event of the schedule, or false if the schedule ends
+// before it
+func (s *Scheduler) Next() (Event, bool) {
+	for {
+		s.now += (s.config.Interval/2 + time.Duration(s.rng.Int63n(int64(s.config.Interval)+1))).Round(time.Millisecond)
+		if s.config.Duration > 0 && s.now > s.config.Duration {
+			return Event{}, false
+		}
+		// Draws are made even if no action is possible, so that the
+		// schedule only depends on the seed and the config
+		action := s.actions[s.rng.Intn(len(s.actions))]
+		pick := s.rng.Int63()
+		downtime := (s.config.Downtime/4 + time.Duration(s.rng.Int63n(int64(s.config.Downtime-s.config.Downtime/4)+1))).Round(time.Millisecond)
+
+		var healthy []Target
+		for _, target := range s.targets {
+			if s.recoveries[target.Name] <= s.now {
+				healthy = append(healthy, target)
+			}
+		}
+		spare := len(healthy) - s.config.Quorum
+		if spare < 1 {
+			continue
+		}
+		e := Event{At: s.now, Action: action, Downtime: downtime}
+		if action == Partition {
+			if s.partitionEnd > s.now {
+				continue
+			}
+			// Cut off up to [spare] healthy nodes from the others
+			size := 1 + int(pick%int64(spare))
+			perm := rand.New(rand.NewSource(pick)).Perm(len(healthy)) // #nosec G404
+			for _, i := range perm[:size] {
+				e.Nodes = append(e.Nodes, healthy[i].Name)
+			}
+			sort.Slice(e.Nodes, func(i, j int) bool { return nodeNum(e.Nodes[i]) < nodeNum(e.Nodes[j]) })
+			s.partitionEnd = s.now + downtime
+		} else {
+			var candidates []Target
+			for _, target := range healthy {
+				if target.Process {
+					candidates = append(candidates, target)
+				}
+			}
+			if len(candidates) == 0 {
+				continue
+			}
+			e.Nodes = []string{candidates[pick%int64(len(candidates))].Name}
+			if action == Restart {
+				e.Downtime = 0
+			}
+		}
+		for _, name := range e.Nodes {
+			s.recoveries[name] = s.now + e.Downtime
+		}
+		return e, true
+	}
+}
+
+// recovery is the end of the downtime of the nodes disrupted by [event].
+// [links] are the pairs of nodes whose link was cut by a partition.
+type recovery struct {
+	at    time.Duration
+	event Event
+	links [][2]string
+}
+
+// Run disrupts the nodes of [n] that are running according to [config] until
+// [ctx] is done or the run lasted [Config.Duration]. Every action is printed
+// and recorded in chaos.log in the data dir of the network. Actions are
+// skipped if the nodes they leave undisrupted are not enough to keep a quorum
+// of them ready (see [checkQuorum]), and actions that fail, e.g. because a
+// node exited on its own, are reported and skipped. Nodes paused or
+// partitioned when [ctx] is done are recovered, while killed nodes stay down.
+func Run(ctx context.Context, n *network.Network, config Config) error {
+	var targets []Target
+	for _, node := range n.Nodes() {
+		if node.Running && !node.Removed {
+			targets = append(targets, Target{Name: node.Name, Process: len(node.AvalancheGoPath) > 0})
+		}
+	}
+	s, err := NewScheduler(config, targets, n.FaultInjection())
+	if err != nil {
+		return err
+	}
+	config = s.Config()
+
+	log, err := os.OpenFile(filepath.Join(n.DataDir(), logFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, constants.FilePerms)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	start := time.Now()
+	record := func(at time.Duration, format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		if late := time.Since(start) - at; late > time.Second {
+			msg += fmt.Sprintf(" (late by %s)", late.Round(time.Second))
+		}
+		color.Magenta("chaos [+%s] %s", at, msg)
+		fmt.Fprintf(log, "%s +%s %s\n", time.Now().UTC().Format(time.RFC3339), at, msg)
+	}
+	actions := make([]string, len(s.actions))
+	for i, action := range s.actions {
+		actions[i] = string(action)
+	}
+	record(0, "started with seed %d: interval=%s downtime=%s quorum=%d actions=%s duration=%s",
+		config.Seed, config.Interval, config.Downtime, config.Quorum, strings.Join(actions, ","), config.Duration)
+
+	var (
+		recoveries []recovery
+		next, ok   = s.Next()
+	)
+	for ok || len(recoveries) > 0 {
+		// Recoveries due at the same time as the next event come first
+		var (
+			at      = next.At
+			recover = len(recoveries) > 0 && (!ok || recoveries[0].at <= next.At)
+		)
+		if recover {
+			at = recoveries[0].at
+		}
+		timer := time.NewTimer(time.Until(start.Add(at)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			for _, r := range recoveries {
+				if r.event.Action != Kill {
+					_ = recoverFrom(context.Background(), n, r)
+				}
+			}
+			return nil
+		case <-timer.C:
+		}
+
+		if recover {
+			r := recoveries[0]
+			recoveries = recoveries[1:]
+			e := r.event
+			verb := recoveryOf(e.Action)
+			record(at, "%s %s", verb, strings.Join(e.Nodes, ","))
+			if err := recoverFrom(ctx, n, r); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				color.Red("chaos: could not %s %s: %v", verb, strings.Join(e.Nodes, ","), err)
+			}
+			continue
+		}
+
+		e := next
+		next, ok = s.Next()
+		if err := checkQuorum(n, e, config.Quorum); err != nil {
+			record(at, "skipped %s: %v", e, err)
+			continue
+		}
+		record(at, "%s", e)
+		links, err := disrupt(ctx, n, e)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			color.Red("chaos: could not %s %s: %v", e.Action, strings.Join(e.Nodes, ","), err)
+			continue
+		}
+		if e.Downtime > 0 {
+			recoveries = append(recoveries, recovery{at: e.At + e.Downtime, event: e, links: links})
+			sort.SliceStable(recoveries, func(i, j int) bool { return recoveries[i].at < recoveries[j].at })
+		}
+	}
+	record(time.Since(start).Round(time.Second), "stopped")
+	return nil
+}
+
+// checkQuorum returns an error unless at least [quorum] of the running
+// nodes of [n] that [e] leaves undisrupted are ready: reachable, bootstrapped
+// and connected to enough peers to form the quorum. The schedule only tracks
+// the nodes it disrupted itself, while nodes may also have exited on their
+// own, be disrupted by hand or still be recovering.
+func checkQuorum(n *network.Network, e Event, quorum int) error {
+	disrupted := make(map[string]bool, len(e.Nodes))
+	for _, name := range e.Nodes {
+		disrupted[name] = true
+	}
+	ready := 0
+	var reasons []string
+	for _, node := range n.Nodes() {
+		if !node.Running || disrupted[node.Name] {
+			continue
+		}
+		r := health.Check(health.Node{Name: node.Name, ID: node.ID, URL: node.URL}, health.PrimaryNetwork(quorum-1))
+		if r.Ready {
+			ready++
+		} else {
+			reasons = append(reasons, fmt.Sprintf("%s %s", node.Name, r.Reason))
+		}
+	}
+	if ready < quorum {
+		msg := fmt.Sprintf("only %d other nodes are ready (quorum %d)", ready, quorum)
+		if len(reasons) > 0 {
+			msg += ": " + strings.Join(reasons, "; ")
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// disrupt takes the action of [e], returning the pairs of nodes whose link
+// was cut if it partitions the network. Links that were cut already are left
+// out, so that they stay cut once the partition heals.
+func disrupt(ctx context.Context, n *network.Network, e Event) ([][2]string, error) {
+	switch e.Action {
+	case Kill:
+		return nil, n.KillNode(ctx, e.Nodes[0])
+	case Restart:
+		return nil, n.RestartNode(ctx, e.Nodes[0])
+	case Pause:
+		return nil, n.PauseNode(e.Nodes[0])
+	case Partition:
+		faults, err := n.Faults()
+		if err != nil {
+			return nil, err
+		}
+		cut := make(map[[2]string]bool)
+		for _, f := range faults {
+			if f.Faults.Partitioned {
+				cut[f.Nodes] = true
+				cut[[2]string{f.Nodes[1], f.Nodes[0]}] = true
+			}
+		}
+		cutOff := make(map[string]bool)
+		for _, name := range e.Nodes {
+			cutOff[name] = true
+		}
+		var (
+			others []string
+			links  [][2]string
+		)
+		for _, node := range n.Nodes() {
+			if cutOff[node.Name] {
+				continue
+			}
+			others = append(others, node.Name)
+			for _, name := range e.Nodes {
+				if link := [2]string{name, node.Name}; !cut[link] {
+					links = append(links, link)
+				}
+			}
+		}
+		return links, n.Partition(e.Nodes, others)
+	}
+	return nil, fmt.Errorf("unknown chaos action %q", e.Action)
+}
+
+// recoveryOf returns the name of the recovery from [action]
+func recoveryOf(action Action) string {
+	switch action {
+	case Kill:
+		return "start"
+	case Pause:
+		return "resume"
+	case Partition:
+		return "heal"
+	}
+	return string(action)
+}
+
+// recoverFrom ends the downtime of the nodes disrupted by the event of [r].
+// Partitions only heal the links they cut, keeping the other faults.
+func recoverFrom(ctx context.Context, n *network.Network, r recovery) error {
+	e := r.event
+	switch e.Action {
+	case Kill:
+		return n.StartNode(ctx, e.Nodes[0])
+	case Pause:
+		return n.ResumeNode(e.Nodes[0])
+	case Partition:
+		if len(r.links) == 0 {
+			return nil
+		}
+		return n.HealPartition(r.links...)
+	}
+	return fmt.Errorf("%s has no recovery", e.Action)
+}
@@ -0,0 +1,145 @@
+package chaos
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// targets returns [num] nodes, running an avalanchego binary if [process]
+func targets(num int, process bool) []Target {
+	res := make([]Target, num)
+	for i := range res {
+		res[i] = Target{Name: fmt.Sprintf("node%d", i+1), Process: process}
+	}
+	return res
+}
+
+// schedule returns the events of the scheduler of [config] on [targets],
+// up to [max] events
+func schedule(t *testing.T, config Config, targets []Target, faults bool, max int) []Event {
+	s, err := NewScheduler(config, targets, faults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	for len(events) < max {
+		e, ok := s.Next()
+		if !ok {
+			break
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestSchedulerDeterministic(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		targets []Target
+		faults  bool
+	}{
+		{"all actions", Config{Seed: 1}, targets(5, true), true},
+		{"partitions only", Config{Seed: 2, Actions: []Action{Partition}}, targets(5, false), true},
+		{"ten nodes", Config{Seed: 3, Interval: 5 * time.Second, Downtime: 20 * time.Second, Quorum: 6}, targets(10, true), true},
+		{"without faults", Config{Seed: 4, Actions: []Action{Kill, Pause}}, targets(3, true), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := schedule(t, test.config, test.targets, test.faults, 100)
+			if len(events) != 100 {
+				t.Fatalf("expected 100 events but got %d", len(events))
+			}
+			// The order the targets are given in does not matter
+			reversed := make([]Target, len(test.targets))
+			for i, target := range test.targets {
+				reversed[len(reversed)-1-i] = target
+			}
+			if again := schedule(t, test.config, reversed, test.faults, 100); !reflect.DeepEqual(events, again) {
+				t.Fatalf("expected the same events for seed %d", test.config.Seed)
+			}
+
+			config := test.config
+			config.Seed++
+			if other := schedule(t, config, test.targets, test.faults, 100); reflect.DeepEqual(events, other) {
+				t.Fatalf("expected different events for seeds %d and %d", test.config.Seed, config.Seed)
+			}
+		})
+	}
+}
+
+func TestSchedulerQuorum(t *testing.T) {
+	config := Config{Seed: 5, Interval: time.Second, Downtime: time.Minute, Quorum: 3}
+	nodes := targets(5, true)
+	events := schedule(t, config, nodes, true, 500)
+
+	// Time each node recovers at
+	recoveries := make(map[string]time.Duration)
+	var last, partitionEnd time.Duration
+	for _, e := range events {
+		if e.At < last {
+			t.Fatalf("event %s at %s comes before the previous one at %s", e, e.At, last)
+		}
+		last = e.At
+		if e.Action == Partition {
+			if partitionEnd > e.At {
+				t.Fatalf("partition %s at %s overlaps a partition healing at %s", e, e.At, partitionEnd)
+			}
+			partitionEnd = e.At + e.Downtime
+		}
+		for _, name := range e.Nodes {
+			if recoveries[name] > e.At {
+				t.Fatalf("event %s at %s disrupts %s before it recovered", e, e.At, name)
+			}
+			recoveries[name] = e.At + e.Downtime
+		}
+		healthy := 0
+		for _, node := range nodes {
+			if recoveries[node.Name] <= e.At {
+				healthy++
+			}
+		}
+		if healthy < config.Quorum {
+			t.Fatalf("event %s at %s leaves %d healthy nodes (quorum %d)", e, e.At, healthy, config.Quorum)
+		}
+	}
+}
+
+func TestSchedulerDuration(t *testing.T) {
+	config := Config{Seed: 6, Interval: time.Second, Duration: time.Minute}
+	events := schedule(t, config, targets(5, true), true, 1000)
+	if len(events) == 0 || len(events) == 1000 {
+		t.Fatalf("expected the schedule to end after %s but got %d events", config.Duration, len(events))
+	}
+	if last := events[len(events)-1]; last.At > config.Duration {
+		t.Fatalf("expected no event after %s but got %s at %s", config.Duration, last, last.At)
+	}
+}
+
+func TestNewSchedulerInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		targets []Target
+		faults  bool
+		err     string
+	}{
+		{"short interval", Config{Interval: time.Millisecond}, targets(5, true), true, "invalid chaos interval"},
+		{"quorum of all nodes", Config{Quorum: 5}, targets(5, true), true, "leaves none of the 5 nodes"},
+		{"single node", Config{}, targets(1, true), true, "leaves none of the 1 nodes"},
+		{"partition without faults", Config{Actions: []Action{Partition}}, targets(5, true), false, "cannot partition nodes"},
+		{"kill in process", Config{Actions: []Action{Kill}}, targets(5, false), true, "cannot kill nodes"},
+		{"no supported action", Config{}, targets(5, false), false, "no chaos action is supported"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewScheduler(test.config, test.targets, test.faults)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
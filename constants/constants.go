@@ -6,14 +6,13 @@ import (
 
 const (
 	// DO NOT CHANGE VALUES IN THIS FILE
-	VMID               = "tGas3T58KzdjLHhBDMnH2TvrddhqTji5iZAMZ3RXs2NLpSnhH"
-	WhitelistedSubnets = "29uVeLPJB1eQJkzRemU8g8wZDw5uJRqpab5U2mX9euieVwiEbL"
+	VMID = "tGas3T58KzdjLHhBDMnH2TvrddhqTji5iZAMZ3RXs2NLpSnhH"
 
 	VMName = "kewl vm"
 
-	HTTPTimeout  = 10 * time.Second
-	BaseHTTPPort = 9650
-	NumNodes     = 5
+	HTTPTimeout     = 10 * time.Second
+	BaseHTTPPort    = 9650
+	DefaultNumNodes = 5
 
 	FilePerms = 0777
 )
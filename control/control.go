@@ -0,0 +1,455 @@
+// Package control serves a local REST API to manage the network running in
+// this process.
+//
+// Requests must carry the token of the API (see [NewToken]) as an
+// "Authorization: Bearer <token>" header, and requests other than GET must be
+// sent with "Content-Type: application/json", as the API runs binaries given
+// in requests (e.g. upgrade and deploying a blockchain).
+//
+// Endpoints (all request and response bodies are JSON, except metrics):
+//
+//	GET    /v1/nodes                          list the nodes and their status
+//	POST   /v1/nodes                          add a node, optionally as a validator
+//	DELETE /v1/nodes/{name}                   remove a node
+//	POST   /v1/nodes/{name}/stop              stop a node
+//	POST   /v1/nodes/{name}/start             start a stopped node
+//	POST   /v1/nodes/{name}/restart           restart a node
+//	POST   /v1/nodes/{name}/kill              kill a node as if it crashed
+//	POST   /v1/nodes/{name}/pause             freeze a node as if it hung
+//	POST   /v1/nodes/{name}/resume            resume a paused node
+//	POST   /v1/upgrade                        upgrade nodes to another avalanchego
+//	GET    /v1/faults                         list the faults injected between nodes
+//	POST   /v1/faults                         inject faults between nodes
+//	DELETE /v1/faults                         heal all links between nodes
+//	POST   /v1/partition                      partition the nodes into groups
+//	GET    /v1/health                         health of the running nodes
+//	GET    /v1/status                         readiness of the running nodes
+//	GET    /v1/logs                           merged log entries of the nodes
+//	GET    /v1/metrics                        Prometheus metrics of the nodes and ava-sim
+//	GET    /v1/accounts                       list the funded test accounts (with their keys if keys=true)
+//	GET    /v1/subnets                        list the created subnets
+//	POST   /v1/subnets                        create a subnet
+//	POST   /v1/subnets/{id}/blockchains       deploy a blockchain on a subnet
+//
+// The log entries are selected by the query parameters node and chain
+// (repeatable), level, grep, since and tail, and are streamed as one JSON
+// entry per line as the nodes write them if follow is true.
+//
+// The metrics are served in the Prometheus text format, each metric of a node
+// labeled with its node and node_id.
+//
+// Errors are returned as {"error": "..."} with a non-2xx status code: 400 for
+// invalid requests (e.g. naming an unknown node or injecting invalid faults),
+// 404 for unknown routes, nodes and subnets, and 500 for requests that failed.
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/logs"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/metrics"
+	"github.com/ava-labs/ava-sim/network"
+	"github.com/ava-labs/ava-sim/proxy"
+
+	"github.com/fatih/color"
+)
+
+const (
+	shutdownTimeout = 5 * time.Second
+
+	tokenLen = 32
+)
+
+var (
+	errUnauthorized         = errors.New("missing or invalid API token")
+	errUnsupportedMediaType = errors.New("request content type must be application/json")
+)
+
+// NodeStatus is a node of the network and whether it has bootstrapped the
+// primary network
+type NodeStatus struct {
+	manager.NodeInfo
+	Bootstrapped bool `json:"bootstrapped"`
+}
+
+// DeployChainRequest deploys a blockchain of a VM, identified by [VMID] or
+// [VMName], from [Genesis]. If [VM] is set, the VM binary at that path is
+// installed on all nodes first.
+type DeployChainRequest struct {
+	Name    string `json:"name"`
+	VM      string `json:"vm"`
+	VMID    string `json:"vmID"`
+	VMName  string `json:"vmName"`
+	Genesis string `json:"genesis"`
+}
+
+// UpgradeRequest restarts the nodes named [Nodes] (all nodes if empty) one at
+// a time with the avalanchego binary at [AvalancheGoPath]
+type UpgradeRequest struct {
+	AvalancheGoPath string   `json:"avalanchegoPath"`
+	Nodes           []string `json:"nodes"`
+}
+
+// FaultsRequest injects [Faults] on the links between each node named
+// [Nodes] and each node named [Peers] (all nodes if empty)
+type FaultsRequest struct {
+	Nodes  []string     `json:"nodes"`
+	Peers  []string     `json:"peers"`
+	Faults proxy.Faults `json:"faults"`
+}
+
+// PartitionRequest cuts the links between nodes of different [Groups] of
+// node names
+type PartitionRequest struct {
+	Groups [][]string `json:"groups"`
+}
+
+// Listen listens for control API requests on [addr]
+func Listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	return listener, nil
+}
+
+// URL returns the URL of the control API served on [listener]
+func URL(listener net.Listener) string {
+	return fmt.Sprintf("http://%s", listener.Addr())
+}
+
+// NewToken returns a random token for the control API
+func NewToken() (string, error) {
+	b := make([]byte, tokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Serve serves the control API of [n] on [listener] until [ctx] is done,
+// to requests carrying [token]
+func Serve(ctx context.Context, listener net.Listener, n *network.Network, token string) error {
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := authorize(r, token); err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errUnsupportedMediaType) {
+				status = http.StatusUnsupportedMediaType
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			writeError(w, status, err)
+			return
+		}
+		handle(n, w, r)
+	})}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	color.Green("control API accessible at: %s/v1", URL(listener))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// authorize checks that [r] carries [token] and, unless it is a GET
+// request, a JSON body
+func authorize(r *http.Request, token string) error {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(token) == 0 || !strings.HasPrefix(auth, prefix) ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(token)) != 1 {
+		return errUnauthorized
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+	return nil
+}
+
+func handle(n *network.Network, w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(path) < 2 || path[0] != "v1" {
+		writeError(w, http.StatusNotFound, network.ErrNotFound)
+		return
+	}
+
+	var (
+		res interface{}
+		err error
+	)
+	switch route := strings.Join(append([]string{r.Method}, path[1:]...), " "); {
+	case route == "GET nodes":
+		res, err = nodeStatuses(n)
+	case route == "POST nodes":
+		req := network.NodeOptions{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		res, err = n.AddNode(r.Context(), req)
+	case r.Method == http.MethodDelete && len(path) == 3 && path[1] == "nodes":
+		if err = n.RemoveNode(r.Context(), path[2]); err == nil {
+			res, err = nodeStatuses(n)
+		}
+	case r.Method == http.MethodPost && len(path) == 4 && path[1] == "nodes":
+		res, err = nodeAction(r.Context(), n, path[2], path[3])
+	case route == "POST upgrade":
+		req := UpgradeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err = n.UpgradeNodes(r.Context(), req.AvalancheGoPath, req.Nodes...); err == nil {
+			res, err = nodeStatuses(n)
+		}
+	case route == "GET faults":
+		res, err = n.Faults()
+	case route == "POST faults":
+		req := FaultsRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err = n.SetFaults(req.Nodes, req.Peers, req.Faults); err == nil {
+			res, err = n.Faults()
+		}
+	case route == "DELETE faults":
+		if err = n.Heal(); err == nil {
+			res, err = n.Faults()
+		}
+	case route == "POST partition":
+		req := PartitionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err = n.Partition(req.Groups...); err == nil {
+			res, err = n.Faults()
+		}
+	case route == "GET health":
+		res, err = n.Health()
+	case route == "GET status":
+		res, err = n.Status()
+	case route == "GET logs":
+		f, tail, follow, queryErr := logsQuery(r)
+		if queryErr != nil {
+			writeError(w, http.StatusBadRequest, queryErr)
+			return
+		}
+		if follow {
+			followLogs(n, w, r, f)
+			return
+		}
+		var entries []logs.Entry
+		if entries, err = n.Logs(f); err == nil {
+			res = logs.Tail(entries, tail)
+		}
+	case route == "GET metrics":
+		families, err := n.Metrics(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", string(metrics.Format))
+		_ = metrics.Write(w, families)
+		return
+	case route == "GET accounts":
+		keys := false
+		if s := r.URL.Query().Get("keys"); len(s) > 0 {
+			if keys, err = strconv.ParseBool(s); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid keys %q", s))
+				return
+			}
+		}
+		res, err = fundedAccounts(n, keys)
+	case route == "GET subnets":
+		res, err = n.Subnets()
+	case route == "POST subnets":
+		req := network.SubnetOptions{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		res, err = n.CreateSubnet(r.Context(), req)
+	case r.Method == http.MethodPost && len(path) == 4 && path[1] == "subnets" && path[3] == "blockchains":
+		req := DeployChainRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		res, err = n.DeployChain(r.Context(), path[2], network.ChainOptions{
+			Name:    req.Name,
+			VM:      req.VM,
+			VMID:    req.VMID,
+			VMName:  req.VMName,
+			Genesis: []byte(req.Genesis),
+		})
+	default:
+		err = network.ErrNotFound
+	}
+
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// errorStatus returns the status code of the response to a request that
+// failed with [err]
+func errorStatus(err error) int {
+	var invalid *manager.ValidationError
+	switch {
+	case errors.Is(err, network.ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// logsQuery returns the filter, the number of last entries (all if 0) and
+// whether to follow the logs requested by the query of [r]
+func logsQuery(r *http.Request) (logs.Filter, int, bool, error) {
+	query := r.URL.Query()
+	f := logs.Filter{
+		Nodes:   query["node"],
+		Chains:  query["chain"],
+		Level:   query.Get("level"),
+		Pattern: query.Get("grep"),
+	}
+	if since := query.Get("since"); len(since) > 0 {
+		t, err := logs.ParseSince(since, time.Now())
+		if err != nil {
+			return f, 0, false, err
+		}
+		f.Since = t
+	}
+	if err := f.Verify(); err != nil {
+		return f, 0, false, err
+	}
+	tail := 0
+	if s := query.Get("tail"); len(s) > 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return f, 0, false, fmt.Errorf("invalid tail %q", s)
+		}
+		tail = n
+	}
+	follow := false
+	if s := query.Get("follow"); len(s) > 0 {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, 0, false, fmt.Errorf("invalid follow %q", s)
+		}
+		follow = b
+	}
+	return f, tail, follow, nil
+}
+
+// followLogs streams the log entries selected by [f] to [w] as the nodes
+// write them, until the client disconnects
+func followLogs(n *network.Network, w http.ResponseWriter, r *http.Request, f logs.Filter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	encoder := json.NewEncoder(w)
+	_ = n.FollowLogs(r.Context(), f, func(e logs.Entry) error {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+func nodeStatuses(n *network.Network) ([]NodeStatus, error) {
+	nodes := n.Nodes()
+	statuses := make([]NodeStatus, len(nodes))
+	for i, node := range nodes {
+		statuses[i].NodeInfo = node
+		if !node.Running {
+			continue
+		}
+		r := health.Check(health.Node{Name: node.Name, ID: node.ID, URL: node.URL}, health.PrimaryNetwork(0))
+		statuses[i].Bootstrapped = r.Ready
+	}
+	return statuses, nil
+}
+
+// fundedAccounts returns the test accounts funded on [n], with their private
+// keys only if [keys] is set
+func fundedAccounts(n *network.Network, keys bool) ([]accounts.Account, error) {
+	accts, err := n.Accounts()
+	if err != nil || keys {
+		return accts, err
+	}
+	for i := range accts {
+		accts[i].PrivateKey = ""
+		accts[i].EthPrivateKey = ""
+	}
+	return accts, nil
+}
+
+func nodeAction(ctx context.Context, n *network.Network, name string, action string) ([]NodeStatus, error) {
+	var err error
+	switch action {
+	case "stop":
+		err = n.StopNode(ctx, name)
+	case "start":
+		err = n.StartNode(ctx, name)
+	case "restart":
+		err = n.RestartNode(ctx, name)
+	case "kill":
+		err = n.KillNode(ctx, name)
+	case "pause":
+		err = n.PauseNode(name)
+	case "resume":
+		err = n.ResumeNode(name)
+	default:
+		return nil, network.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nodeStatuses(n)
+}
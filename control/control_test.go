@@ -0,0 +1,120 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+)
+
+func TestAuthorize(t *testing.T) {
+	const token = "secret"
+	tests := []struct {
+		name        string
+		method      string
+		auth        string
+		contentType string
+		err         error
+	}{
+		{"get", http.MethodGet, "Bearer secret", "", nil},
+		{"post json", http.MethodPost, "Bearer secret", "application/json", nil},
+		{"post json with charset", http.MethodPost, "Bearer secret", "application/json; charset=utf-8", nil},
+		{"delete json", http.MethodDelete, "Bearer secret", "application/json", nil},
+		{"no token", http.MethodGet, "", "", errUnauthorized},
+		{"wrong token", http.MethodGet, "Bearer secre", "", errUnauthorized},
+		{"basic auth", http.MethodGet, "Basic secret", "", errUnauthorized},
+		{"post without content type", http.MethodPost, "Bearer secret", "", errUnsupportedMediaType},
+		{"post form", http.MethodPost, "Bearer secret", "application/x-www-form-urlencoded", errUnsupportedMediaType},
+		{"post text", http.MethodPost, "Bearer secret", "text/plain", errUnsupportedMediaType},
+		{"post without token", http.MethodPost, "", "application/json", errUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/v1/nodes", nil)
+			if len(test.auth) > 0 {
+				r.Header.Set("Authorization", test.auth)
+			}
+			if len(test.contentType) > 0 {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if err := authorize(r, token); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v but got %v", test.err, err)
+			}
+		})
+	}
+
+	// A server without a token rejects all requests
+	r := httptest.NewRequest(http.MethodGet, "/v1/nodes", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	if err := authorize(r, ""); !errors.Is(err, errUnauthorized) {
+		t.Fatalf("expected %v but got %v", errUnauthorized, err)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 2*tokenLen || a == b {
+		t.Fatalf("expected distinct random tokens but got %q and %q", a, b)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	invalid := &manager.ValidationError{Err: errors.New("unknown node \"node9\"")}
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"not found", fmt.Errorf("node node9: %w", network.ErrNotFound), http.StatusNotFound},
+		{"invalid", invalid, http.StatusBadRequest},
+		{"wrapped invalid", fmt.Errorf("could not add node: %w", invalid), http.StatusBadRequest},
+		{"failed", errors.New("network is not running"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := errorStatus(test.err); status != test.status {
+				t.Fatalf("expected status %d but got %d", test.status, status)
+			}
+		})
+	}
+}
+
+func TestLogsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"invalid pattern", "grep=(", http.StatusBadRequest},
+		{"invalid pattern followed", "grep=(&follow=true", http.StatusBadRequest},
+		{"invalid level", "level=loud", http.StatusBadRequest},
+		{"invalid since", "since=yesterday", http.StatusBadRequest},
+		{"negative tail", "tail=-1", http.StatusBadRequest},
+		// No network runs in the test, so its logs cannot be read
+		{"network not started", "grep=panic", http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handle(&network.Network{}, w, httptest.NewRequest(http.MethodGet, "/v1/logs?"+test.query, nil))
+			if w.Code != test.status {
+				t.Fatalf("expected status %d but got %d: %s", test.status, w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), `"error"`) {
+				t.Fatalf("expected an error but got %s", w.Body)
+			}
+		})
+	}
+}
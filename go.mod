@@ -4,8 +4,15 @@ go 1.16
 
 require (
 	github.com/ava-labs/avalanchego v1.7.1
+	github.com/ethereum/go-ethereum v1.10.12
 	github.com/fatih/color v1.9.0
+	github.com/golang/protobuf v1.5.2
 	github.com/hashicorp/go-plugin v1.4.3 // indirect
+	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.10.0
 	github.com/spf13/viper v1.9.0 // indirect
+	github.com/tyler-smith/go-bip39 v1.0.1-0.20181017060643-dbb3b84ba2ef
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	gopkg.in/yaml.v2 v2.4.0
 )
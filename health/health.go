@@ -0,0 +1,219 @@
+// Package health checks the readiness of the nodes of a network: the health
+// checks each node reports on /ext/health, the chains it has bootstrapped, the
+// peers it is connected to and the status of the subnet blockchains it
+// validates. The same checks back the status reports and every wait of
+// ava-sim, so that a wait that never ends reports what it is stuck on.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/ava-sim/constants"
+
+	"github.com/ava-labs/avalanchego/api/health"
+	"github.com/ava-labs/avalanchego/api/info"
+	"github.com/ava-labs/avalanchego/vms/platformvm"
+	"github.com/fatih/color"
+)
+
+// Node is a node to check
+type Node struct {
+	Name string
+	ID   string
+	URL  string
+}
+
+func (n Node) String() string {
+	if len(n.Name) == 0 {
+		return n.ID
+	}
+	return n.Name
+}
+
+// Requirements are the conditions a node must meet to be ready
+type Requirements struct {
+	// Aliases or IDs of the chains the node must have bootstrapped
+	Chains []string
+	// IDs of the subnet blockchains the node must validate, which it must
+	// have bootstrapped as well
+	Validating []string
+	// Minimum number of peers the node must be connected to
+	MinPeers int
+	// Whether all the health checks of the node must pass. The P-chain is
+	// only healthy once the node is connected to 80% of the stake, which
+	// networks with fewer than 5 nodes (missing some genesis validators) never
+	// are.
+	Healthy bool
+}
+
+// PrimaryNetwork returns the requirements of a node that bootstrapped the
+// primary network and is connected to [minPeers] peers
+func PrimaryNetwork(minPeers int) Requirements {
+	return Requirements{
+		Chains:   constants.Chains,
+		MinPeers: minPeers,
+	}
+}
+
+// Chain is the state of a chain on a node
+type Chain struct {
+	// Alias or ID of the chain
+	Chain        string `json:"chain"`
+	Bootstrapped bool   `json:"bootstrapped"`
+	// Status of a subnet blockchain on the node (Created, Preferred, Syncing
+	// or Validating)
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the readiness of a node
+type Report struct {
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+	// Whether the node answers API calls, failing with [Error] otherwise
+	Reachable bool `json:"reachable"`
+	// Whether all the health checks of the node pass and the names of the
+	// failing ones
+	Healthy bool     `json:"healthy"`
+	Failing []string `json:"failing,omitempty"`
+	Chains  []Chain  `json:"chains"`
+	Peers   int      `json:"peers"`
+	// Whether the node meets its requirements and why it does not otherwise
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Check returns the readiness of [node] against [req]
+func Check(node Node, req Requirements) Report {
+	r := Report{
+		Name: node.Name,
+		ID:   node.ID,
+		URL:  node.URL,
+	}
+	infoClient := info.NewClient(node.URL, constants.HTTPTimeout)
+	peers, err := infoClient.Peers()
+	if err != nil {
+		r.Error = err.Error()
+		r.Reason = fmt.Sprintf("unreachable: %v", err)
+		return r
+	}
+	r.Reachable = true
+	r.Peers = len(peers)
+
+	var reasons []string
+	if reply, err := health.NewClient(node.URL, constants.HTTPTimeout).Health(); err != nil {
+		r.Error = err.Error()
+		if req.Healthy {
+			reasons = append(reasons, fmt.Sprintf("could not query the health checks: %v", err))
+		}
+	} else {
+		r.Healthy = reply.Healthy
+		for name, check := range reply.Checks {
+			if len(check.Error.Message) > 0 {
+				r.Failing = append(r.Failing, name)
+			}
+		}
+		sort.Strings(r.Failing)
+	}
+
+	for _, chain := range req.Chains {
+		r.Chains = append(r.Chains, Chain{Chain: chain})
+	}
+	var pClient platformvm.Client
+	if len(req.Validating) > 0 {
+		pClient = platformvm.NewClient(node.URL, constants.HTTPTimeout)
+	}
+	for _, blockchainID := range req.Validating {
+		chain := Chain{Chain: blockchainID}
+		status, err := pClient.GetBlockchainStatus(blockchainID)
+		if err != nil {
+			chain.Error = err.Error()
+			reasons = append(reasons, fmt.Sprintf("could not query the status of blockchain %s: %v", blockchainID, err))
+		} else {
+			chain.Status = status.String()
+			if status != platformvm.Validating {
+				reasons = append(reasons, fmt.Sprintf("blockchain %s is %s, not Validating", blockchainID, status))
+			}
+		}
+		r.Chains = append(r.Chains, chain)
+	}
+	for i := range r.Chains {
+		c := &r.Chains[i]
+		// Nodes only bootstrap the blockchains they validate
+		if len(c.Error) > 0 || (len(c.Status) > 0 && c.Status != platformvm.Validating.String()) {
+			continue
+		}
+		bootstrapped, err := infoClient.IsBootstrapped(c.Chain)
+		if err != nil {
+			c.Error = err.Error()
+			reasons = append(reasons, fmt.Sprintf("could not query the bootstrap state of %s: %v", chainName(c.Chain), err))
+			continue
+		}
+		c.Bootstrapped = bootstrapped
+		if !bootstrapped {
+			reasons = append(reasons, fmt.Sprintf("bootstrapping %s", chainName(c.Chain)))
+		}
+	}
+	if r.Peers < req.MinPeers {
+		reasons = append(reasons, fmt.Sprintf("connected to %d/%d peers", r.Peers, req.MinPeers))
+	}
+	if req.Healthy && !r.Healthy && len(r.Error) == 0 {
+		reasons = append(reasons, fmt.Sprintf("failing health checks %v", r.Failing))
+	}
+	r.Ready = len(reasons) == 0
+	r.Reason = strings.Join(reasons, ", ")
+	return r
+}
+
+// chainName returns the name of [chain] in messages
+func chainName(chain string) string {
+	for _, alias := range constants.Chains {
+		if chain == alias {
+			return chain + "-chain"
+		}
+	}
+	return "blockchain " + chain
+}
+
+// Poll calls [check] every [interval] until it reports done, printing
+// [what] it waits for and the status it reports whenever the status changes.
+// It fails if [check] does, or with the last status once [ctx] is done.
+func Poll(ctx context.Context, interval time.Duration, what string, check func() (bool, string, error)) error {
+	var last string
+	for {
+		done, status, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if status != last {
+			color.Yellow("waiting for %s: %s", what, status)
+			last = status
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for %s (last status: %s): %w", what, last, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Wait checks [node] against [req] every [interval] until it is ready,
+// returning its last report. It fails with the reason the node is not ready
+// once [ctx] is done.
+func Wait(ctx context.Context, node Node, req Requirements, interval time.Duration) (Report, error) {
+	var r Report
+	err := Poll(ctx, interval, fmt.Sprintf("%s to be ready", node), func() (bool, string, error) {
+		r = Check(node, req)
+		return r.Ready, r.Reason, nil
+	})
+	return r, err
+}
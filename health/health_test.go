@@ -0,0 +1,204 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeNode serves the info, health and platform APIs of a node in the state
+// it describes
+type fakeNode struct {
+	peers   int
+	healthy bool
+	failing []string
+	// Bootstrap state of the chains of the node, which does not know the
+	// other chains
+	bootstrapped map[string]bool
+	// Status of the blockchains on the node, which does not know the other
+	// blockchains
+	statuses map[string]string
+}
+
+func (f *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		Params struct {
+			Chain        string `json:"chain"`
+			BlockchainID string `json:"blockchainID"`
+		} `json:"params"`
+		ID interface{} `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var (
+		result interface{}
+		err    error
+	)
+	switch req.Method {
+	case "info.peers":
+		peers := make([]struct{}, f.peers)
+		result = map[string]interface{}{"numPeers": fmt.Sprint(f.peers), "peers": peers}
+	case "info.isBootstrapped":
+		bootstrapped, ok := f.bootstrapped[req.Params.Chain]
+		if !ok {
+			err = fmt.Errorf("there is no chain with alias/ID '%s'", req.Params.Chain)
+		}
+		result = map[string]bool{"isBootstrapped": bootstrapped}
+	case "health.health":
+		checks := make(map[string]interface{})
+		for _, name := range f.failing {
+			checks[name] = map[string]interface{}{"error": map[string]string{"message": "not healthy"}}
+		}
+		result = map[string]interface{}{"healthy": f.healthy, "checks": checks}
+	case "platform.getBlockchainStatus":
+		status, ok := f.statuses[req.Params.BlockchainID]
+		if !ok {
+			err = fmt.Errorf("unknown blockchain %s", req.Params.BlockchainID)
+		}
+		result = map[string]string{"status": status}
+	default:
+		err = fmt.Errorf("unknown method %s", req.Method)
+	}
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if err != nil {
+		res["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+	} else {
+		res["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// serve serves [f] and returns the node it is
+func serve(t *testing.T, f *fakeNode) Node {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return Node{Name: "node1", ID: "NodeID-1", URL: server.URL}
+}
+
+// primaryNetwork returns the bootstrap state of the primary network chains
+// of a node that bootstrapped all of them but [bootstrapping]
+func primaryNetwork(bootstrapping ...string) map[string]bool {
+	bootstrapped := map[string]bool{"P": true, "X": true, "C": true}
+	for _, chain := range bootstrapping {
+		bootstrapped[chain] = false
+	}
+	return bootstrapped
+}
+
+func TestCheck(t *testing.T) {
+	const blockchainID = "2oo5UvYgFQikM7KBsMXFQE3RQv3xAFFc8JY2GEBNBF1tp4JaeZ"
+	tests := []struct {
+		name   string
+		node   *fakeNode
+		req    Requirements
+		ready  bool
+		reason string
+	}{
+		{
+			name:  "ready",
+			node:  &fakeNode{peers: 4, bootstrapped: primaryNetwork()},
+			req:   PrimaryNetwork(4),
+			ready: true,
+		},
+		{
+			name:   "bootstrapping",
+			node:   &fakeNode{peers: 4, bootstrapped: primaryNetwork("X")},
+			req:    PrimaryNetwork(4),
+			reason: "bootstrapping X-chain",
+		},
+		{
+			name:   "few peers",
+			node:   &fakeNode{peers: 2, bootstrapped: primaryNetwork()},
+			req:    PrimaryNetwork(4),
+			reason: "connected to 2/4 peers",
+		},
+		{
+			name:   "all reasons",
+			node:   &fakeNode{peers: 2, bootstrapped: primaryNetwork("P", "C")},
+			req:    PrimaryNetwork(4),
+			reason: "bootstrapping P-chain, bootstrapping C-chain, connected to 2/4 peers",
+		},
+		{
+			name:  "unhealthy but not required to be healthy",
+			node:  &fakeNode{peers: 4, failing: []string{"P"}, bootstrapped: primaryNetwork()},
+			req:   PrimaryNetwork(4),
+			ready: true,
+		},
+		{
+			name:   "unhealthy",
+			node:   &fakeNode{peers: 4, failing: []string{"P", "network"}, bootstrapped: primaryNetwork()},
+			req:    Requirements{Chains: []string{"P"}, Healthy: true},
+			reason: "failing health checks [P network]",
+		},
+		{
+			name:  "healthy",
+			node:  &fakeNode{healthy: true, bootstrapped: primaryNetwork()},
+			req:   Requirements{Chains: []string{"P"}, Healthy: true},
+			ready: true,
+		},
+		{
+			name: "validating",
+			node: &fakeNode{
+				bootstrapped: map[string]bool{blockchainID: true},
+				statuses:     map[string]string{blockchainID: "Validating"},
+			},
+			req:   Requirements{Validating: []string{blockchainID}},
+			ready: true,
+		},
+		{
+			// The node does not know the blockchain until it validates it
+			name:   "syncing",
+			node:   &fakeNode{statuses: map[string]string{blockchainID: "Syncing"}},
+			req:    Requirements{Validating: []string{blockchainID}},
+			reason: "blockchain " + blockchainID + " is Syncing, not Validating",
+		},
+		{
+			name:   "bootstrapping a blockchain",
+			node:   &fakeNode{bootstrapped: map[string]bool{blockchainID: false}, statuses: map[string]string{blockchainID: "Validating"}},
+			req:    Requirements{Validating: []string{blockchainID}},
+			reason: "bootstrapping blockchain " + blockchainID,
+		},
+		{
+			name:   "unknown chain",
+			node:   &fakeNode{bootstrapped: map[string]bool{}},
+			req:    Requirements{Chains: []string{"P"}},
+			reason: "could not query the bootstrap state of P-chain",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := Check(serve(t, test.node), test.req)
+			if !r.Reachable {
+				t.Fatalf("expected the node to be reachable but got %q", r.Error)
+			}
+			if r.Ready != test.ready || !strings.HasPrefix(r.Reason, test.reason) || (test.ready && len(r.Reason) > 0) {
+				t.Fatalf("expected ready=%t (%q) but got ready=%t (%q)", test.ready, test.reason, r.Ready, r.Reason)
+			}
+			if r.Peers != test.node.peers {
+				t.Fatalf("expected %d peers but got %d", test.node.peers, r.Peers)
+			}
+		})
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	node := serve(t, &fakeNode{})
+	server := httptest.NewServer(http.NotFoundHandler())
+	node.URL = server.URL
+	server.Close()
+
+	r := Check(node, PrimaryNetwork(0))
+	if r.Reachable || r.Ready || !strings.HasPrefix(r.Reason, "unreachable: ") || len(r.Error) == 0 {
+		t.Fatalf("expected the node to be unreachable but got %+v", r)
+	}
+	if r.Name != "node1" || r.ID != "NodeID-1" || r.URL != node.URL {
+		t.Fatalf("expected the report to name the node but got %+v", r)
+	}
+}
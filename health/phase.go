@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/ava-sim/metrics"
+)
+
+// Phases of the setup of a network
+const (
+	// Nodes bootstrapping the primary network and connecting to each other,
+	// on startup and whenever nodes restart or join the network
+	NetworkBootstrap = "network bootstrap"
+	// Creation of a subnet
+	SubnetCreation = "subnet creation"
+	// Addition of the validators of the primary network and of a subnet
+	ValidatorAddition = "validator addition"
+	// Creation of the blockchains of a subnet and their bootstrap by its
+	// validators
+	ChainBootstrap = "chain bootstrap"
+	// Funding of the test accounts on the X, P and C chains
+	AccountFunding = "account funding"
+)
+
+// Timeouts are the deadlines of the phases of the setup of a network. A
+// phase that does not complete before its deadline fails with the status it
+// was stuck on.
+type Timeouts struct {
+	NetworkBootstrap  time.Duration `json:"networkBootstrap"`
+	SubnetCreation    time.Duration `json:"subnetCreation"`
+	ValidatorAddition time.Duration `json:"validatorAddition"`
+	ChainBootstrap    time.Duration `json:"chainBootstrap"`
+	AccountFunding    time.Duration `json:"accountFunding"`
+}
+
+// DefaultTimeouts are the deadlines of the phases when not set
+var DefaultTimeouts = Timeouts{
+	NetworkBootstrap:  5 * time.Minute,
+	SubnetCreation:    2 * time.Minute,
+	ValidatorAddition: 5 * time.Minute,
+	ChainBootstrap:    5 * time.Minute,
+	AccountFunding:    5 * time.Minute,
+}
+
+// WithDefaults returns the timeouts with the ones that are not set replaced
+// by [DefaultTimeouts]
+func (t Timeouts) WithDefaults() Timeouts {
+	if t.NetworkBootstrap <= 0 {
+		t.NetworkBootstrap = DefaultTimeouts.NetworkBootstrap
+	}
+	if t.SubnetCreation <= 0 {
+		t.SubnetCreation = DefaultTimeouts.SubnetCreation
+	}
+	if t.ValidatorAddition <= 0 {
+		t.ValidatorAddition = DefaultTimeouts.ValidatorAddition
+	}
+	if t.ChainBootstrap <= 0 {
+		t.ChainBootstrap = DefaultTimeouts.ChainBootstrap
+	}
+	if t.AccountFunding <= 0 {
+		t.AccountFunding = DefaultTimeouts.AccountFunding
+	}
+	return t
+}
+
+// PhaseError is the error of a phase of the setup of a network
+type PhaseError struct {
+	Phase   string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *PhaseError) Error() string {
+	if e.TimedOut() {
+		return fmt.Sprintf("%s timed out after %s: %v", e.Phase, e.Timeout, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %v", e.Phase, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// TimedOut returns true if the phase failed as it did not complete before
+// its deadline
+func (e *PhaseError) TimedOut() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Phase runs [f] with a context that expires after [timeout], failing with a
+// [PhaseError] naming [phase] if [f] does. Errors of nested phases are
+// returned as is. The duration and result of [phase] are recorded in the
+// metrics of ava-sim.
+func Phase(ctx context.Context, phase string, timeout time.Duration, f func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := f(ctx)
+	metrics.ObservePhase(phase, time.Since(start), err)
+	var phaseErr *PhaseError
+	if err == nil || errors.As(err, &phaseErr) {
+		return err
+	}
+	return &PhaseError{
+		Phase:   phase,
+		Timeout: timeout,
+		Err:     err,
+	}
+}
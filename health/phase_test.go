@@ -0,0 +1,180 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseErrorTimedOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		timedOut bool
+		message  string
+	}{
+		{
+			name:     "deadline exceeded",
+			err:      context.DeadlineExceeded,
+			timedOut: true,
+			message:  "chain bootstrap timed out after 1m0s: context deadline exceeded",
+		},
+		{
+			name:     "wrapped deadline exceeded",
+			err:      fmt.Errorf("gave up waiting for node2 to be ready (last status: bootstrapping X-chain): %w", context.DeadlineExceeded),
+			timedOut: true,
+			message:  "chain bootstrap timed out after 1m0s: gave up waiting for node2",
+		},
+		{
+			name:    "canceled",
+			err:     fmt.Errorf("gave up waiting for node2 to be ready: %w", context.Canceled),
+			message: "chain bootstrap failed: gave up waiting for node2",
+		},
+		{
+			name:    "failed",
+			err:     errors.New("blockchain rejected"),
+			message: "chain bootstrap failed: blockchain rejected",
+		},
+		{
+			// Only the deadline of a phase times it out
+			name:    "deadline mentioned",
+			err:     errors.New(context.DeadlineExceeded.Error()),
+			message: "chain bootstrap failed: context deadline exceeded",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := &PhaseError{Phase: ChainBootstrap, Timeout: time.Minute, Err: test.err}
+			if err.TimedOut() != test.timedOut {
+				t.Fatalf("expected timed out to be %t", test.timedOut)
+			}
+			if !strings.HasPrefix(err.Error(), test.message) {
+				t.Fatalf("expected %q but got %q", test.message, err.Error())
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v to wrap %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestPhase(t *testing.T) {
+	errFailed := errors.New("failed")
+	// waitDeadline fails once the deadline of the phase expires
+	waitDeadline := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		f        func(context.Context) error
+		phase    string
+		timedOut bool
+		err      error
+	}{
+		{
+			name:    "succeeded",
+			timeout: time.Minute,
+			f:       func(context.Context) error { return nil },
+		},
+		{
+			name:    "failed",
+			timeout: time.Minute,
+			f:       func(context.Context) error { return errFailed },
+			phase:   SubnetCreation,
+			err:     errFailed,
+		},
+		{
+			name:     "deadline expired",
+			timeout:  10 * time.Millisecond,
+			f:        waitDeadline,
+			phase:    SubnetCreation,
+			timedOut: true,
+			err:      context.DeadlineExceeded,
+		},
+		{
+			// The error of a nested phase names it rather than the outer one
+			name:    "nested phase expired",
+			timeout: time.Minute,
+			f: func(ctx context.Context) error {
+				return Phase(ctx, ValidatorAddition, 10*time.Millisecond, waitDeadline)
+			},
+			phase:    ValidatorAddition,
+			timedOut: true,
+			err:      context.DeadlineExceeded,
+		},
+		{
+			// A nested phase fails with the deadline of the outer phase if it
+			// expires first
+			name:    "outer phase expired",
+			timeout: 10 * time.Millisecond,
+			f: func(ctx context.Context) error {
+				return Phase(ctx, ValidatorAddition, time.Minute, waitDeadline)
+			},
+			phase:    ValidatorAddition,
+			timedOut: true,
+			err:      context.DeadlineExceeded,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Phase(context.Background(), SubnetCreation, test.timeout, test.f)
+			if test.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var phaseErr *PhaseError
+			if !errors.As(err, &phaseErr) {
+				t.Fatalf("expected a phase error but got %v", err)
+			}
+			if phaseErr.Phase != test.phase || phaseErr.TimedOut() != test.timedOut || !errors.Is(err, test.err) {
+				t.Fatalf("expected %s to fail with %v (timed out: %t) but got %v", test.phase, test.err, test.timedOut, err)
+			}
+		})
+	}
+}
+
+func TestPhaseCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := Phase(ctx, NetworkBootstrap, time.Minute, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var phaseErr *PhaseError
+	if !errors.As(err, &phaseErr) || phaseErr.TimedOut() || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a phase canceled before its deadline but got %v", err)
+	}
+}
+
+func TestWaitDeadline(t *testing.T) {
+	node := serve(t, &fakeNode{peers: 4, bootstrapped: primaryNetwork("X")})
+	err := Phase(context.Background(), NetworkBootstrap, 50*time.Millisecond, func(ctx context.Context) error {
+		_, err := Wait(ctx, node, PrimaryNetwork(4), 10*time.Millisecond)
+		return err
+	})
+	var phaseErr *PhaseError
+	if !errors.As(err, &phaseErr) || !phaseErr.TimedOut() {
+		t.Fatalf("expected the network bootstrap to time out but got %v", err)
+	}
+	// The error tells what the phase was stuck on
+	expected := "network bootstrap timed out after 50ms: gave up waiting for node1 to be ready (last status: bootstrapping X-chain)"
+	if !strings.HasPrefix(err.Error(), expected) {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	timeouts := Timeouts{SubnetCreation: time.Second, ChainBootstrap: -time.Second}.WithDefaults()
+	expected := DefaultTimeouts
+	expected.SubnetCreation = time.Second
+	if timeouts != expected {
+		t.Fatalf("expected %+v but got %+v", expected, timeouts)
+	}
+}
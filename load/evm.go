@@ -0,0 +1,224 @@
+package load
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/runner"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// Gas limits of value transfers, contract calls and the deployment of
+	// the contract
+	transferGas = 21000
+	callGas     = 100000
+	deployGas   = 200000
+
+	// How often the gas price is queried, which is paid twice over so that
+	// txs are not underpriced if the base fee rises in between
+	gasPriceInterval = 5 * time.Second
+)
+
+var (
+	// Wei sent to the account of each node
+	evmFunds = new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+	// Wei transferred by each tx
+	evmAmount = big.NewInt(1)
+
+	// Contract incrementing a counter in its storage whenever it is called:
+	//
+	//	PUSH1 0x0a PUSH1 0x0c PUSH1 0x00 CODECOPY PUSH1 0x0a PUSH1 0x00 RETURN
+	//	PUSH1 0x00 SLOAD PUSH1 0x01 ADD PUSH1 0x00 SSTORE STOP
+	counterCode = hexutil.MustDecode("0x600a600c600039600a6000f360005460010160005500")
+)
+
+// evmWorker transfers value to the account of the next worker and calls the
+// counter contract on an EVM chain of a node, tracking the nonce of its
+// account so that it does not wait for its txs to be accepted
+type evmWorker struct {
+	client    *ethclient.Client
+	signer    types.Signer
+	key       *ecdsa.PrivateKey
+	to        common.Address
+	contract  common.Address
+	callRatio float64
+	rng       *rand.Rand
+
+	// Nonce of the next tx, queried again if the last tx could not be issued
+	nonce      uint64
+	knownNonce bool
+	// Gas price paid and when it was queried
+	gasPrice   *big.Int
+	gasPriceAt time.Time
+}
+
+// newEVMWorkers returns a worker per node of [urls] on the EVM chain [chain],
+// funded by the genesis key, of which [callRatio] of the txs call the counter
+// contract
+func newEVMWorkers(ctx context.Context, urls []string, chain string, callRatio float64) ([]worker, error) {
+	clients := make([]*ethclient.Client, len(urls))
+	for i, url := range urls {
+		client, err := ethclient.DialContext(ctx, fmt.Sprintf("%s/ext/bc/%s/rpc", url, chain))
+		if err != nil {
+			return nil, err
+		}
+		clients[i] = client
+	}
+	chainID, err := clients[0].ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not query chain ID: %w", err)
+	}
+	signer := types.LatestSignerForChainID(chainID)
+	genesisKey, err := accounts.ECDSAKey(runner.GenesisKey)
+	if err != nil {
+		return nil, err
+	}
+	funder := &evmWorker{
+		client: clients[0],
+		signer: signer,
+		key:    genesisKey,
+	}
+
+	// Deploy the contract and fund the accounts of the nodes at once
+	var hashes []common.Hash
+	deployment, err := funder.send(ctx, nil, nil, deployGas, counterCode)
+	if err != nil {
+		return nil, fmt.Errorf("could not deploy contract: %w", err)
+	}
+	hashes = append(hashes, deployment)
+	keys := make([]*ecdsa.PrivateKey, len(urls))
+	for i := range keys {
+		if keys[i], err = crypto.GenerateKey(); err != nil {
+			return nil, err
+		}
+		to := crypto.PubkeyToAddress(keys[i].PublicKey)
+		hash, err := funder.send(ctx, &to, evmFunds, transferGas, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fund accounts: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	var contract common.Address
+	for i, hash := range hashes {
+		var receipt *types.Receipt
+		err := health.Poll(ctx, pollInterval, fmt.Sprintf("%s funding tx (%s) to be accepted", chain, hash.Hex()), func() (bool, string, error) {
+			var err error
+			receipt, err = clients[0].TransactionReceipt(ctx, hash)
+			switch {
+			case errors.Is(err, ethereum.NotFound):
+				return false, "pending", nil
+			case err != nil:
+				return false, fmt.Sprintf("could not query receipt: %v", err), nil
+			case receipt.Status != types.ReceiptStatusSuccessful:
+				return false, "", fmt.Errorf("%s funding tx (%s) failed", chain, hash.Hex())
+			}
+			return true, "", nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			contract = receipt.ContractAddress
+		}
+	}
+
+	workers := make([]worker, len(urls))
+	for i, client := range clients {
+		workers[i] = &evmWorker{
+			client:    client,
+			signer:    signer,
+			key:       keys[i],
+			to:        crypto.PubkeyToAddress(keys[(i+1)%len(keys)].PublicKey),
+			contract:  contract,
+			callRatio: callRatio,
+			rng:       rand.New(rand.NewSource(time.Now().UnixNano() + int64(i))), // #nosec G404
+		}
+	}
+	return workers, nil
+}
+
+func (w *evmWorker) issue(ctx context.Context) (func(context.Context) (bool, error), error) {
+	var (
+		hash common.Hash
+		err  error
+	)
+	if w.rng.Float64() < w.callRatio {
+		hash, err = w.send(ctx, &w.contract, nil, callGas, nil)
+	} else {
+		hash, err = w.send(ctx, &w.to, evmAmount, transferGas, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (bool, error) {
+		return w.accepted(ctx, hash)
+	}, nil
+}
+
+// send signs and issues a tx sending [value] to [to] (deploying a contract if
+// it is nil) with [data] and [gas], returning its hash
+func (w *evmWorker) send(ctx context.Context, to *common.Address, value *big.Int, gas uint64, data []byte) (common.Hash, error) {
+	from := crypto.PubkeyToAddress(w.key.PublicKey)
+	if !w.knownNonce {
+		nonce, err := w.client.PendingNonceAt(ctx, from)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("could not query nonce: %w", err)
+		}
+		w.nonce, w.knownNonce = nonce, true
+	}
+	if w.gasPrice == nil || time.Since(w.gasPriceAt) > gasPriceInterval {
+		gasPrice, err := w.client.SuggestGasPrice(ctx)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("could not query gas price: %w", err)
+		}
+		w.gasPrice, w.gasPriceAt = new(big.Int).Mul(gasPrice, big.NewInt(2)), time.Now()
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	tx, err := types.SignNewTx(w.key, w.signer, &types.LegacyTx{
+		Nonce:    w.nonce,
+		GasPrice: w.gasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := w.client.SendTransaction(ctx, tx); err != nil {
+		w.knownNonce = false
+		return common.Hash{}, err
+	}
+	w.nonce++
+	return tx.Hash(), nil
+}
+
+// accepted returns true if the tx [hash] was accepted, failing if it
+// reverted. Errors querying its receipt are ignored so that it is queried
+// again.
+func (w *evmWorker) accepted(ctx context.Context, hash common.Hash) (bool, error) {
+	receipt, err := w.client.TransactionReceipt(ctx, hash)
+	switch {
+	case err != nil:
+		return false, nil
+	case receipt.Status != types.ReceiptStatusSuccessful:
+		return false, fmt.Errorf("tx %s reverted", hash.Hex())
+	}
+	return true, nil
+}
@@ -0,0 +1,373 @@
+// Package load generates transaction traffic on the chains of a running
+// network, e.g. to load test a custom VM or watch a network degrade under
+// faults: it issues AVAX transfers on the X-chain, and value transfers and
+// contract calls on the C-chain and Subnet-EVM blockchains, at a given rate
+// spread across the nodes, and reports the throughput, the acceptance latency
+// and the error rate of each chain.
+//
+// Txs are issued from the funded genesis key through accounts of their own,
+// one per node, funded by the genesis key before the run starts, so that txs
+// issued through different nodes do not conflict.
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const (
+	DefaultRate           = 10
+	DefaultCallRatio      = 0.5
+	DefaultTxTimeout      = 30 * time.Second
+	DefaultReportInterval = 10 * time.Second
+
+	// X-chain alias
+	XChain = "X"
+	// C-chain alias
+	CChain = "C"
+
+	// How often the status of issued txs is checked
+	pollInterval = 100 * time.Millisecond
+)
+
+// Config describes the traffic of a load run
+type Config struct {
+	// URLs of the nodes the txs are issued through
+	URLs []string
+	// Chains to issue txs on: [XChain], [CChain] or the IDs of Subnet-EVM
+	// blockchains
+	Chains []string
+	// Txs issued per second on each chain. Defaults to [DefaultRate].
+	Rate float64
+	// Fraction of the txs issued on EVM chains that call a contract rather
+	// than transfer value
+	CallRatio float64
+	// Time after which a tx that was neither accepted nor rejected counts as
+	// failed. Defaults to [DefaultTxTimeout].
+	TxTimeout time.Duration
+	// Time after which no more txs are issued, once the txs issued are
+	// decided. Txs are issued until the context of the run is done if 0.
+	Duration time.Duration
+	// Called with the report of the run so far every ReportInterval, if not
+	// nil. Defaults to [DefaultReportInterval].
+	ReportInterval time.Duration
+	OnReport       func(Report)
+}
+
+// Verify returns an error if the config is invalid
+func (c Config) Verify() error {
+	switch {
+	case len(c.URLs) == 0:
+		return errors.New("no node to issue txs through")
+	case len(c.Chains) == 0:
+		return errors.New("no chain to issue txs on")
+	case c.Rate < 0 || math.IsNaN(c.Rate) || math.IsInf(c.Rate, 0):
+		return fmt.Errorf("invalid load rate %g", c.Rate)
+	case c.CallRatio < 0 || c.CallRatio > 1 || math.IsNaN(c.CallRatio):
+		return fmt.Errorf("invalid contract call ratio %g (expecting between 0 and 1)", c.CallRatio)
+	case c.TxTimeout < 0:
+		return fmt.Errorf("invalid tx timeout %s", c.TxTimeout)
+	case c.Duration < 0:
+		return fmt.Errorf("invalid load duration %s", c.Duration)
+	case c.ReportInterval < 0:
+		return fmt.Errorf("invalid report interval %s", c.ReportInterval)
+	}
+	return nil
+}
+
+// Report is the outcome of a load run so far
+type Report struct {
+	// Time since the first tx was issued, in seconds
+	Elapsed float64       `json:"elapsed"`
+	Chains  []ChainReport `json:"chains"`
+}
+
+// ChainReport is the outcome of the txs issued on a chain
+type ChainReport struct {
+	Chain string `json:"chain"`
+	// Txs issued, accepted, that failed (they could not be issued, were
+	// rejected, reverted or timed out) and that are still pending
+	Issued   int `json:"issued"`
+	Accepted int `json:"accepted"`
+	Failed   int `json:"failed"`
+	Pending  int `json:"pending"`
+	// Txs accepted per second
+	Throughput float64 `json:"throughput"`
+	// Fraction of the txs attempted that failed
+	ErrorRate float64 `json:"errorRate"`
+	// Time from the issuance of the accepted txs to their acceptance
+	Latency Latency `json:"latency"`
+	// Last error a tx failed with
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Latency summarizes the distribution of acceptance latencies, in seconds
+type Latency struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+func (r ChainReport) String() string {
+	desc := fmt.Sprintf("%s: issued=%d accepted=%d failed=%d pending=%d throughput=%.1f/s errors=%.1f%% latency p50=%s p90=%s p99=%s max=%s",
+		r.Chain, r.Issued, r.Accepted, r.Failed, r.Pending, r.Throughput, 100*r.ErrorRate,
+		seconds(r.Latency.P50), seconds(r.Latency.P90), seconds(r.Latency.P99), seconds(r.Latency.Max))
+	if len(r.LastError) > 0 {
+		desc += fmt.Sprintf(" (last error: %s)", r.LastError)
+	}
+	return desc
+}
+
+// seconds formats [s] seconds as a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
+
+// worker issues txs through a node from an account of its own
+type worker interface {
+	// issue issues a tx, returning a function reporting whether it was
+	// accepted, failing if it was rejected
+	issue(ctx context.Context) (func(ctx context.Context) (bool, error), error)
+}
+
+// stats records the outcome of the txs issued on a chain
+type stats struct {
+	lock      sync.Mutex
+	chain     string
+	attempts  int
+	issued    int
+	accepted  int
+	failed    int
+	latencies []time.Duration
+	lastError string
+	// Time the last tx was accepted at
+	lastAccepted time.Time
+}
+
+func (s *stats) recordIssue(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attempts++
+	if err != nil {
+		s.failed++
+		s.lastError = err.Error()
+		return
+	}
+	s.issued++
+}
+
+func (s *stats) recordDecision(latency time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.failed++
+		s.lastError = err.Error()
+		return
+	}
+	s.accepted++
+	s.latencies = append(s.latencies, latency)
+	s.lastAccepted = time.Now()
+}
+
+// report returns the report of the chain of a run that started at [start]
+func (s *stats) report(start time.Time) ChainReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := ChainReport{
+		Chain:     s.chain,
+		Issued:    s.issued,
+		Accepted:  s.accepted,
+		Failed:    s.failed,
+		Pending:   s.attempts - s.accepted - s.failed,
+		LastError: s.lastError,
+	}
+	// Throughput is measured until the last acceptance so that it does not
+	// drop while the last txs are decided
+	if elapsed := s.lastAccepted.Sub(start); elapsed > 0 {
+		r.Throughput = float64(s.accepted) / elapsed.Seconds()
+	}
+	if s.attempts > 0 {
+		r.ErrorRate = float64(s.failed) / float64(s.attempts)
+	}
+	latencies := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.Latency = Latency{
+		P50: percentile(latencies, 0.5),
+		P90: percentile(latencies, 0.9),
+		P99: percentile(latencies, 0.99),
+		Max: percentile(latencies, 1),
+	}
+	return r
+}
+
+// percentile returns the [p] percentile of the sorted [latencies] in seconds
+func percentile(latencies []time.Duration, p float64) float64 {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return latencies[i].Seconds()
+}
+
+// Run funds an account per node on each chain of [config] from the genesis
+// key, then issues txs through the nodes until [ctx] is done or the run lasted
+// [Config.Duration], and returns the report of the run. Txs that are pending
+// when the run ends are given [Config.TxTimeout] to be decided, unless [ctx]
+// is done.
+func Run(ctx context.Context, config Config) (Report, error) {
+	if err := config.Verify(); err != nil {
+		return Report{}, err
+	}
+	if config.Rate == 0 {
+		config.Rate = DefaultRate
+	}
+	if config.TxTimeout == 0 {
+		config.TxTimeout = DefaultTxTimeout
+	}
+	if config.ReportInterval == 0 {
+		config.ReportInterval = DefaultReportInterval
+	}
+
+	workers := make([][]worker, len(config.Chains))
+	chainStats := make([]*stats, len(config.Chains))
+	for i, chain := range config.Chains {
+		var err error
+		if chain == XChain {
+			workers[i], err = newXWorkers(ctx, config.URLs)
+		} else {
+			workers[i], err = newEVMWorkers(ctx, config.URLs, chain, config.CallRatio)
+		}
+		if err != nil {
+			return Report{}, fmt.Errorf("could not set up load on %s: %w", chain, err)
+		}
+		chainStats[i] = &stats{chain: chain}
+	}
+
+	issueCtx := ctx
+	if config.Duration > 0 {
+		var cancel context.CancelFunc
+		issueCtx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+	color.Cyan("issuing %g txs/s on %s through %d nodes", config.Rate, strings.Join(config.Chains, ","), len(config.URLs))
+
+	var (
+		start  = time.Now()
+		report = func() Report {
+			r := Report{Elapsed: time.Since(start).Seconds()}
+			for _, s := range chainStats {
+				r.Chains = append(r.Chains, s.report(start))
+			}
+			return r
+		}
+		issuers sync.WaitGroup
+		pending sync.WaitGroup
+	)
+	for i := range config.Chains {
+		// Each worker issues its share of the txs of the chain
+		interval := issueInterval(config.Rate, len(workers[i]))
+		for _, w := range workers[i] {
+			w, s := w, chainStats[i]
+			issuers.Add(1)
+			go func() {
+				defer issuers.Done()
+				issue(issueCtx, ctx, w, s, interval, config.TxTimeout, &pending)
+			}()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		issuers.Wait()
+		pending.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(config.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return report(), nil
+		case <-ticker.C:
+			if config.OnReport != nil {
+				config.OnReport(report())
+			}
+		}
+	}
+}
+
+// issueInterval returns the time between two txs issued by each of
+// [workers] workers sharing a rate of [rate] txs per second. Rates too high to
+// be met issue txs as fast as possible.
+func issueInterval(rate float64, workers int) time.Duration {
+	interval := float64(time.Second) * float64(workers) / rate
+	switch {
+	case interval < 1:
+		return 1
+	case interval >= math.MaxInt64:
+		return math.MaxInt64
+	}
+	return time.Duration(interval)
+}
+
+// issue issues a tx through [w] every [interval] until [issueCtx] is done,
+// recording the outcome of the txs in [s]. Txs are polled until they are
+// decided, they timed out after [txTimeout] or [ctx] is done.
+func issue(issueCtx context.Context, ctx context.Context, w worker, s *stats, interval time.Duration, txTimeout time.Duration, pending *sync.WaitGroup) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-issueCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		issued := time.Now()
+		accepted, err := w.issue(issueCtx)
+		if issueCtx.Err() != nil {
+			return
+		}
+		s.recordIssue(err)
+		if err != nil {
+			continue
+		}
+		pending.Add(1)
+		go func() {
+			defer pending.Done()
+			pollCtx, cancel := context.WithTimeout(ctx, txTimeout)
+			defer cancel()
+			for {
+				ok, err := accepted(pollCtx)
+				switch {
+				case err != nil:
+					s.recordDecision(0, err)
+					return
+				case ok:
+					s.recordDecision(time.Since(issued), nil)
+					return
+				}
+				select {
+				case <-pollCtx.Done():
+					if ctx.Err() == nil {
+						s.recordDecision(0, fmt.Errorf("tx was not accepted within %s", txTimeout))
+					}
+					return
+				case <-time.After(pollInterval):
+				}
+			}
+		}()
+	}
+}
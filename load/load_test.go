@@ -0,0 +1,143 @@
+package load
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	valid := Config{URLs: []string{"http://127.0.0.1:9650"}, Chains: []string{XChain}}
+	tests := []struct {
+		name   string
+		update func(c *Config)
+		err    string
+	}{
+		{"default rate", func(c *Config) {}, ""},
+		{"high rate", func(c *Config) { c.Rate = 1e10 }, ""},
+		{"low rate", func(c *Config) { c.Rate = 1e-30 }, ""},
+		{"negative rate", func(c *Config) { c.Rate = -1 }, "invalid load rate -1"},
+		{"infinite rate", func(c *Config) { c.Rate = math.Inf(1) }, "invalid load rate +Inf"},
+		{"NaN rate", func(c *Config) { c.Rate = math.NaN() }, "invalid load rate NaN"},
+		{"NaN call ratio", func(c *Config) { c.CallRatio = math.NaN() }, "invalid contract call ratio"},
+		{"call ratio above 1", func(c *Config) { c.CallRatio = 1.5 }, "invalid contract call ratio"},
+		{"no node", func(c *Config) { c.URLs = nil }, "no node"},
+		{"no chain", func(c *Config) { c.Chains = nil }, "no chain"},
+		{"negative duration", func(c *Config) { c.Duration = -time.Second }, "invalid load duration"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := valid
+			test.update(&config)
+			err := config.Verify()
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestIssueInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		workers  int
+		expected time.Duration
+	}{
+		{"single worker", 10, 1, 100 * time.Millisecond},
+		{"shared rate", 10, 5, 500 * time.Millisecond},
+		{"fraction of a tx per second", 0.5, 1, 2 * time.Second},
+		{"rate above a tx per nanosecond", 1e10, 1, time.Nanosecond},
+		{"rate below a tx per 292 years", 1e-30, 1, math.MaxInt64},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if interval := issueInterval(test.rate, test.workers); interval != test.expected {
+				t.Fatalf("expected %s but got %s", test.expected, interval)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	start := time.Now()
+	s := &stats{chain: XChain}
+	// 10 txs issued, of which 1 could not be issued, 1 was rejected, 7 were
+	// accepted and 1 is pending
+	s.recordIssue(errors.New("insufficient funds"))
+	for i := 0; i < 9; i++ {
+		s.recordIssue(nil)
+	}
+	s.recordDecision(0, errors.New("rejected"))
+	for i := 1; i <= 7; i++ {
+		s.recordDecision(time.Duration(8-i)*time.Second, nil)
+	}
+	s.lastAccepted = start.Add(2 * time.Second)
+
+	r := s.report(start)
+	if r.Chain != XChain || r.Issued != 9 || r.Accepted != 7 || r.Failed != 2 || r.Pending != 1 {
+		t.Fatalf("unexpected counts %+v", r)
+	}
+	if r.Throughput != 3.5 {
+		t.Fatalf("expected a throughput of 3.5 txs/s but got %g", r.Throughput)
+	}
+	if r.ErrorRate != 0.2 {
+		t.Fatalf("expected an error rate of 0.2 but got %g", r.ErrorRate)
+	}
+	if r.LastError != "rejected" {
+		t.Fatalf("expected last error %q but got %q", "rejected", r.LastError)
+	}
+	expected := Latency{P50: 4, P90: 7, P99: 7, Max: 7}
+	if r.Latency != expected {
+		t.Fatalf("expected latency %+v but got %+v", expected, r.Latency)
+	}
+	// The recorded latencies are left unsorted
+	if s.latencies[0] != 7*time.Second {
+		t.Fatalf("expected the recorded latencies to be left as is but got %s first", s.latencies[0])
+	}
+}
+
+func TestReportEmpty(t *testing.T) {
+	r := (&stats{chain: CChain}).report(time.Now())
+	if r != (ChainReport{Chain: CChain}) {
+		t.Fatalf("expected an empty report but got %+v", r)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Second
+	}
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		p         float64
+		expected  float64
+	}{
+		{"none", nil, 0.5, 0},
+		{"single", latencies[:1], 0.99, 1},
+		{"median of two", latencies[:2], 0.5, 1},
+		{"median of three", latencies[:3], 0.5, 2},
+		{"p50", latencies, 0.5, 50},
+		{"p90", latencies, 0.9, 90},
+		{"p99", latencies, 0.99, 99},
+		{"max", latencies, 1, 100},
+		{"min", latencies, 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if p := percentile(test.latencies, test.p); p != test.expected {
+				t.Fatalf("expected %g but got %g", test.expected, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package load
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/runner"
+
+	"github.com/ava-labs/avalanchego/api"
+	"github.com/ava-labs/avalanchego/api/keystore"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	cjson "github.com/ava-labs/avalanchego/utils/json"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"github.com/ava-labs/avalanchego/vms/avm"
+)
+
+const (
+	// AVAX sent to the account of each node, which pays for 1000 txs per AVAX
+	xFunds = 1000 * units.Avax
+	// AVAX transferred by each tx
+	xAmount = 1
+)
+
+// Keystore user holding the accounts of the load on the X-chain
+var user = api.UserPass{
+	Username: "load",
+	Password: "vmsrkewl",
+}
+
+// xWorker transfers AVAX from [address] to itself on the X-chain of a node,
+// through the wallet API of the node so that a tx can spend the outputs of
+// the txs issued before it even if they are not accepted yet
+type xWorker struct {
+	client  avm.Client
+	wallet  avm.WalletClient
+	address string
+}
+
+// newXWorkers returns a worker per node of [urls] on the X-chain, funded by
+// the genesis key
+func newXWorkers(ctx context.Context, urls []string) ([]worker, error) {
+	var (
+		workers = make([]worker, len(urls))
+		outputs = make([]avm.SendOutput, len(urls))
+	)
+	for i, url := range urls {
+		if err := createUser(url); err != nil {
+			return nil, err
+		}
+		client := avm.NewClient(url, XChain, constants.HTTPTimeout)
+		address, err := client.CreateAddress(user)
+		if err != nil {
+			return nil, fmt.Errorf("could not create address: %w", err)
+		}
+		workers[i] = &xWorker{
+			client:  client,
+			wallet:  avm.NewWalletClient(url, XChain, constants.HTTPTimeout),
+			address: address,
+		}
+		outputs[i] = avm.SendOutput{
+			Amount:  cjson.Uint64(xFunds),
+			AssetID: "AVAX",
+			To:      address,
+		}
+	}
+
+	client := avm.NewClient(urls[0], XChain, constants.HTTPTimeout)
+	fundedAddress, err := client.ImportKey(user, runner.GenesisKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to import genesis key: %w", err)
+	}
+	txID, err := client.SendMultiple(user, []string{fundedAddress}, fundedAddress, outputs, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to fund accounts: %w", err)
+	}
+	err = health.Poll(ctx, pollInterval, fmt.Sprintf("X-chain funding tx (%s) to be accepted", txID), func() (bool, string, error) {
+		status, err := client.GetTxStatus(txID)
+		if err != nil {
+			return false, fmt.Sprintf("could not query tx status: %v", err), nil
+		}
+		if status == choices.Rejected {
+			return false, "", fmt.Errorf("X-chain funding tx (%s) was rejected", txID)
+		}
+		return status == choices.Accepted, status.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workers, nil
+}
+
+// createUser creates the keystore user of the load on the node at [url] if
+// it does not exist
+func createUser(url string) error {
+	client := keystore.NewClient(url, constants.HTTPTimeout)
+	users, err := client.ListUsers()
+	if err != nil {
+		return fmt.Errorf("could not list users: %w", err)
+	}
+	for _, name := range users {
+		if name == user.Username {
+			return nil
+		}
+	}
+	if ok, err := client.CreateUser(user); !ok || err != nil {
+		return fmt.Errorf("could not create user: %w", err)
+	}
+	return nil
+}
+
+func (w *xWorker) issue(ctx context.Context) (func(context.Context) (bool, error), error) {
+	txID, err := w.wallet.Send(user, []string{w.address}, w.address, xAmount, "AVAX", w.address, "")
+	if err != nil {
+		return nil, err
+	}
+	return func(context.Context) (bool, error) {
+		return w.accepted(txID)
+	}, nil
+}
+
+// accepted returns true if [txID] was accepted, failing if it was rejected.
+// Errors querying its status are ignored so that it is queried again.
+func (w *xWorker) accepted(txID ids.ID) (bool, error) {
+	status, err := w.client.GetTxStatus(txID)
+	switch {
+	case err != nil:
+		return false, nil
+	case status == choices.Rejected:
+		return false, fmt.Errorf("tx %s was rejected", txID)
+	}
+	return status == choices.Accepted, nil
+}
@@ -0,0 +1,465 @@
+// Package logs reads the logs the nodes of a network write to their log
+// directories: one file per chain (named after its alias, e.g. P.log, or its
+// blockchain ID) and main.log, rotated to <name>.log.1, <name>.log.2... It
+// merges them into a single time-ordered stream, filtered by node, chain,
+// level and pattern, and tails them as the nodes write them.
+package logs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+)
+
+const (
+	// Chain of the entries of main.log, written by the node itself
+	MainChain = "main"
+
+	timeFormat = "01-02|15:04:05"
+)
+
+var (
+	// An entry starts with its aligned level and its timestamp, e.g.
+	// "WARN [10-18|01:07:31] <P Chain> vms/platformvm/vm.go#226: ..."
+	entryStart = regexp.MustCompile(`^([A-Z]+) *\[(\d\d-\d\d\|\d\d:\d\d:\d\d)\] ?(.*)$`)
+	colorCode  = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// Source is the log directory [Dir] of the node named [Node] with ID [ID]
+type Source struct {
+	Node string
+	ID   string
+	Dir  string
+}
+
+// Entry is a log entry of a node. Entries spanning several lines (e.g. stack
+// traces) hold all of them in [Message].
+type Entry struct {
+	Node  string `json:"node"`
+	ID    string `json:"id"`
+	Chain string `json:"chain"`
+	Level string `json:"level"`
+	// Nodes log with second precision in local time and without the year,
+	// which is taken from the modification time of the log file
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// String returns the entry prefixed with the node, its short ID and the chain
+func (e Entry) String() string {
+	chain := e.Chain
+	if len(chain) > 8 {
+		chain = chain[:8]
+	}
+	id := strings.TrimPrefix(e.ID, "NodeID-")
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("%s %s %-8s %-5s[%s] %s", e.Node, id, chain, e.Level, e.Time.Format(timeFormat), e.Message)
+}
+
+// Filter selects log entries. Empty fields select all entries.
+type Filter struct {
+	// Names or IDs of the nodes
+	Nodes []string `json:"nodes,omitempty"`
+	// Aliases (P, C, X), blockchain IDs or main
+	Chains []string `json:"chains,omitempty"`
+	// Least severe level, e.g. warn for warnings, errors and fatal errors
+	Level string `json:"level,omitempty"`
+	// Regular expression the message must match
+	Pattern string    `json:"pattern,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+// Verify returns an error if the level or the pattern of [f] is invalid
+func (f Filter) Verify() error {
+	_, err := f.compile()
+	return err
+}
+
+// matcher is a compiled [Filter]
+type matcher struct {
+	nodes   map[string]bool
+	chains  map[string]bool
+	level   logging.Level
+	pattern *regexp.Regexp
+	since   time.Time
+}
+
+func (f Filter) compile() (*matcher, error) {
+	m := &matcher{
+		nodes:  make(map[string]bool),
+		chains: make(map[string]bool),
+		level:  logging.Verbo,
+		since:  f.Since,
+	}
+	for _, node := range f.Nodes {
+		m.nodes[node] = true
+	}
+	for _, chain := range f.Chains {
+		m.chains[chain] = true
+	}
+	if len(f.Level) > 0 {
+		level, err := logging.ToLevel(f.Level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level: %w", err)
+		}
+		m.level = level
+	}
+	if len(f.Pattern) > 0 {
+		pattern, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		m.pattern = pattern
+	}
+	return m, nil
+}
+
+// source returns true if the entries of [s] may match
+func (m *matcher) source(s Source) bool {
+	return len(m.nodes) == 0 || m.nodes[s.Node] || m.nodes[s.ID]
+}
+
+// chain returns true if the entries of [chain] may match
+func (m *matcher) chain(chain string) bool {
+	return len(m.chains) == 0 || m.chains[chain]
+}
+
+func (m *matcher) match(e Entry) bool {
+	if level, err := logging.ToLevel(e.Level); err == nil && level > m.level {
+		return false
+	}
+	if !m.since.IsZero() && e.Time.Before(m.since) {
+		return false
+	}
+	return m.pattern == nil || m.pattern.MatchString(e.Message)
+}
+
+// Read returns the entries of the logs of [sources] selected by [f], ordered
+// by time. Entries logged within the same second are ordered by node and
+// chain.
+func Read(sources []Source, f Filter) ([]Entry, error) {
+	m, err := f.compile()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, s := range sources {
+		if !m.source(s) {
+			continue
+		}
+		files, err := logFiles(s.Dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !m.chain(file.chain) {
+				continue
+			}
+			for _, path := range file.rotated {
+				if entries, _, err = readFile(entries, s, file.chain, path, 0, true, m); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// ParseSince parses [s], either a time in RFC 3339 format or a duration
+// before [now] such as 5m
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (expecting a duration such as 5m or a time such as 2006-01-02T15:04:05Z)", s)
+	}
+	return t, nil
+}
+
+// Tail returns the last [n] of [entries], or all of them if [n] is not
+// positive
+func Tail(entries []Entry, n int) []Entry {
+	if n <= 0 || n >= len(entries) {
+		return entries
+	}
+	return entries[len(entries)-n:]
+}
+
+// Follow calls [fn] with the entries of the logs of [sources] selected by
+// [f] as the nodes write them, checking the logs every [interval] until
+// [ctx] is done or [fn] fails. Entries written before Follow is called are
+// skipped.
+func Follow(ctx context.Context, sources []Source, f Filter, interval time.Duration, fn func(Entry) error) error {
+	m, err := f.compile()
+	if err != nil {
+		return err
+	}
+	// Log files being written, by path, and the offsets they were read up
+	// to. Files that did not exist yet are read from their start once they
+	// are created.
+	followed := make(map[string]followedFile)
+	poll := func(initial bool) error {
+		var entries []Entry
+		for _, s := range sources {
+			if !m.source(s) {
+				continue
+			}
+			files, err := logFiles(s.Dir)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if !m.chain(file.chain) {
+					continue
+				}
+				path := file.rotated[len(file.rotated)-1]
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				if initial {
+					followed[path] = followedFile{info: info, offset: info.Size()}
+					continue
+				}
+				var offset int64
+				if previous, ok := followed[path]; ok {
+					if os.SameFile(previous.info, info) {
+						// Unless the file was truncated
+						if info.Size() >= previous.offset {
+							offset = previous.offset
+						}
+					} else if rotated := rotatedFile(file, previous.info); len(rotated) > 0 {
+						// The file was rotated: the entries written to it
+						// since it was last read come first
+						if entries, _, err = readFile(entries, s, file.chain, rotated, previous.offset, true, m); err != nil {
+							return err
+						}
+					}
+				}
+				if info.Size() == offset {
+					followed[path] = followedFile{info: info, offset: offset}
+					continue
+				}
+				if entries, offset, err = readFile(entries, s, file.chain, path, offset, false, m); err != nil {
+					return err
+				}
+				followed[path] = followedFile{info: info, offset: offset}
+			}
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Time.Before(entries[j].Time)
+		})
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := poll(true); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		if err := poll(false); err != nil {
+			return err
+		}
+	}
+}
+
+// followedFile is a log file being written, read up to [offset]
+type followedFile struct {
+	info   os.FileInfo
+	offset int64
+}
+
+// rotatedFile returns the path [file] was rotated to, described by [info],
+// or an empty path if it is gone
+func rotatedFile(file logFile, info os.FileInfo) string {
+	for _, path := range file.rotated[:len(file.rotated)-1] {
+		if rotated, err := os.Stat(path); err == nil && os.SameFile(rotated, info) {
+			return path
+		}
+	}
+	return ""
+}
+
+// logFile is the log of [chain], written to the last of [rotated], which
+// holds the older rotated files first
+type logFile struct {
+	chain   string
+	rotated []string
+}
+
+// logFiles returns the log files in [dir] but the HTTP logs, ordered by
+// chain
+func logFiles(dir string) ([]logFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rotations := make(map[string]map[int]string)
+	for _, info := range infos {
+		name := info.Name()
+		i := strings.Index(name, ".log")
+		if i <= 0 || info.IsDir() || strings.HasSuffix(name[:i], ".http") || name == "http.log" || strings.HasPrefix(name, "http.log.") {
+			continue
+		}
+		rotation := 0
+		if suffix := name[i+len(".log"):]; len(suffix) > 0 {
+			n, err := strconv.Atoi(strings.TrimPrefix(suffix, "."))
+			if err != nil || !strings.HasPrefix(suffix, ".") || n < 1 {
+				continue
+			}
+			rotation = n
+		}
+		chain := name[:i]
+		if rotations[chain] == nil {
+			rotations[chain] = make(map[int]string)
+		}
+		rotations[chain][rotation] = filepath.Join(dir, name)
+	}
+	files := make([]logFile, 0, len(rotations))
+	for chain, paths := range rotations {
+		file := logFile{chain: chain}
+		if _, ok := paths[0]; !ok {
+			// The current log file is read once it is created
+			paths[0] = filepath.Join(dir, chain+".log")
+		}
+		indices := make([]int, 0, len(paths))
+		for i := range paths {
+			indices = append(indices, i)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(indices)))
+		for _, i := range indices {
+			file.rotated = append(file.rotated, paths[i])
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return chainOrder(files[i].chain) < chainOrder(files[j].chain)
+	})
+	return files, nil
+}
+
+// chainOrder sorts the main log first, then the primary network chains and
+// then the other blockchains by ID
+func chainOrder(chain string) string {
+	switch chain {
+	case MainChain:
+		return "0"
+	case "P":
+		return "1"
+	case "C":
+		return "2"
+	case "X":
+		return "3"
+	}
+	return "4" + chain
+}
+
+// readFile appends the entries of [chain] in the log file at [path] of [s]
+// from [offset] that match [m] to [entries], returning the offset it read the
+// file up to. An incomplete last line is read as is if the file is [final]
+// (no longer written to, or read once), and left to be read once it is
+// complete otherwise.
+func readFile(entries []Entry, s Source, chain string, path string, offset int64, final bool, m *matcher) ([]Entry, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, offset, nil
+	}
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+
+	var (
+		reader  = bufio.NewReader(f)
+		current *Entry
+	)
+	flush := func() {
+		if current != nil && m.match(*current) {
+			entries = append(entries, *current)
+		}
+		current = nil
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && (!final || len(line) == 0) {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, offset, err
+		}
+		offset += int64(len(line))
+		line = colorCode.ReplaceAllString(strings.TrimRight(line, "\r\n"), "")
+		match := entryStart.FindStringSubmatch(line)
+		if match == nil {
+			// Lines that do not start an entry continue the previous one
+			if current != nil {
+				current.Message += "\n" + line
+			}
+			continue
+		}
+		flush()
+		current = &Entry{
+			Node:    s.Node,
+			ID:      s.ID,
+			Chain:   chain,
+			Level:   match[1],
+			Time:    parseTime(match[2], info.ModTime()),
+			Message: match[3],
+		}
+	}
+	flush()
+	return entries, offset, nil
+}
+
+// parseTime parses the timestamp [s] of an entry of a log file last modified
+// at [modTime]
+func parseTime(s string, modTime time.Time) time.Time {
+	t, err := time.ParseInLocation(timeFormat, s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	t = t.AddDate(modTime.Year(), 0, 0)
+	// Entries cannot be logged after the file was last modified, unless the
+	// year changed since
+	if t.After(modTime.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
@@ -0,0 +1,263 @@
+package logs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntryStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		level   string
+		time    string
+		message string
+	}{
+		{"aligned level", "WARN [10-18|01:07:31] <P Chain> vms/platformvm/vm.go#226: msg", "WARN", "10-18|01:07:31", "<P Chain> vms/platformvm/vm.go#226: msg"},
+		{"long level", "FATAL[10-18|01:07:31] node/node.go#12: crashed", "FATAL", "10-18|01:07:31", "node/node.go#12: crashed"},
+		{"empty message", "INFO [01-02|15:04:05]", "INFO", "01-02|15:04:05", ""},
+		{"stack trace", "goroutine 1 [running]:", "", "", ""},
+		{"indented line", "	/go/src/main.go:10 +0x20", "", "", ""},
+		{"lowercase level", "warn [10-18|01:07:31] msg", "", "", ""},
+		{"timestamp with year", "INFO [2021-10-18|01:07:31] msg", "", "", ""},
+		{"no level", "[10-18|01:07:31] msg", "", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match := entryStart.FindStringSubmatch(test.line)
+			if len(test.level) == 0 {
+				if match != nil {
+					t.Fatalf("expected %q not to start an entry but got %q", test.line, match)
+				}
+				return
+			}
+			if match == nil {
+				t.Fatalf("expected %q to start an entry", test.line)
+			}
+			if match[1] != test.level || match[2] != test.time || match[3] != test.message {
+				t.Fatalf("expected %s, %s and %q but got %s, %s and %q", test.level, test.time, test.message, match[1], match[2], match[3])
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2021, 10, 18, 2, 0, 0, 0, time.Local)
+	files := map[string]string{
+		"main.log": "INFO [10-18|01:00:00] node/node.go#1: starting\n" +
+			"\x1b[31mERROR[10-18|01:00:03] node/node.go#2: panic\x1b[0m\n" +
+			"goroutine 1 [running]:\n" +
+			"\tmain.go:10 +0x20\n" +
+			"INFO [10-18|01:00:05] node/node.go#3: last line",
+		"P.log.1":  "DEBUG[10-18|01:00:01] <P Chain> rotated\n",
+		"P.log":    "WARN [10-18|01:00:02] <P Chain> current\n",
+		"http.log": "INFO [10-18|01:00:01] http request\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sources := []Source{{Node: "node1", ID: "NodeID-1", Dir: dir}}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		messages []string
+	}{
+		{
+			// The last line is read even without a trailing newline
+			name: "all",
+			messages: []string{
+				"node/node.go#1: starting",
+				"<P Chain> rotated",
+				"<P Chain> current",
+				"node/node.go#2: panic\ngoroutine 1 [running]:\n\tmain.go:10 +0x20",
+				"node/node.go#3: last line",
+			},
+		},
+		{
+			name:     "level",
+			filter:   Filter{Level: "warn"},
+			messages: []string{"<P Chain> current", "node/node.go#2: panic\ngoroutine 1 [running]:\n\tmain.go:10 +0x20"},
+		},
+		{
+			name:     "chain",
+			filter:   Filter{Chains: []string{"P"}},
+			messages: []string{"<P Chain> rotated", "<P Chain> current"},
+		},
+		{
+			name:     "pattern in a continuation line",
+			filter:   Filter{Pattern: "goroutine"},
+			messages: []string{"node/node.go#2: panic\ngoroutine 1 [running]:\n\tmain.go:10 +0x20"},
+		},
+		{
+			name:     "since",
+			filter:   Filter{Since: time.Date(2021, 10, 18, 1, 0, 2, 0, time.Local)},
+			messages: []string{"<P Chain> current", "node/node.go#2: panic\ngoroutine 1 [running]:\n\tmain.go:10 +0x20", "node/node.go#3: last line"},
+		},
+		{
+			name:     "other node",
+			filter:   Filter{Nodes: []string{"node2"}},
+			messages: nil,
+		},
+		{
+			name:     "node ID",
+			filter:   Filter{Nodes: []string{"NodeID-1"}, Chains: []string{MainChain}},
+			messages: []string{"node/node.go#1: starting", "node/node.go#2: panic\ngoroutine 1 [running]:\n\tmain.go:10 +0x20", "node/node.go#3: last line"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := Read(sources, test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := make([]string, len(entries))
+			for i, e := range entries {
+				messages[i] = e.Message
+				if e.Node != "node1" || e.ID != "NodeID-1" || e.Time.Year() != 2021 {
+					t.Fatalf("unexpected entry %+v", e)
+				}
+			}
+			if strings.Join(messages, "|") != strings.Join(test.messages, "|") {
+				t.Fatalf("expected %q but got %q", test.messages, messages)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s        string
+		expected time.Time
+		err      bool
+	}{
+		{s: "5m", expected: now.Add(-5 * time.Minute)},
+		{s: "1h30m", expected: now.Add(-90 * time.Minute)},
+		{s: "0s", expected: now},
+		{s: "2021-10-18T11:00:00Z", expected: time.Date(2021, 10, 18, 11, 0, 0, 0, time.UTC)},
+		{s: "2021-10-18T13:00:00+02:00", expected: time.Date(2021, 10, 18, 11, 0, 0, 0, time.UTC)},
+		{s: "5", err: true},
+		{s: "yesterday", err: true},
+		{s: "2021-10-18", err: true},
+		{s: "", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			since, err := ParseSince(test.s, now)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error but got %s", since)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !since.Equal(test.expected) {
+				t.Fatalf("expected %s but got %s", test.expected, since)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		modTime  time.Time
+		expected time.Time
+	}{
+		{"same year", "10-18|01:07:31", time.Date(2021, 10, 18, 2, 0, 0, 0, time.Local), time.Date(2021, 10, 18, 1, 7, 31, 0, time.Local)},
+		{"logged last year", "12-31|23:59:59", time.Date(2022, 1, 1, 0, 0, 5, 0, time.Local), time.Date(2021, 12, 31, 23, 59, 59, 0, time.Local)},
+		{"invalid", "10-18 01:07:31", time.Date(2021, 10, 18, 2, 0, 0, 0, time.Local), time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if parsed := parseTime(test.s, test.modTime); !parsed.Equal(test.expected) {
+				t.Fatalf("expected %s but got %s", test.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestFollowRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "P.log")
+	appendLine := func(path string, line string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appendLine(path, "INFO [10-18|01:00:00] <P Chain> before\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan Entry, 10)
+	go func() {
+		sources := []Source{{Node: "node1", ID: "NodeID-1", Dir: dir}}
+		_ = Follow(ctx, sources, Filter{}, 50*time.Millisecond, func(e Entry) error {
+			received <- e
+			return nil
+		})
+	}()
+	next := func() string {
+		select {
+		case e := <-received:
+			return e.Message
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected an entry")
+			return ""
+		}
+	}
+
+	// Entries are written until Follow reports one, right after it polled
+	// the logs
+	for done := false; !done; {
+		appendLine(path, "INFO [10-18|01:00:01] <P Chain> marker\n")
+		select {
+		case e := <-received:
+			if e.Message != "<P Chain> marker" {
+				t.Fatalf("expected a marker but got %q", e.Message)
+			}
+			done = true
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	// Drain the other markers
+	time.Sleep(100 * time.Millisecond)
+	for len(received) > 0 {
+		<-received
+	}
+
+	// The last entries of the file, including an incomplete line, are
+	// written right before it is rotated
+	appendLine(path, "INFO [10-18|01:00:10] <P Chain> rotated\nINFO [10-18|01:00:11] <P Chain> incomplete")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLine(path, "INFO [10-18|01:00:12] <P Chain> current\n")
+
+	for _, expected := range []string{"<P Chain> rotated", "<P Chain> incomplete", "<P Chain> current"} {
+		if message := next(); message != expected {
+			t.Fatalf("expected %q but got %q", expected, message)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/manager"
+
+	"github.com/fatih/color"
+)
+
+func accountsCmd(args []string) error {
+	fs, dataDir := newFlagSet("accounts", "Print the private keys and addresses, in the Avalanche and Ethereum formats, of the test accounts funded on a network (see start --accounts)")
+	jsonOutput := fs.Bool("json", false, "print the accounts as JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	state, err := manager.LoadState(*dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", *dataDir, err)
+	}
+	accts, err := state.FundedAccounts()
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		if accts == nil {
+			accts = []accounts.Account{}
+		}
+		return printJSON(accts)
+	}
+	if len(accts) == 0 {
+		color.Yellow("no test accounts were funded on the network in %s (see start --accounts)", *dataDir)
+		return nil
+	}
+	color.Green("%d test accounts funded with %d nAVAX on each chain:", len(accts), state.Accounts.Balance)
+	printAccounts(accts)
+	return nil
+}
+
+// printAccounts prints the keys and addresses of [accts]
+func printAccounts(accts []accounts.Account) {
+	for _, a := range accts {
+		color.Green("account %d: %s %s", a.Index, a.PrivateKey, a.EthPrivateKey)
+		color.Green("  %s %s %s %s", a.XAddress, a.PAddress, a.CAddress, a.EthAddress)
+	}
+}
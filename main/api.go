@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ava-labs/ava-sim/manager"
+)
+
+// callAPI sends a [method] request for [path] to the control API of the
+// network running in [dataDir], with [req] as the JSON body if it is not nil,
+// and decodes the response into [res] if it is not nil
+func callAPI(ctx context.Context, dataDir string, method string, path string, req interface{}, res interface{}) error {
+	state, err := manager.LoadState(dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", dataDir, err)
+	}
+	if !state.Running() {
+		return fmt.Errorf("network in %s is not running", dataDir)
+	}
+	if len(state.API) == 0 {
+		return fmt.Errorf("network in %s has no control API", dataDir)
+	}
+	token, err := manager.LoadAPIToken(dataDir)
+	if err != nil {
+		return fmt.Errorf("could not read control API token of network in %s: %w", dataDir, err)
+	}
+
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, state.API+path, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("could not reach ava-sim (pid %d): %w", state.PID, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		apiErr := struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.NewDecoder(response.Body).Decode(&apiErr); err != nil || len(apiErr.Error) == 0 {
+			return fmt.Errorf("%s %s failed with status %s", method, path, response.Status)
+		}
+		return errors.New(apiErr.Error)
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(res)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+
+	"github.com/fatih/color"
+)
+
+// diagnosticsFile is the name of the diagnostics tarball written to the data
+// dir by default
+const diagnosticsFile = "diagnostics.tar.gz"
+
+func diagnoseCmd(args []string) error {
+	fs, dataDir := newFlagSet("diagnose", "Archive the logs, flags and record (subnets, blockchains and txs) of a network, and snapshots of the health, info, readiness and validators of its nodes if it is running, to a tarball to attach to a bug report")
+	output := fs.String("output", "", "file to write the tarball to (defaults to "+diagnosticsFile+" in --data-dir)")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	path := diagnosticsPath(*dataDir, *output)
+	if err := manager.SaveDiagnostics(*dataDir, path, nil); err != nil {
+		return err
+	}
+	color.Green("diagnostics written to %s", path)
+	return nil
+}
+
+// diagnosticsPath returns [path], or the default path of the diagnostics of
+// the network in [dataDir] if it is empty
+func diagnosticsPath(dataDir string, path string) string {
+	if len(path) > 0 {
+		return path
+	}
+	return filepath.Join(dataDir, diagnosticsFile)
+}
+
+// saveDiagnostics archives the diagnostics of [n], which failed with
+// [failure], to [path]. Errors are printed as the failure is the error that
+// matters.
+func saveDiagnostics(n *network.Network, path string, failure error) {
+	if err := n.SaveDiagnostics(path, failure); err != nil {
+		color.Red("could not save diagnostics: %v", err)
+		return
+	}
+	color.Yellow("diagnostics written to %s", path)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ava-labs/ava-sim/control"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/proxy"
+
+	"github.com/fatih/color"
+)
+
+const faultUsage = `Inject faults on the links between the nodes of a running network started
+with --faults
+
+Usage:
+  ava-sim fault <command> [flags] [args]
+
+Commands:
+  list       list the faults injected between nodes
+  set        inject latency, packet loss or bandwidth limits between nodes
+  partition  partition the nodes into groups, e.g. "node1,node2 node3"
+  heal       remove all faults, including partitions
+`
+
+func faultCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, faultUsage)
+		os.Exit(exitUsage)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "list":
+		return faultListCmd(args)
+	case "set":
+		return faultSetCmd(args)
+	case "partition":
+		return faultPartitionCmd(args)
+	case "heal":
+		return faultHealCmd(args)
+	case "help", "-h", "--help":
+		fmt.Print(faultUsage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown fault command %q\n\n%s", cmd, faultUsage)
+		os.Exit(exitUsage)
+	}
+	return nil
+}
+
+func faultListCmd(args []string) error {
+	fs, dataDir := newFlagSet("fault list", "List the faults injected between the nodes of a running network")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	var faults []manager.LinkFaults
+	if err := callAPI(context.Background(), *dataDir, http.MethodGet, "/v1/faults", nil, &faults); err != nil {
+		return err
+	}
+	printFaults(faults)
+	return nil
+}
+
+func faultSetCmd(args []string) error {
+	fs, dataDir := newFlagSet("fault set", "Inject faults on the links between nodes of a running network, in both directions, replacing the faults injected on them before")
+	nodes := fs.String("nodes", "", "comma-separated names of the nodes on one end of the links (defaults to all nodes)")
+	peers := fs.String("peers", "", "comma-separated names of the nodes on the other end of the links (defaults to all nodes)")
+	latency := fs.Duration("latency", 0, "time data takes to cross the links")
+	jitter := fs.Duration("jitter", 0, "maximum random delay added to --latency")
+	dropRate := fs.Float64("drop-rate", 0, "probability that a packet is lost and has to be retransmitted")
+	bandwidth := fs.Int("bandwidth", 0, "maximum rate of data sent in each direction in bytes per second (unlimited if 0)")
+	partitioned := fs.Bool("partitioned", false, "cut the links until they are healed")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	req := control.FaultsRequest{
+		Faults: proxy.Faults{
+			Latency:     *latency,
+			Jitter:      *jitter,
+			DropRate:    *dropRate,
+			Bandwidth:   *bandwidth,
+			Partitioned: *partitioned,
+		},
+	}
+	if err := req.Faults.Verify(); err != nil {
+		return err
+	}
+	if len(*nodes) > 0 {
+		req.Nodes = strings.Split(*nodes, ",")
+	}
+	if len(*peers) > 0 {
+		req.Peers = strings.Split(*peers, ",")
+	}
+
+	var faults []manager.LinkFaults
+	if err := callAPI(context.Background(), *dataDir, http.MethodPost, "/v1/faults", req, &faults); err != nil {
+		return err
+	}
+	printFaults(faults)
+	return nil
+}
+
+func faultPartitionCmd(args []string) error {
+	fs, dataDir := newFlagSet("fault partition", "Cut the links between nodes of different groups of a running network until they are healed. Each argument is a group of comma-separated node names.")
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 {
+		return fmt.Errorf("expecting at least 2 groups of nodes (e.g. node1,node2 node3) but got %v", fs.Args())
+	}
+	req := control.PartitionRequest{}
+	for _, group := range fs.Args() {
+		req.Groups = append(req.Groups, strings.Split(group, ","))
+	}
+
+	var faults []manager.LinkFaults
+	if err := callAPI(context.Background(), *dataDir, http.MethodPost, "/v1/partition", req, &faults); err != nil {
+		return err
+	}
+	printFaults(faults)
+	return nil
+}
+
+func faultHealCmd(args []string) error {
+	fs, dataDir := newFlagSet("fault heal", "Remove all faults injected between the nodes of a running network, including partitions")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	var faults []manager.LinkFaults
+	if err := callAPI(context.Background(), *dataDir, http.MethodDelete, "/v1/faults", nil, &faults); err != nil {
+		return err
+	}
+	printFaults(faults)
+	return nil
+}
+
+// printFaults prints the faults injected between each pair of nodes
+func printFaults(faults []manager.LinkFaults) {
+	if len(faults) == 0 {
+		color.Green("no faults injected")
+		return
+	}
+	for _, f := range faults {
+		color.Yellow("%s <-> %s: %s", f.Nodes[0], f.Nodes[1], f.Faults)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/ava-sim/control"
+	"github.com/ava-labs/ava-sim/load"
+	"github.com/ava-labs/ava-sim/manager"
+
+	"github.com/fatih/color"
+)
+
+func loadCmd(args []string) error {
+	fs, dataDir := newFlagSet("load", "Issue txs at a steady rate on the chains of a running network, spread across its running nodes, and report the throughput, acceptance latency and error rate of each chain. Txs are issued from accounts funded by the genesis key, one per node and chain.")
+	chains := fs.String("chains", load.XChain+","+load.CChain, "comma-separated chains to issue txs on: X (AVAX transfers), C, or the name or ID of a Subnet-EVM blockchain (value transfers and contract calls)")
+	rate := fs.Float64("rate", load.DefaultRate, "txs issued per second on each chain")
+	callRatio := fs.Float64("call-ratio", load.DefaultCallRatio, "fraction of the txs issued on EVM chains that call a contract rather than transfer value")
+	duration := fs.Duration("duration", 0, "how long to issue txs for (until interrupted if 0)")
+	txTimeout := fs.Duration("tx-timeout", load.DefaultTxTimeout, "time after which a tx that is not accepted counts as failed")
+	reportInterval := fs.Duration("report-interval", load.DefaultReportInterval, "how often to print the report of the run so far")
+	jsonOutput := fs.Bool("json", false, "print the final report as JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	state, err := manager.LoadState(*dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", *dataDir, err)
+	}
+	var statuses []control.NodeStatus
+	if err := callAPI(context.Background(), *dataDir, http.MethodGet, "/v1/nodes", nil, &statuses); err != nil {
+		return err
+	}
+	config := load.Config{
+		Rate:           *rate,
+		CallRatio:      *callRatio,
+		TxTimeout:      *txTimeout,
+		Duration:       *duration,
+		ReportInterval: *reportInterval,
+		OnReport: func(r load.Report) {
+			for _, c := range r.Chains {
+				color.Yellow("%s", c)
+			}
+		},
+	}
+	for _, s := range statuses {
+		if s.Running && !s.Paused {
+			config.URLs = append(config.URLs, s.URL)
+		}
+	}
+	for _, chain := range splitList(*chains) {
+		if chain != load.XChain && chain != load.CChain {
+			chain = state.ChainID(chain)
+		}
+		config.Chains = append(config.Chains, chain)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	r, err := load.Run(ctx, config)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return printJSON(r)
+	}
+	color.Green("issued txs for %s", time.Duration(r.Elapsed*float64(time.Second)).Round(time.Second))
+	for _, c := range r.Chains {
+		color.Green("%s", c)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/ava-sim/logs"
+	"github.com/ava-labs/ava-sim/manager"
+
+	"github.com/fatih/color"
+)
+
+// logPollInterval is how often the logs are checked for new entries with
+// --follow
+const logPollInterval = 500 * time.Millisecond
+
+func logsCmd(args []string) error {
+	fs, dataDir := newFlagSet("logs", "Print the logs of the nodes and chains of a network merged by time, each entry prefixed with its node, node ID and chain. The logs are read from the data directory, so that they can be searched once the network stopped.")
+	nodes := fs.String("node", "", "comma-separated names (e.g. node1) or IDs of the nodes to print the logs of")
+	chains := fs.String("chain", "", "comma-separated chains to print the logs of: P, C, X, main (the node itself), or the name or ID of a subnet blockchain")
+	level := fs.String("level", "", "least severe level of the entries to print, e.g. warn for warnings and errors")
+	pattern := fs.String("grep", "", "regular expression the entries must match")
+	since := fs.String("since", "", "print entries logged since this time (e.g. 2006-01-02T15:04:05Z) or for this long (e.g. 5m)")
+	tail := fs.Int("tail", 0, "print the last N entries only")
+	follow := fs.Bool("follow", false, "keep printing entries as the nodes log them")
+	jsonOutput := fs.Bool("json", false, "print one JSON entry per line")
+	export := fs.String("export", "", "write the entries, ordered by time, to this file instead of printing them")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *follow && len(*export) > 0 {
+		return fmt.Errorf("--follow cannot be used with --export")
+	}
+
+	state, err := manager.LoadState(*dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", *dataDir, err)
+	}
+	f := logs.Filter{
+		Nodes:   splitList(*nodes),
+		Level:   *level,
+		Pattern: *pattern,
+	}
+	for _, chain := range splitList(*chains) {
+		f.Chains = append(f.Chains, state.ChainID(chain))
+	}
+	if len(*since) > 0 {
+		if f.Since, err = logs.ParseSince(*since, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	sources := state.LogSources()
+	entries, err := logs.Read(sources, f)
+	if err != nil {
+		return err
+	}
+	entries = logs.Tail(entries, *tail)
+
+	if len(*export) > 0 {
+		file, err := os.Create(*export)
+		if err != nil {
+			return fmt.Errorf("could not export logs: %w", err)
+		}
+		w := bufio.NewWriter(file)
+		for _, e := range entries {
+			if err := writeEntry(w, e, *jsonOutput); err != nil {
+				file.Close()
+				return fmt.Errorf("could not export logs: %w", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			file.Close()
+			return fmt.Errorf("could not export logs: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("could not export logs: %w", err)
+		}
+		color.Green("exported %d log entries to %s", len(entries), *export)
+		return nil
+	}
+
+	output := func(e logs.Entry) error {
+		if *jsonOutput {
+			return writeEntry(os.Stdout, e, true)
+		}
+		printEntry(e)
+		return nil
+	}
+	for _, e := range entries {
+		if err := output(e); err != nil {
+			return err
+		}
+	}
+	if !*follow {
+		return nil
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	return logs.Follow(ctx, sources, f, logPollInterval, output)
+}
+
+// writeEntry writes [e] to [w] as a line of text, or of JSON if [asJSON]
+func writeEntry(w io.Writer, e logs.Entry, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(e)
+	}
+	_, err := fmt.Fprintln(w, e)
+	return err
+}
+
+// printEntry prints [e] colored by its level
+func printEntry(e logs.Entry) {
+	switch e.Level {
+	case "FATAL", "ERROR":
+		color.Red("%s", e)
+	case "WARN":
+		color.Yellow("%s", e)
+	default:
+		fmt.Println(e)
+	}
+}
+
+// splitList returns the elements of the comma-separated list [s]
+func splitList(s string) []string {
+	var list []string
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); len(elem) > 0 {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
@@ -1,80 +1,159 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"os/signal"
 	"path"
-	"syscall"
+	"path/filepath"
+	"strings"
 
+	"github.com/ava-labs/ava-sim/health"
 	"github.com/ava-labs/ava-sim/manager"
-	"github.com/ava-labs/ava-sim/runner"
+
 	"github.com/fatih/color"
-	"golang.org/x/sync/errgroup"
+)
+
+const usage = `ava-sim spins up a local Avalanche network
+
+Usage:
+  ava-sim <command> [flags]
+
+Commands:
+  start          start a local network, optionally running a custom VM
+  subnet create  deploy the custom VM of a running network on a new subnet
+  node           list, stop, start, restart, kill, pause and resume the nodes of a running network
+  fault          inject latency, packet loss and partitions between nodes
+  snapshot       save, load and list snapshots of the state of a network
+  status         print the readiness of a running network
+  logs           print, follow and search the logs of the nodes
+  diagnose       archive the logs and state of a network for a bug report
+  load           issue txs on the X, C and Subnet-EVM chains of a running network
+  accounts       print the keys and addresses of the test accounts of a network
+  stop           stop a running network
+  upgrade        restart the nodes of a running network with another avalanchego
+
+Run "ava-sim <command> --help" for more information about a command.
+
+Exit codes:
+  0  the command succeeded or the network was stopped (e.g. with Ctrl-C)
+  1  the command failed, e.g. the network could not be set up
+  2  invalid command or flags
+  3  the network stopped as a node failed
+`
+
+// Exit codes of ava-sim
+const (
+	exitFailure   = 1
+	exitUsage     = 2
+	exitNodeCrash = 3
 )
 
 func main() {
-	var vm, vmGenesis string
-	switch len(os.Args) {
-	case 1: // normal network
-	case 3:
-		vm = path.Clean(os.Args[1])
-		if _, err := os.Stat(vm); os.IsNotExist(err) {
-			panic(fmt.Sprintf("%s does not exist", vm))
-		}
-		color.Yellow("vm set to: %s", vm)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
 
-		vmGenesis = path.Clean(os.Args[2])
-		if _, err := os.Stat(vmGenesis); os.IsNotExist(err) {
-			panic(fmt.Sprintf("%s does not exist", vmGenesis))
-		}
-		color.Yellow("vm-genesis set to: %s", vmGenesis)
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "start":
+		err = startCmd(args)
+	case "subnet":
+		err = subnetCmd(args)
+	case "node":
+		err = nodeCmd(args)
+	case "fault":
+		err = faultCmd(args)
+	case "snapshot":
+		err = snapshotCmd(args)
+	case "status":
+		err = statusCmd(args)
+	case "logs":
+		err = logsCmd(args)
+	case "diagnose":
+		err = diagnoseCmd(args)
+	case "load":
+		err = loadCmd(args)
+	case "accounts":
+		err = accountsCmd(args)
+	case "stop":
+		err = stopCmd(args)
+	case "upgrade":
+		err = upgradeCmd(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
 	default:
-		panic("invalid arguments (expecting no arguments or [vm] [vm-genesis])")
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(exitUsage)
 	}
-
-	// Start local network
-	bootstrapped := make(chan struct{})
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	g, gctx := errgroup.WithContext(ctx)
-	g.Go(func() error {
-		// register signals to kill the application
-		signals := make(chan os.Signal, 1)
-		signal.Notify(signals, syscall.SIGINT)
-		signal.Notify(signals, syscall.SIGTERM)
-		defer func() {
-			// shut down the signal go routine
-			signal.Stop(signals)
-			close(signals)
-		}()
-
-		select {
-		case sig := <-signals:
-			color.Red("signal received: %v", sig)
-			cancel()
-		case <-gctx.Done():
-		}
-		return nil
-	})
-
-	g.Go(func() error {
-		return manager.StartNetwork(gctx, vm, bootstrapped)
-	})
-
-	// Only setup network if a custom VM is provided and the network has finished
-	// bootstrapping
-	select {
-	case <-bootstrapped:
-		if len(vm) > 0 && gctx.Err() == nil {
-			g.Go(func() error {
-				return runner.SetupSubnet(gctx, vmGenesis)
-			})
+	if err != nil {
+		color.Red("ava-sim exited with error: %s", err)
+		var nodeErr *manager.NodeError
+		if errors.As(err, &nodeErr) {
+			os.Exit(exitNodeCrash)
 		}
-	case <-gctx.Done():
+		os.Exit(exitFailure)
 	}
+}
 
-	color.Red("ava-sim exited with error: %s", g.Wait())
-	os.Exit(1)
+// File in the temporary directory recording the data dir of the network
+// started last, which commands manage unless --data-dir is set
+var lastDataDirFile = filepath.Join(os.TempDir(), "ava-sim-last")
+
+// newFlagSet returns a flag set for [cmd] with the flags shared by all
+// commands
+func newFlagSet(cmd, description string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\nUsage:\n  ava-sim %s [flags]\n\nFlags:\n", description, cmd)
+		fs.PrintDefaults()
+	}
+	dataDir := fs.String("data-dir", lastDataDir(), "directory holding the network's node DBs, logs and plugins (defaults to the one of the network started last)")
+	return fs, dataDir
+}
+
+// lastDataDir returns the data dir of the network started last, or
+// $TMPDIR/ava-sim if no network was started
+func lastDataDir() string {
+	b, err := ioutil.ReadFile(lastDataDirFile)
+	if dir := strings.TrimSpace(string(b)); err == nil && len(dir) > 0 {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "ava-sim")
+}
+
+// recordLastDataDir records [dir] as the data dir of the network started
+// last
+func recordLastDataDir(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(lastDataDirFile, []byte(dir+"\n"), 0644)
+}
+
+// timeoutFlags adds the flags setting the deadlines of the phases of the
+// setup of a network to [fs], returning the deadlines they set
+func timeoutFlags(fs *flag.FlagSet) *health.Timeouts {
+	t := health.DefaultTimeouts
+	fs.DurationVar(&t.NetworkBootstrap, "bootstrap-timeout", t.NetworkBootstrap, "deadline for the nodes to bootstrap and connect to each other, on startup and whenever nodes restart")
+	fs.DurationVar(&t.SubnetCreation, "subnet-timeout", t.SubnetCreation, "deadline for the creation of a subnet")
+	fs.DurationVar(&t.ValidatorAddition, "validator-timeout", t.ValidatorAddition, "deadline for the addition of the validators of a subnet")
+	fs.DurationVar(&t.ChainBootstrap, "chain-timeout", t.ChainBootstrap, "deadline for the blockchains of a subnet to be created and bootstrapped by its validators")
+	fs.DurationVar(&t.AccountFunding, "accounts-timeout", t.AccountFunding, "deadline for the test accounts to be funded on the X, P and C chains")
+	return &t
+}
+
+// checkFile returns the cleaned [file] if it exists
+func checkFile(name, file string) (string, error) {
+	file = path.Clean(file)
+	if _, err := os.Stat(file); err != nil {
+		return "", fmt.Errorf("invalid --%s: %w", name, err)
+	}
+	color.Yellow("%s set to: %s", name, file)
+	return file, nil
 }
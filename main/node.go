@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ava-labs/ava-sim/control"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+	"github.com/ava-labs/ava-sim/runner"
+
+	"github.com/fatih/color"
+)
+
+const nodeUsage = `Manage the nodes of a running network
+
+Usage:
+  ava-sim node <command> [flags] [name]
+
+Commands:
+  list     list the nodes and their status
+  add      add a node, optionally as a validator
+  remove   stop a node for good
+  stop     stop a node gracefully
+  start    start a stopped, killed or crashed node
+  restart  restart a node with the same staking key and DB
+  kill     kill a node and its VM plugins as if it crashed
+  pause    freeze a node and its VM plugins as if it hung
+  resume   resume a paused node
+
+Nodes run in the ava-sim process unless the network is started with
+--avalanchego-path (or avalanchegoPath in its spec). Such nodes share their
+VM plugin processes: they cannot be stopped, killed or paused, and restarting
+one of them restarts all of them. Start the network with --avalanchego-path
+[binary] to stop or restart a single node while the rest of the network keeps
+running.
+`
+
+func nodeCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, nodeUsage)
+		os.Exit(exitUsage)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "list":
+		return nodeListCmd(args)
+	case "add":
+		return nodeAddCmd(args)
+	case "remove", "stop", "start", "restart", "kill", "pause", "resume":
+		return nodeActionCmd(cmd, args)
+	case "help", "-h", "--help":
+		fmt.Print(nodeUsage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown node command %q\n\n%s", cmd, nodeUsage)
+		os.Exit(exitUsage)
+	}
+	return nil
+}
+
+func nodeListCmd(args []string) error {
+	fs, dataDir := newFlagSet("node list", "List the nodes of a running network and their status")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	var statuses []control.NodeStatus
+	if err := callAPI(context.Background(), *dataDir, http.MethodGet, "/v1/nodes", nil, &statuses); err != nil {
+		return err
+	}
+	printNodes(statuses)
+	return nil
+}
+
+func nodeAddCmd(args []string) error {
+	fs, dataDir := newFlagSet("node add", "Add a node with a fresh staking key to a running network")
+	validator := fs.Bool("validator", false, "add the node as a validator of the primary network")
+	subnets := fs.String("subnets", "", "comma-separated IDs or names of the subnets to add the node to as a validator (implies --validator)")
+	weight := fs.Uint64("weight", runner.DefaultValidatorWeight, "weight of the node on --subnets")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	req := network.NodeOptions{
+		Validator: *validator,
+		Weight:    *weight,
+	}
+	if len(*subnets) > 0 {
+		req.Subnets = strings.Split(*subnets, ",")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var added manager.NodeInfo
+	if err := callAPI(ctx, *dataDir, http.MethodPost, "/v1/nodes", req, &added); err != nil {
+		return err
+	}
+	color.Green("added %s (%s): %s", added.Name, added.ID, added.URL)
+	return nil
+}
+
+func nodeActionCmd(action string, args []string) error {
+	descriptions := map[string]string{
+		"remove":  "Stop a node of a running network for good, keeping its data dir (validators stay in the validator sets until their validation period ends)",
+		"stop":    "Stop a node of a running network gracefully (only nodes running an avalanchego binary)",
+		"start":   "Start a stopped, killed or crashed node of a running network",
+		"restart": "Restart a node of a running network with the same staking key and DB (restarts all nodes running in the ava-sim process if it is one of them)",
+		"kill":    "Kill a node of a running network and its VM plugins as if it crashed (only nodes running an avalanchego binary)",
+		"pause":   "Freeze a node of a running network and its VM plugins as if it hung, until it is resumed (only nodes running an avalanchego binary)",
+		"resume":  "Resume a paused node of a running network",
+	}
+	fs, dataDir := newFlagSet("node "+action, descriptions[action])
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expecting the name of a node (e.g. node2) but got %v", fs.Args())
+	}
+	name := fs.Arg(0)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	method, path := http.MethodPost, fmt.Sprintf("/v1/nodes/%s/%s", name, action)
+	if action == "remove" {
+		method, path = http.MethodDelete, fmt.Sprintf("/v1/nodes/%s", name)
+	}
+	var statuses []control.NodeStatus
+	if err := callAPI(ctx, *dataDir, method, path, nil, &statuses); err != nil {
+		return err
+	}
+	printNodes(statuses)
+	return nil
+}
+
+// printNodes prints the status of each node
+func printNodes(statuses []control.NodeStatus) {
+	for _, n := range statuses {
+		if n.Removed {
+			color.Yellow("%s (%s): removed", n.Name, n.ID)
+			continue
+		}
+		if !n.Running {
+			color.Yellow("%s (%s): stopped exitCode=%d", n.Name, n.ID, n.ExitCode)
+			continue
+		}
+		if n.Paused {
+			color.Yellow("%s (%s): %s pid=%d paused", n.Name, n.ID, n.URL, n.PID)
+			continue
+		}
+		avalanchego := n.AvalancheGoPath
+		if len(avalanchego) == 0 {
+			avalanchego = "in-process"
+		}
+		if n.Byzantine != nil {
+			color.Magenta("%s (%s): %s pid=%d avalanchego=%s bootstrapped=%t byzantine=%s", n.Name, n.ID, n.URL, n.PID, avalanchego, n.Bootstrapped, n.Byzantine)
+			continue
+		}
+		color.Green("%s (%s): %s pid=%d avalanchego=%s bootstrapped=%t", n.Name, n.ID, n.URL, n.PID, avalanchego, n.Bootstrapped)
+	}
+}
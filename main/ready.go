@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+)
+
+// readySignal is written as a single JSON line to --ready-file and --ready-fd
+// once the network and its subnets are set up, so that scripts can wait for
+// the network to be ready. It is not printed on stdout, where it would
+// interleave with the node logs.
+type readySignal struct {
+	Ready   bool   `json:"ready"`
+	PID     int    `json:"pid"`
+	DataDir string `json:"dataDir"`
+	API     string `json:"api"`
+	// Token to send to the control API as "Authorization: Bearer <token>"
+	APIToken string                `json:"apiToken"`
+	Nodes    []manager.NodeInfo    `json:"nodes"`
+	Subnets  []manager.SubnetState `json:"subnets,omitempty"`
+	// Test accounts funded on the network, with their keys
+	Accounts []accounts.Account `json:"accounts,omitempty"`
+}
+
+// openReadyFD returns the file of the inherited file descriptor [fd] the
+// ready signal is written to, or nil if [fd] is 0
+func openReadyFD(fd int) (*os.File, error) {
+	if fd == 0 {
+		return nil, nil
+	}
+	// Stdout and stderr carry the logs of ava-sim and the nodes
+	if fd < 3 {
+		return nil, fmt.Errorf("invalid --ready-fd %d: must be 3 or more", fd)
+	}
+	f := os.NewFile(uintptr(fd), "ready-fd")
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("invalid --ready-fd %d: %w", fd, err)
+	}
+	// The nodes must not inherit it and keep it open once it is closed here
+	syscall.CloseOnExec(fd)
+	return f, nil
+}
+
+// signalReady writes the ready signal of [n], served by the control API at
+// [api] with [token], to [readyFile] and [readyFD] if set. [readyFD] is closed
+// afterwards so that readers get EOF.
+func signalReady(n *network.Network, api string, token string, readyFile string, readyFD *os.File) error {
+	signal := readySignal{
+		Ready:    true,
+		PID:      os.Getpid(),
+		DataDir:  n.DataDir(),
+		API:      api,
+		APIToken: token,
+		Nodes:    n.Nodes(),
+	}
+	subnets, err := n.Subnets()
+	if err != nil {
+		return err
+	}
+	signal.Subnets = subnets
+	if signal.Accounts, err = n.Accounts(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(signal)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if readyFD != nil {
+		_, err := readyFD.Write(b)
+		readyFD.Close()
+		if err != nil {
+			return fmt.Errorf("could not write ready signal to --ready-fd: %w", err)
+		}
+	}
+	if len(readyFile) == 0 {
+		return nil
+	}
+
+	// Write the file atomically so that scripts never read it partially
+	tmp, err := ioutil.TempFile(filepath.Dir(readyFile), ".ready-")
+	if err != nil {
+		return fmt.Errorf("could not write ready file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write ready file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write ready file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), readyFile); err != nil {
+		return fmt.Errorf("could not write ready file: %w", err)
+	}
+	return nil
+}
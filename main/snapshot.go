@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ava-labs/ava-sim/manager"
+
+	"github.com/fatih/color"
+)
+
+const snapshotUsage = `Save and restore the state of a network
+
+Usage:
+  ava-sim snapshot <command> <name> [flags]
+
+Commands:
+  save  stop the network and archive the state of its nodes as <name>
+  load  restore the network archived as <name> (resume it with start)
+  list  list the saved snapshots
+`
+
+func snapshotCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, snapshotUsage)
+		os.Exit(exitUsage)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "save":
+		return snapshotSaveCmd(args)
+	case "load":
+		return snapshotLoadCmd(args)
+	case "list":
+		return snapshotListCmd(args)
+	case "help", "-h", "--help":
+		fmt.Print(snapshotUsage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown snapshot command %q\n\n%s", cmd, snapshotUsage)
+		os.Exit(exitUsage)
+	}
+	return nil
+}
+
+// parseSnapshotFlags parses the flags of the snapshot command [cmd] and
+// returns the data dir, the snapshot dir and the remaining arguments
+func parseSnapshotFlags(cmd, description string, args []string) (string, string, []string) {
+	fs, dataDir := newFlagSet("snapshot "+cmd, description)
+	snapshotDir := fs.String("snapshot-dir", filepath.Join(os.TempDir(), "ava-sim-snapshots"), "directory holding the saved snapshots")
+	// Allow the snapshot name to come before the flags
+	var positional []string
+	for len(args) > 0 {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+	return *dataDir, *snapshotDir, positional
+}
+
+// snapshotPath returns the path of the snapshot named [name] in [dir]
+func snapshotPath(dir, name string) (string, error) {
+	if len(name) == 0 || name != filepath.Base(name) || name[0] == '.' {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return filepath.Join(dir, name+".tar.gz"), nil
+}
+
+func snapshotSaveCmd(args []string) error {
+	dataDir, snapshotDir, names := parseSnapshotFlags("save", "Stop the network in --data-dir and archive the state of its nodes", args)
+	if len(names) != 1 {
+		return fmt.Errorf("expected a snapshot name but got %v", names)
+	}
+	path, err := snapshotPath(snapshotDir, names[0])
+	if err != nil {
+		return err
+	}
+
+	state, err := manager.LoadState(dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", dataDir, err)
+	}
+	if state.Running() {
+		if err := stopNetwork(dataDir, state); err != nil {
+			return err
+		}
+	}
+	if err := manager.SaveSnapshot(dataDir, path); err != nil {
+		return fmt.Errorf("could not save snapshot %s: %w", names[0], err)
+	}
+	color.Cyan("saved snapshot %s to %s", names[0], path)
+	return nil
+}
+
+func snapshotLoadCmd(args []string) error {
+	dataDir, snapshotDir, names := parseSnapshotFlags("load", "Replace the network in --data-dir with a saved snapshot", args)
+	if len(names) != 1 {
+		return fmt.Errorf("expected a snapshot name but got %v", names)
+	}
+	path, err := snapshotPath(snapshotDir, names[0])
+	if err != nil {
+		return err
+	}
+	if err := manager.LoadSnapshot(path, dataDir); err != nil {
+		return fmt.Errorf("could not load snapshot %s: %w", names[0], err)
+	}
+	color.Cyan("loaded snapshot %s into %s (run start --data-dir %s to resume the network)", names[0], dataDir, dataDir)
+	return nil
+}
+
+func snapshotListCmd(args []string) error {
+	_, snapshotDir, names := parseSnapshotFlags("list", "List the saved snapshots", args)
+	if len(names) > 0 {
+		return fmt.Errorf("unexpected arguments %v", names)
+	}
+	files, err := filepath.Glob(filepath.Join(snapshotDir, "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Println(filepath.Base(file[:len(file)-len(".tar.gz")]))
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/chaos"
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/control"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+	"github.com/ava-labs/ava-sim/runner"
+	"github.com/ava-labs/ava-sim/spec"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"github.com/fatih/color"
+	"golang.org/x/sync/errgroup"
+)
+
+func startCmd(args []string) error {
+	fs, dataDir := newFlagSet("start", "Start a local network, optionally running a custom VM on a subnet")
+	// Networks only resume from a data dir set explicitly, so that runs do
+	// not pick up or collide with the state of previous ones
+	dataDirFlag := fs.Lookup("data-dir")
+	dataDirFlag.DefValue = ""
+	dataDirFlag.Usage = "directory holding the network's node DBs, logs and plugins, resuming the stopped network in it if any (a fresh temporary directory is used if not set)"
+	*dataDir = ""
+	numNodes := fs.Int("num-nodes", constants.DefaultNumNodes, "number of nodes in the network")
+	basePort := fs.Int("base-port", constants.BaseHTTPPort, "HTTP port of the first node (node i uses base-port+2i for HTTP and base-port+2i+1 for staking)")
+	dynamicPorts := fs.Bool("dynamic-ports", false, "pick free ports for the nodes instead of deriving them from --base-port")
+	logLevel := fs.String("log-level", "info", "log level of the nodes")
+	avalanchegoPath := fs.String("avalanchego-path", "", "path to an avalanchego binary to run each node in a separate process with (nodes run in the ava-sim process by default)")
+	vm := fs.String("vm", "", "path to a custom VM binary to install on all nodes")
+	vmGenesis := fs.String("vm-genesis", "", "path to the genesis of the custom VM (creates a subnet running the VM once the network is bootstrapped)")
+	specFile := fs.String("spec", "", "path to a YAML or JSON spec declaring the nodes, subnets and blockchains of the network")
+	reset := fs.Bool("reset", false, "discard the stopped network in --data-dir instead of resuming it")
+	apiPort := fs.Int("api-port", 0, "port of the control API on localhost (a free port is picked if 0)")
+	faults := fs.Bool("faults", false, "route the staking connections between nodes through links that can inject faults (see ava-sim fault)")
+	byzantine := fs.String("byzantine", "", "comma-separated <node>:<mode> entries making nodes misbehave once the network is bootstrapped, where modes are unresponsive, delay=<duration> (both require --faults) and mute=<subnet> (requires staking), e.g. node2:unresponsive,node3:delay=2s")
+	chaosMode := fs.Bool("chaos", false, "kill, restart, pause and partition nodes at random once the network is set up (see the other --chaos flags)")
+	chaosSeed := fs.Int64("chaos-seed", 0, "seed of the chaos schedule, to replay a previous run (a random seed is used if not set)")
+	chaosInterval := fs.Duration("chaos-interval", chaos.DefaultInterval, "mean time between two chaos actions")
+	chaosDowntime := fs.Duration("chaos-downtime", chaos.DefaultDowntime, "maximum time nodes stay killed, paused or partitioned")
+	chaosQuorum := fs.Int("chaos-quorum", 0, "minimum number of nodes kept healthy (defaults to a majority of the nodes)")
+	chaosActions := fs.String("chaos-actions", "", "comma-separated chaos actions among kill, restart, pause and partition (defaults to all the actions the network supports)")
+	chaosDuration := fs.Duration("chaos-duration", 0, "time after which chaos stops (runs until the network stops if 0)")
+	readyFile := fs.String("ready-file", "", "file to write the ready signal (a JSON line with the nodes, subnets and accounts) to once the network and its subnets are set up (removed when ava-sim exits)")
+	readyFDNum := fs.Int("ready-fd", 0, "inherited file descriptor (3 or more) to write the ready signal to and close once the network and its subnets are set up, as an alternative to --ready-file")
+	diagnosticsFile := fs.String("diagnostics-file", "", "file to write a tarball with the logs, flags and state of the network to if it fails (defaults to "+diagnosticsFile+" in --data-dir)")
+	numAccounts := fs.Int("accounts", 0, "number of test accounts derived from --mnemonic to fund on the X, P and C chains once the network is bootstrapped, and in the genesis of the Subnet-EVM blockchains deployed afterwards (see ava-sim accounts)")
+	mnemonic := fs.String("mnemonic", accounts.DefaultMnemonic, "BIP-39 mnemonic the test accounts are derived from")
+	accountBalance := fs.Uint64("account-balance", accounts.DefaultBalance/units.Avax, "AVAX funded to each test account on each chain")
+	timeouts := timeoutFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var (
+		config      network.Options
+		subnets     func(nodeIDs []string) ([]runner.Subnet, error)
+		chaosConfig *chaos.Config
+	)
+	if len(*specFile) > 0 {
+		for _, name := range []string{"num-nodes", "vm", "vm-genesis"} {
+			if set[name] {
+				return fmt.Errorf("--%s cannot be used with --spec", name)
+			}
+		}
+		s, err := spec.Load(*specFile)
+		if err != nil {
+			return err
+		}
+		color.Yellow("spec set to: %s", *specFile)
+		config = s.Options()
+		subnets = s.RunnerSubnets
+		if chaosConfig, err = s.ChaosConfig(); err != nil {
+			return err
+		}
+	} else {
+		config.NumNodes = *numNodes
+	}
+	if set["base-port"] && *dynamicPorts {
+		return errors.New("--base-port cannot be used with --dynamic-ports")
+	}
+	if *dynamicPorts {
+		config.DynamicPorts = true
+	}
+	if *faults {
+		config.Faults = true
+	}
+	if len(*byzantine) > 0 {
+		behaviors, err := manager.ParseBehaviors(*byzantine)
+		if err != nil {
+			return err
+		}
+		if config.Behaviors == nil {
+			config.Behaviors = make(map[int]manager.Behavior)
+		}
+		for i, b := range behaviors {
+			config.Behaviors[i] = b
+		}
+	}
+	if set["base-port"] || config.BasePort == 0 {
+		config.BasePort = *basePort
+	}
+	if set["log-level"] || len(config.LogLevel) == 0 {
+		config.LogLevel = *logLevel
+	}
+	if set["accounts"] {
+		config.Accounts = *numAccounts
+	}
+	if set["mnemonic"] || len(config.Mnemonic) == 0 {
+		config.Mnemonic = *mnemonic
+	}
+	if set["account-balance"] || config.AccountBalance == 0 {
+		config.AccountBalance = *accountBalance * units.Avax
+	}
+	if len(*dataDir) == 0 {
+		if *reset {
+			return errors.New("--reset requires --data-dir")
+		}
+		dir, err := ioutil.TempDir("", "ava-sim-")
+		if err != nil {
+			return err
+		}
+		*dataDir = dir
+		color.Yellow("data-dir set to: %s", dir)
+	}
+	config.DataDir = *dataDir
+	config.Reset = *reset
+	config.Timeouts = *timeouts
+	if *chaosMode && chaosConfig == nil {
+		chaosConfig = &chaos.Config{Seed: time.Now().UnixNano()}
+	}
+	if chaosConfig != nil {
+		if set["chaos-seed"] {
+			chaosConfig.Seed = *chaosSeed
+		}
+		if set["chaos-interval"] || chaosConfig.Interval == 0 {
+			chaosConfig.Interval = *chaosInterval
+		}
+		if set["chaos-downtime"] || chaosConfig.Downtime == 0 {
+			chaosConfig.Downtime = *chaosDowntime
+		}
+		if set["chaos-quorum"] {
+			chaosConfig.Quorum = *chaosQuorum
+		}
+		if set["chaos-actions"] {
+			actions, err := chaos.ParseActions(*chaosActions)
+			if err != nil {
+				return err
+			}
+			chaosConfig.Actions = actions
+		}
+		if set["chaos-duration"] {
+			chaosConfig.Duration = *chaosDuration
+		}
+		if err := chaosConfig.Verify(); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range []string{"chaos-seed", "chaos-interval", "chaos-downtime", "chaos-quorum", "chaos-actions", "chaos-duration"} {
+			if set[name] {
+				return fmt.Errorf("--%s requires --chaos", name)
+			}
+		}
+	}
+
+	if *apiPort < 0 || *apiPort > 65535 {
+		return fmt.Errorf("invalid --api-port %d", *apiPort)
+	}
+	if len(*avalanchegoPath) > 0 {
+		path, err := checkFile("avalanchego-path", *avalanchegoPath)
+		if err != nil {
+			return err
+		}
+		config.AvalancheGoPath = path
+	}
+	if len(*vm) > 0 {
+		path, err := checkFile("vm", *vm)
+		if err != nil {
+			return err
+		}
+		config.VMs = map[string]string{constants.VMID: path}
+	}
+	if len(*vmGenesis) > 0 {
+		if len(*vm) == 0 {
+			return errors.New("--vm-genesis requires --vm")
+		}
+		path, err := checkFile("vm-genesis", *vmGenesis)
+		if err != nil {
+			return err
+		}
+		genesis, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read genesis file (%s): %w", path, err)
+		}
+		subnets = func(nodeIDs []string) ([]runner.Subnet, error) {
+			return []runner.Subnet{runner.DefaultSubnet(nodeIDs, genesis)}, nil
+		}
+	}
+
+	if len(*readyFile) > 0 {
+		// A ready file left by a previous run must not signal this network
+		// as ready
+		if err := os.Remove(*readyFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("invalid --ready-file: %w", err)
+		}
+		defer os.Remove(*readyFile)
+	}
+	readyFD, err := openReadyFD(*readyFDNum)
+	if err != nil {
+		return err
+	}
+
+	// Other ava-sim commands (e.g. upgrade) manage the network through the
+	// control API
+	listener, err := control.Listen(fmt.Sprintf("127.0.0.1:%d", *apiPort))
+	if err != nil {
+		return err
+	}
+	config.API = control.URL(listener)
+	if config.APIToken, err = control.NewToken(); err != nil {
+		listener.Close()
+		return err
+	}
+
+	n, err := network.New(config)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	// Diagnostics are saved before the network is stopped if it is running
+	// when it fails, and once it stopped otherwise
+	var (
+		diagnostics = diagnosticsPath(*dataDir, *diagnosticsFile)
+		diagnosed   bool
+	)
+
+	// Other ava-sim commands manage this network unless given --data-dir
+	if err := recordLastDataDir(*dataDir); err != nil {
+		color.Red("could not record data dir: %v", err)
+	}
+
+	// Start local network
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		// register signals to kill the application
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT)
+		signal.Notify(signals, syscall.SIGTERM)
+		// SIGHUP is sent by other ava-sim commands (e.g. subnet create) to
+		// whitelist the subnets they created
+		signal.Notify(signals, syscall.SIGHUP)
+		defer func() {
+			// shut down the signal go routine
+			signal.Stop(signals)
+			close(signals)
+		}()
+
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGHUP {
+					g.Go(func() error {
+						if err := manager.WhitelistPendingSubnets(gctx); err != nil && gctx.Err() == nil {
+							color.Red("could not whitelist subnets: %v", err)
+						}
+						return nil
+					})
+					continue
+				}
+				color.Yellow("signal received: %v, stopping the network", sig)
+				cancel()
+			case <-gctx.Done():
+			}
+			return nil
+		}
+	})
+
+	g.Go(func() error {
+		return control.Serve(gctx, listener, n, config.APIToken)
+	})
+
+	g.Go(func() error {
+		// Stop the other go routines once the network stopped
+		defer cancel()
+
+		if err := n.Start(gctx); err != nil {
+			if gctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if accts, err := n.Accounts(); err == nil && len(accts) > 0 {
+			color.Green("test accounts (see ava-sim accounts):")
+			printAccounts(accts)
+		}
+		// Only setup subnets if a custom VM is provided
+		if subnets != nil {
+			if err := setupSubnets(gctx, subnets, *timeouts); err != nil {
+				if gctx.Err() != nil {
+					return n.Stop()
+				}
+				saveDiagnostics(n, diagnostics, err)
+				diagnosed = true
+				_ = n.Stop()
+				return err
+			}
+		}
+		if err := signalReady(n, config.API, config.APIToken, *readyFile, readyFD); err != nil {
+			_ = n.Stop()
+			return err
+		}
+		color.Green("network in %s is ready, control API on %s", n.DataDir(), config.API)
+		if chaosConfig != nil {
+			color.Yellow("chaos seed: %d (replay with --chaos-seed %d)", chaosConfig.Seed, chaosConfig.Seed)
+			// The network is stopped if chaos cannot run on it
+			g.Go(func() error {
+				return chaos.Run(gctx, n, *chaosConfig)
+			})
+		}
+		select {
+		case <-gctx.Done():
+		case <-n.Done():
+		}
+		return n.Stop()
+	})
+
+	err = g.Wait()
+	if err != nil && !diagnosed {
+		saveDiagnostics(n, diagnostics, err)
+	}
+	return err
+}
+
+// setupSubnets creates the subnets returned by [subnets], restarts all nodes
+// to whitelist them and then deploys their blockchains, failing once the
+// deadline of a phase in [timeouts] passed. Subnets that were created on a
+// resumed network before are reused.
+func setupSubnets(ctx context.Context, subnets func(nodeIDs []string) ([]runner.Subnet, error), timeouts health.Timeouts) error {
+	nodeIDs := manager.NodeIDs()
+	nodeURLs := manager.NodeURLs()
+	toSetup, err := subnets(nodeIDs)
+	if err != nil {
+		return err
+	}
+	// Nodes removed from a resumed network are not running, so they cannot
+	// validate the blockchains
+	removed := make(map[string]bool)
+	for _, node := range manager.Nodes() {
+		removed[node.ID] = node.Removed
+	}
+	for i := range toSetup {
+		var validators []runner.Validator
+		for _, validator := range toSetup[i].Validators {
+			if !removed[validator.NodeID] {
+				validators = append(validators, validator)
+			}
+		}
+		toSetup[i].Validators = validators
+	}
+	var (
+		subnetIDs = make([]ids.ID, len(toSetup))
+		whitelist []string
+	)
+	for i, subnet := range toSetup {
+		if recorded, ok := manager.RecordedSubnet(subnet.Name); ok {
+			subnetID, err := ids.FromString(recorded)
+			if err != nil {
+				return err
+			}
+			color.Cyan("subnet %s already exists (%s)", subnet.Name, subnetID)
+			subnetIDs[i] = subnetID
+			continue
+		}
+		subnetID, err := runner.CreateSubnet(ctx, nodeURLs[0], timeouts)
+		if err != nil {
+			return err
+		}
+		if err := manager.RecordSubnet(manager.DataDir(), manager.SubnetState{
+			Name: subnet.Name,
+			ID:   subnetID.String(),
+		}); err != nil {
+			return err
+		}
+		subnetIDs[i] = subnetID
+		whitelist = append(whitelist, subnetID.String())
+	}
+
+	// Nodes only pick up whitelisted subnets on startup
+	if len(whitelist) > 0 {
+		if err := manager.WhitelistSubnets(ctx, whitelist); err != nil {
+			return fmt.Errorf("could not whitelist subnets: %w", err)
+		}
+	}
+
+	for i, subnet := range toSetup {
+		subnet.MuteValidators(manager.MutedNodeIDs(subnetIDs[i].String()))
+		blockchainIDs, err := runner.SetupSubnet(ctx, nodeURLs, nodeIDs, subnetIDs[i], subnet, timeouts)
+		if err != nil {
+			return err
+		}
+		if err := manager.RecordSubnet(manager.DataDir(), subnetRecord(subnetIDs[i], subnet, blockchainIDs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subnetRecord returns the record of [subnet] with ID [subnetID] running the
+// blockchains [blockchainIDs]
+func subnetRecord(subnetID ids.ID, subnet runner.Subnet, blockchainIDs []ids.ID) manager.SubnetState {
+	record := manager.SubnetState{
+		Name: subnet.Name,
+		ID:   subnetID.String(),
+	}
+	for i, blockchain := range subnet.Blockchains {
+		record.Blockchains = append(record.Blockchains, manager.BlockchainState{
+			Name: blockchain.Name,
+			ID:   blockchainIDs[i].String(),
+			VMID: blockchain.VMID,
+		})
+	}
+	return record
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+
+	"github.com/fatih/color"
+)
+
+func statusCmd(args []string) error {
+	fs, dataDir := newFlagSet("status", "Print the readiness of a running network: the bootstrap state of each chain, the peers, the subnet blockchains validated and the health checks of each node")
+	jsonOutput := fs.Bool("json", false, "print the status as JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	state, err := manager.LoadState(*dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", *dataDir, err)
+	}
+	if !state.Running() {
+		if *jsonOutput {
+			return printJSON(network.Status{})
+		}
+		color.Yellow("network in %s is not running", *dataDir)
+		return nil
+	}
+
+	var status network.Status
+	if err := callAPI(context.Background(), *dataDir, http.MethodGet, "/v1/status", nil, &status); err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return printJSON(status)
+	}
+	if status.Ready {
+		color.Green("network in %s is ready (pid %d)", *dataDir, state.PID)
+	} else {
+		color.Yellow("network in %s is running but not ready (pid %d)", *dataDir, state.PID)
+	}
+	for _, r := range status.Nodes {
+		printReport(r)
+	}
+	return nil
+}
+
+// printReport prints the readiness of a node
+func printReport(r health.Report) {
+	if !r.Reachable {
+		color.Red("%s (%s): %s", r.Name, r.ID, r.Reason)
+		return
+	}
+	chains := make([]string, len(r.Chains))
+	for i, c := range r.Chains {
+		switch {
+		case len(c.Error) > 0:
+			chains[i] = fmt.Sprintf("%s=error", c.Chain)
+		case len(c.Status) > 0 && !c.Bootstrapped:
+			chains[i] = fmt.Sprintf("%s=%s", c.Chain, c.Status)
+		default:
+			chains[i] = fmt.Sprintf("%s=bootstrapped:%t", c.Chain, c.Bootstrapped)
+		}
+	}
+	desc := fmt.Sprintf("%s (%s): %s peers=%d %s healthy=%t", r.Name, r.ID, r.URL, r.Peers, strings.Join(chains, " "), r.Healthy)
+	if len(r.Failing) > 0 {
+		desc += fmt.Sprintf(" failing=%s", strings.Join(r.Failing, ","))
+	}
+	if r.Ready {
+		color.Green("%s", desc)
+		return
+	}
+	color.Yellow("%s not ready: %s", desc, r.Reason)
+}
+
+// printJSON prints [v] as indented JSON on stdout
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
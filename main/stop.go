@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/ava-sim/manager"
+
+	"github.com/fatih/color"
+)
+
+const (
+	stopTimeout  = time.Minute
+	stopWaitTime = 500 * time.Millisecond
+)
+
+func stopCmd(args []string) error {
+	fs, dataDir := newFlagSet("stop", "Stop a running network")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	state, err := manager.LoadState(*dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", *dataDir, err)
+	}
+	if !state.Running() {
+		color.Yellow("network in %s is not running", *dataDir)
+		return nil
+	}
+
+	return stopNetwork(*dataDir, state)
+}
+
+// stopNetwork stops the network in [dataDir] described by [state] and waits
+// for the process running it to exit
+func stopNetwork(dataDir string, state *manager.State) error {
+	process, err := os.FindProcess(state.PID)
+	if err != nil {
+		return err
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("could not signal ava-sim (pid %d): %w", state.PID, err)
+	}
+	for deadline := time.Now().Add(stopTimeout); state.Running(); time.Sleep(stopWaitTime) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("ava-sim (pid %d) did not stop within %s", state.PID, stopTimeout)
+		}
+	}
+	color.Cyan("stopped network in %s", dataDir)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/runner"
+)
+
+const subnetUsage = `Manage the subnets of a running network
+
+Usage:
+  ava-sim subnet <command> [flags]
+
+Commands:
+  create  deploy the custom VM of a running network on a new subnet
+`
+
+func subnetCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, subnetUsage)
+		os.Exit(exitUsage)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "create":
+		return subnetCreateCmd(args)
+	case "help", "-h", "--help":
+		fmt.Print(subnetUsage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown subnet command %q\n\n%s", cmd, subnetUsage)
+		os.Exit(exitUsage)
+	}
+	return nil
+}
+
+func subnetCreateCmd(args []string) error {
+	fs, dataDir := newFlagSet("subnet create", "Create a subnet validated by all nodes and deploy the custom VM of a running network on it")
+	vmGenesis := fs.String("vm-genesis", "", "path to the genesis of the custom VM (required)")
+	timeouts := timeoutFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if len(*vmGenesis) == 0 {
+		return errors.New("--vm-genesis is required")
+	}
+	genesisFile, err := checkFile("vm-genesis", *vmGenesis)
+	if err != nil {
+		return err
+	}
+	genesis, err := ioutil.ReadFile(genesisFile)
+	if err != nil {
+		return fmt.Errorf("could not read genesis file (%s): %w", genesisFile, err)
+	}
+
+	state, err := manager.LoadState(*dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", *dataDir, err)
+	}
+	if !state.Running() {
+		return fmt.Errorf("network in %s is not running", *dataDir)
+	}
+	if _, ok := state.VMs[constants.VMID]; !ok {
+		return errors.New("network was not started with a custom VM (see start --vm)")
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// The txs issued are recorded along with the ones of the network
+	runner.RecordTxs(func(tx runner.Tx) {
+		_ = manager.RecordTx(*dataDir, tx)
+	})
+	subnetID, err := runner.CreateSubnet(ctx, state.NodeURLs()[0], *timeouts)
+	if err != nil {
+		return err
+	}
+	// The nodes restart to whitelist the subnet
+	err = health.Phase(ctx, health.NetworkBootstrap, timeouts.NetworkBootstrap, func(ctx context.Context) error {
+		return manager.RequestWhitelist(ctx, *dataDir, subnetID.String())
+	})
+	if err != nil {
+		return fmt.Errorf("could not whitelist subnet %s: %w", subnetID, err)
+	}
+	// EVM blockchains allocate funds to the test accounts of the network
+	accts, err := state.FundedAccounts()
+	if err != nil {
+		return err
+	}
+	if len(accts) > 0 {
+		runner.AllocateAccounts(accts, state.Accounts.Balance)
+	}
+	subnet := runner.DefaultSubnet(state.NodeIDs(), genesis)
+	blockchainIDs, err := runner.SetupSubnet(ctx, state.NodeURLs(), state.NodeIDs(), subnetID, subnet, *timeouts)
+	if err != nil {
+		return err
+	}
+	return manager.RecordSubnet(*dataDir, subnetRecord(subnetID, subnet, blockchainIDs))
+}
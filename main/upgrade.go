@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ava-labs/ava-sim/control"
+)
+
+func upgradeCmd(args []string) error {
+	fs, dataDir := newFlagSet("upgrade", "Restart the nodes of a running network one at a time with another avalanchego binary, keeping their DBs and staking keys")
+	avalanchegoPath := fs.String("avalanchego-path", "", "path to the avalanchego binary to upgrade the nodes to (required)")
+	nodes := fs.String("nodes", "", "comma-separated names of the nodes to upgrade, in order (defaults to all nodes)")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if len(*avalanchegoPath) == 0 {
+		return errors.New("--avalanchego-path is required")
+	}
+	path, err := checkFile("avalanchego-path", *avalanchegoPath)
+	if err != nil {
+		return err
+	}
+	// The binary is run by the ava-sim process of the network, which may have
+	// another working directory
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	req := control.UpgradeRequest{AvalancheGoPath: path}
+	if len(*nodes) > 0 {
+		req.Nodes = strings.Split(*nodes, ",")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var statuses []control.NodeStatus
+	if err := callAPI(ctx, *dataDir, http.MethodPost, "/v1/upgrade", req, &statuses); err != nil {
+		return err
+	}
+	printNodes(statuses)
+	return nil
+}
@@ -0,0 +1,296 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/ava-sim/proxy"
+
+	"github.com/fatih/color"
+)
+
+// Behavior describes how a byzantine node misbehaves. Nodes talk over TLS and
+// sign their own messages, so they cannot equivocate without changes to
+// avalanchego, which can be tested by running a modified avalanchego binary
+// on the node instead (see [Config.NodeAvalancheGoPaths]).
+type Behavior struct {
+	// Hold all data the node sends to its peers, so that it never answers
+	// their queries while still receiving their messages. Requires
+	// [Config.Faults].
+	Unresponsive bool
+	// Delay all data the node sends to its peers. Requires [Config.Faults].
+	Delay time.Duration
+	// Names or IDs of the subnets the node validates without running their
+	// chains, so that it never answers queries on them. Nodes run the chains
+	// of all subnets when staking is disabled, so this requires staking.
+	MuteSubnets []string
+}
+
+// behaviorJSON is the JSON encoding of [Behavior] with a delay such as "2s"
+type behaviorJSON struct {
+	Unresponsive bool     `json:"unresponsive,omitempty"`
+	Delay        string   `json:"delay,omitempty"`
+	MuteSubnets  []string `json:"muteSubnets,omitempty"`
+}
+
+func (b Behavior) MarshalJSON() ([]byte, error) {
+	res := behaviorJSON{
+		Unresponsive: b.Unresponsive,
+		MuteSubnets:  b.MuteSubnets,
+	}
+	if b.Delay != 0 {
+		res.Delay = b.Delay.String()
+	}
+	return json.Marshal(res)
+}
+
+func (b *Behavior) UnmarshalJSON(data []byte) error {
+	res := behaviorJSON{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*b = Behavior{
+		Unresponsive: res.Unresponsive,
+		MuteSubnets:  res.MuteSubnets,
+	}
+	if len(res.Delay) > 0 {
+		delay, err := time.ParseDuration(res.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+		b.Delay = delay
+	}
+	return nil
+}
+
+// Verify returns an error if the behavior is invalid
+func (b Behavior) Verify() error {
+	if b.Delay < 0 {
+		return fmt.Errorf("invalid delay %s", b.Delay)
+	}
+	for _, subnet := range b.MuteSubnets {
+		if len(subnet) == 0 {
+			return fmt.Errorf("empty subnet name in %v", b.MuteSubnets)
+		}
+	}
+	return nil
+}
+
+func (b Behavior) String() string {
+	var desc []string
+	if b.Unresponsive {
+		desc = append(desc, "unresponsive")
+	}
+	if b.Delay > 0 {
+		desc = append(desc, fmt.Sprintf("delay=%s", b.Delay))
+	}
+	if len(b.MuteSubnets) > 0 {
+		desc = append(desc, fmt.Sprintf("mute=%s", strings.Join(b.MuteSubnets, ",")))
+	}
+	if len(desc) == 0 {
+		return "honest"
+	}
+	return strings.Join(desc, " ")
+}
+
+// Zero returns true if the node behaves honestly
+func (b Behavior) Zero() bool {
+	return !b.Unresponsive && b.Delay == 0 && len(b.MuteSubnets) == 0
+}
+
+// faults returns the faults injected on the data sent by a node with the
+// behavior
+func (b Behavior) faults() proxy.Faults {
+	return proxy.Faults{
+		Latency:     b.Delay,
+		Partitioned: b.Unresponsive,
+	}
+}
+
+// mutes returns true if the behavior mutes the node on [subnet]
+func (b Behavior) mutes(subnet SubnetState) bool {
+	for _, name := range b.MuteSubnets {
+		if name == subnet.ID || (len(subnet.Name) > 0 && name == subnet.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBehaviors parses comma-separated <node>:<mode> entries into the
+// behaviors of the nodes keyed by index, where modes are unresponsive,
+// delay=<duration> and mute=<subnet>, e.g. "node2:unresponsive,node3:mute=evm"
+func ParseBehaviors(s string) (map[int]Behavior, error) {
+	behaviors := make(map[int]Behavior)
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid byzantine node %q (expecting <node>:<mode>)", entry)
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(parts[0], "node"))
+		if !strings.HasPrefix(parts[0], "node") || err != nil || index < 1 {
+			return nil, fmt.Errorf("unknown node %q", parts[0])
+		}
+		b := behaviors[index-1]
+		mode, value := parts[1], ""
+		if i := strings.Index(mode, "="); i >= 0 {
+			mode, value = mode[:i], mode[i+1:]
+		}
+		switch mode {
+		case "unresponsive":
+			b.Unresponsive = true
+		case "delay":
+			if b.Delay, err = time.ParseDuration(value); err != nil {
+				return nil, fmt.Errorf("invalid delay of %s: %w", parts[0], err)
+			}
+		case "mute":
+			b.MuteSubnets = append(b.MuteSubnets, value)
+		default:
+			return nil, fmt.Errorf("unknown byzantine mode %q (expecting unresponsive, delay or mute)", mode)
+		}
+		if err := b.Verify(); err != nil {
+			return nil, fmt.Errorf("invalid behavior of %s: %w", parts[0], err)
+		}
+		behaviors[index-1] = b
+	}
+	return behaviors, nil
+}
+
+// verifyBehaviors returns an error if the behaviors of [config] are invalid
+func verifyBehaviors(config Config) error {
+	for i, b := range config.Behaviors {
+		if i < 0 || i >= config.NumNodes {
+			return fmt.Errorf("behavior set for unknown node%d", i+1)
+		}
+		if err := b.Verify(); err != nil {
+			return fmt.Errorf("invalid behavior of node%d: %w", i+1, err)
+		}
+		if !b.faults().Zero() && !config.Faults {
+			return fmt.Errorf("node%d cannot be %s: %w", i+1, b, errFaultsDisabled)
+		}
+	}
+	return nil
+}
+
+// checkMuted returns an error if nodes are muted on subnets while staking is
+// disabled. [lock] must be held.
+func checkMuted() error {
+	if stakingEnabled {
+		return nil
+	}
+	for i, b := range networkConfig.Behaviors {
+		if len(b.MuteSubnets) > 0 {
+			return fmt.Errorf("node%d cannot be muted on subnets as staking is disabled (requires at least %d nodes)", i+1, len(embeddedCerts))
+		}
+	}
+	return nil
+}
+
+// egressFaults returns the faults injected on the data sent by the node at
+// [index]. [lock] must be held.
+func egressFaults(index int) proxy.Faults {
+	if !misbehaving {
+		return proxy.Faults{}
+	}
+	return networkConfig.Behaviors[index].faults()
+}
+
+// setMisbehaving makes the byzantine nodes misbehave, or behave honestly so
+// that nodes can bootstrap and connect to them. [lock] must be held.
+func setMisbehaving(active bool) {
+	if misbehaving == active {
+		return
+	}
+	misbehaving = active
+	byzantine := false
+	for _, b := range networkConfig.Behaviors {
+		byzantine = byzantine || !b.faults().Zero()
+	}
+	if !byzantine {
+		return
+	}
+	for key, l := range links {
+		updateLink(key, l)
+	}
+	if active {
+		color.Cyan("byzantine nodes misbehaving")
+	} else {
+		color.Cyan("byzantine nodes behaving honestly until the nodes bootstrap")
+	}
+}
+
+// behaveHonestly makes the byzantine nodes behave honestly until the
+// returned function is called, once the nodes restarted meanwhile
+// bootstrapped. They misbehave again once all the restarts in progress are
+// done. [lock] must be held when calling both functions.
+func behaveHonestly() func() {
+	bootstrapping++
+	setMisbehaving(false)
+	return func() {
+		if bootstrapping == 0 {
+			// The network was started again meanwhile
+			return
+		}
+		bootstrapping--
+		if bootstrapping == 0 {
+			setMisbehaving(true)
+		}
+	}
+}
+
+// whitelistFlag returns the value of the whitelisted-subnets flag of the
+// node at [index]: the whitelisted subnets but the ones it is muted on.
+// [lock] must be held.
+func whitelistFlag(index int) string {
+	behavior := networkConfig.Behaviors[index]
+	if len(behavior.MuteSubnets) == 0 {
+		return strings.Join(whitelistedSubnets, ",")
+	}
+	var subnets []SubnetState
+	if state, err := LoadState(networkDir); err == nil {
+		subnets = state.Subnets
+	}
+	muted := make(map[string]bool)
+	for _, subnet := range subnets {
+		muted[subnet.ID] = behavior.mutes(subnet)
+	}
+	var whitelist []string
+	for _, subnetID := range whitelistedSubnets {
+		if !muted[subnetID] && !behavior.mutes(SubnetState{ID: subnetID}) {
+			whitelist = append(whitelist, subnetID)
+		}
+	}
+	return strings.Join(whitelist, ",")
+}
+
+// MutedNodeIDs returns the IDs of the nodes that do not run the chains of the
+// whitelisted subnet [subnetID] as they are muted on it
+func MutedNodeIDs(subnetID string) map[string]bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	muted := make(map[string]bool)
+	if !stakingEnabled || !contains(whitelistedSubnets, subnetID) {
+		return muted
+	}
+	nodeIDs := NodeIDs()
+	for i, n := range nodes {
+		whitelist := strings.Split(flagValue(n.args, "whitelisted-subnets"), ",")
+		if !contains(whitelist, subnetID) {
+			muted[nodeIDs[i]] = true
+		}
+	}
+	return muted
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBehaviors(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		expected map[int]Behavior
+	}{
+		{
+			name:     "unresponsive",
+			s:        "node2:unresponsive",
+			expected: map[int]Behavior{1: {Unresponsive: true}},
+		},
+		{
+			name:     "delay",
+			s:        "node1:delay=2s",
+			expected: map[int]Behavior{0: {Delay: 2 * time.Second}},
+		},
+		{
+			name: "several nodes",
+			s:    "node2:unresponsive, node3:mute=evm",
+			expected: map[int]Behavior{
+				1: {Unresponsive: true},
+				2: {MuteSubnets: []string{"evm"}},
+			},
+		},
+		{
+			name: "modes of a node combine",
+			s:    "node4:mute=evm,node4:mute=wagmi,node4:delay=500ms",
+			expected: map[int]Behavior{
+				3: {Delay: 500 * time.Millisecond, MuteSubnets: []string{"evm", "wagmi"}},
+			},
+		},
+		{
+			// Nodes beyond the network are rejected by verifyBehaviors
+			name:     "node beyond the network",
+			s:        "node10:unresponsive",
+			expected: map[int]Behavior{9: {Unresponsive: true}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			behaviors, err := ParseBehaviors(test.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(behaviors, test.expected) {
+				t.Fatalf("expected %v but got %v", test.expected, behaviors)
+			}
+		})
+	}
+}
+
+func TestParseBehaviorsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		err  string
+	}{
+		{"empty", "", "invalid byzantine node"},
+		{"missing mode", "node2", "invalid byzantine node"},
+		{"trailing comma", "node2:unresponsive,", "invalid byzantine node"},
+		{"unknown node", "validator2:unresponsive", `unknown node "validator2"`},
+		{"node0", "node0:unresponsive", `unknown node "node0"`},
+		{"unknown mode", "node2:crash", `unknown byzantine mode "crash"`},
+		{"delay without unit", "node2:delay=2", "invalid delay of node2"},
+		{"delay without value", "node2:delay", "invalid delay of node2"},
+		{"negative delay", "node2:delay=-1s", "invalid behavior of node2"},
+		{"mute without subnet", "node2:mute", "invalid behavior of node2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseBehaviors(test.s)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyBehaviors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"honest", Config{NumNodes: 5}, ""},
+		{"byzantine", Config{NumNodes: 5, Faults: true, Behaviors: map[int]Behavior{4: {Unresponsive: true}}}, ""},
+		{"unknown node", Config{NumNodes: 5, Faults: true, Behaviors: map[int]Behavior{5: {Unresponsive: true}}}, "behavior set for unknown node6"},
+		{"without faults", Config{NumNodes: 5, Behaviors: map[int]Behavior{0: {Delay: time.Second}}}, "node1 cannot be delay=1s"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyBehaviors(test.config)
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
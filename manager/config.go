@@ -3,6 +3,7 @@ package manager
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -362,6 +363,46 @@ func flagsToArgs(flags Flags) []string {
 	return args
 }
 
+// flagValue returns the value of the flag [name] in [args]
+func flagValue(args []string, name string) string {
+	for _, arg := range args {
+		parts := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+		if parts[0] == name && len(parts) == 2 {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// applyFlagOverrides replaces the flags in [args] with the values in
+// [overrides], appending any flags that are not already set
+func applyFlagOverrides(args []string, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return args
+	}
+	applied := make(map[string]bool, len(overrides))
+	res := make([]string, 0, len(args)+len(overrides))
+	for _, arg := range args {
+		name := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)[0]
+		if value, ok := overrides[name]; ok {
+			arg = fmt.Sprintf("--%s=%s", name, value)
+			applied[name] = true
+		}
+		res = append(res, arg)
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		if !applied[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		res = append(res, fmt.Sprintf("--%s=%s", name, overrides[name]))
+	}
+	return res
+}
+
 func removeEmptyFlags(args []string) []string {
 	var res []string
 	for _, f := range args {
@@ -0,0 +1,275 @@
+package manager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+
+	avalancheHealth "github.com/ava-labs/avalanchego/api/health"
+	"github.com/ava-labs/avalanchego/api/info"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/network"
+	avalancheConstants "github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/vms/platformvm"
+)
+
+// Files of the data dir and of each node directory included in diagnostics
+// if they exist
+var (
+	diagnosticsFiles     = []string{stateFile, "chaos.log"}
+	diagnosticsNodeFiles = []string{flagsFile, processLogFile, "logs"}
+)
+
+// Diagnostics summarizes the state of a network when its diagnostics were
+// saved, and why it failed if it did
+type Diagnostics struct {
+	Time time.Time `json:"time"`
+	// Command line of the process that saved the diagnostics
+	Command []string `json:"command"`
+	DataDir string   `json:"dataDir"`
+	Running bool     `json:"running"`
+	// Error the network failed with and, if it failed during its setup, the
+	// phase that failed and whether it timed out. Node is set if the network
+	// stopped as a node failed.
+	Error    string `json:"error,omitempty"`
+	Phase    string `json:"phase,omitempty"`
+	TimedOut bool   `json:"timedOut,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+	Node     string `json:"node,omitempty"`
+	// Readiness of each node, if the network was running
+	Nodes []NodeDiagnostics `json:"nodes,omitempty"`
+}
+
+// NodeDiagnostics summarizes the readiness of a node
+type NodeDiagnostics struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// nodeInfo is the snapshot of the info API of a node
+type nodeInfo struct {
+	NodeID       string                    `json:"nodeID,omitempty"`
+	Version      *info.GetNodeVersionReply `json:"version,omitempty"`
+	NetworkID    uint32                    `json:"networkID,omitempty"`
+	Peers        []network.PeerInfo        `json:"peers,omitempty"`
+	Bootstrapped map[string]bool           `json:"bootstrapped,omitempty"`
+	Errors       []string                  `json:"errors,omitempty"`
+}
+
+// validatorSet is the snapshot of the validators of a subnet
+type validatorSet struct {
+	SubnetID string        `json:"subnetID"`
+	Current  []interface{} `json:"current"`
+	Pending  []interface{} `json:"pending"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// SaveDiagnostics archives the diagnostics of the network in [dataDir] to
+// [path]: its record (including the subnets, blockchains and txs issued to
+// set it up), chaos.log, and the flags, process output and logs of each node,
+// along with a summary of why the network failed with [failure] if it is not
+// nil. The health, info and readiness of each node and the validators of
+// each subnet are included if the network is running.
+func SaveDiagnostics(dataDir string, path string, failure error) error {
+	state, err := LoadState(dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", dataDir, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(constants.FilePerms)); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	summary := Diagnostics{
+		Time:    time.Now(),
+		Command: os.Args,
+		DataDir: dataDir,
+		Running: state.Running(),
+	}
+	if failure != nil {
+		summary.Error = failure.Error()
+		var phaseErr *health.PhaseError
+		if errors.As(failure, &phaseErr) {
+			summary.Phase = phaseErr.Phase
+			summary.TimedOut = phaseErr.TimedOut()
+			summary.Timeout = phaseErr.Timeout.String()
+		}
+		var nodeErr *NodeError
+		if errors.As(failure, &nodeErr) {
+			summary.Node = nodeErr.Node
+		}
+	}
+
+	files := append([]string(nil), diagnosticsFiles...)
+	for _, n := range state.Nodes {
+		for _, file := range diagnosticsNodeFiles {
+			files = append(files, filepath.Join(filepath.Base(n.Dir), file))
+		}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dataDir, file)); os.IsNotExist(err) {
+			continue
+		}
+		if err := archive(tw, dataDir, file); err != nil {
+			return fmt.Errorf("could not archive %s: %w", file, err)
+		}
+	}
+
+	if summary.Running {
+		var (
+			validating []string
+			// URL of a node answering API calls, queried for the validators
+			reachableURL string
+		)
+		for _, subnet := range state.Subnets {
+			for _, blockchain := range subnet.Blockchains {
+				validating = append(validating, blockchain.ID)
+			}
+		}
+		for i, n := range state.Nodes {
+			if n.Removed {
+				continue
+			}
+			name := fmt.Sprintf("node%d", i+1)
+			dir := filepath.Base(n.Dir)
+			node := health.Node{Name: name, ID: n.ID, URL: n.URL}
+			// Nodes only validate the blockchains of some of the subnets,
+			// which the report tells apart by their status
+			r := health.Check(node, health.Requirements{Chains: constants.Chains, Validating: validating})
+			summary.Nodes = append(summary.Nodes, NodeDiagnostics{
+				Name:   name,
+				ID:     n.ID,
+				Ready:  r.Ready,
+				Reason: r.Reason,
+			})
+			if err := writeJSON(tw, filepath.Join(dir, "status.json"), r); err != nil {
+				return err
+			}
+			if !r.Reachable {
+				continue
+			}
+			if len(reachableURL) == 0 {
+				reachableURL = n.URL
+			}
+			if err := writeJSON(tw, filepath.Join(dir, "info.json"), infoSnapshot(n.URL)); err != nil {
+				return err
+			}
+			reply, err := avalancheHealth.NewClient(n.URL, constants.HTTPTimeout).Health()
+			if err != nil {
+				continue
+			}
+			if err := writeJSON(tw, filepath.Join(dir, "health.json"), reply); err != nil {
+				return err
+			}
+		}
+		if len(reachableURL) > 0 {
+			if err := writeJSON(tw, "validators.json", validatorSnapshot(reachableURL, state.Subnets)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeJSON(tw, "summary.json", summary); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// infoSnapshot returns the snapshot of the info API of the node at [url]
+func infoSnapshot(url string) nodeInfo {
+	var (
+		client = info.NewClient(url, constants.HTTPTimeout)
+		res    = nodeInfo{Bootstrapped: make(map[string]bool)}
+		err    error
+	)
+	if res.NodeID, err = client.GetNodeID(); err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("could not query the node ID: %v", err))
+	}
+	if res.Version, err = client.GetNodeVersion(); err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("could not query the node version: %v", err))
+	}
+	if res.NetworkID, err = client.GetNetworkID(); err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("could not query the network ID: %v", err))
+	}
+	if res.Peers, err = client.Peers(); err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("could not query the peers: %v", err))
+	}
+	for _, chain := range constants.Chains {
+		bootstrapped, err := client.IsBootstrapped(chain)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("could not query the bootstrap state of %s: %v", chain, err))
+			continue
+		}
+		res.Bootstrapped[chain] = bootstrapped
+	}
+	return res
+}
+
+// validatorSnapshot returns the current and pending validators of the primary
+// network and of [subnets] according to the node at [url]
+func validatorSnapshot(url string, subnets []SubnetState) []validatorSet {
+	client := platformvm.NewClient(url, constants.HTTPTimeout)
+	subnetIDs := []ids.ID{avalancheConstants.PrimaryNetworkID}
+	var sets []validatorSet
+	for _, subnet := range subnets {
+		subnetID, err := ids.FromString(subnet.ID)
+		if err != nil {
+			sets = append(sets, validatorSet{SubnetID: subnet.ID, Error: err.Error()})
+			continue
+		}
+		subnetIDs = append(subnetIDs, subnetID)
+	}
+	for _, subnetID := range subnetIDs {
+		set := validatorSet{SubnetID: subnetID.String()}
+		var err error
+		if set.Current, err = client.GetCurrentValidators(subnetID, nil); err != nil {
+			set.Error = err.Error()
+		} else if set.Pending, _, err = client.GetPendingValidators(subnetID, nil); err != nil {
+			set.Error = err.Error()
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
+// writeJSON writes [v] as indented JSON to the file [name] of [tw]
+func writeJSON(tw *tar.Writer, name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    filepath.ToSlash(name),
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
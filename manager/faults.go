@@ -0,0 +1,345 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ava-labs/ava-sim/proxy"
+
+	"github.com/fatih/color"
+)
+
+var errFaultsDisabled = &ValidationError{Err: errors.New("fault injection is not enabled on the network (see --faults)")}
+
+// faultFlags are the avalanchego flags of the nodes of a network with fault
+// injection
+var faultFlags = map[string]string{
+	// Nodes only learn the staking ports of their peers from the addresses
+	// the peers advertise when they connect from the same IP. Advertising
+	// another IP keeps the ports from being gossiped, so that nodes only
+	// connect to each other through the links of [linkAddr].
+	"public-ip": "127.0.0.2",
+	// Nodes never connect again to a peer at an address it does not
+	// advertise once the connection is closed, so partitioned links must
+	// stall instead of timing out
+	"network-ping-timeout": "24h",
+	// Partitions heal within seconds
+	"network-max-reconnect-delay": "5s",
+}
+
+// linkKey identifies the link a node dials another node through
+type linkKey struct {
+	from int
+	to   int
+}
+
+// pairKey identifies a pair of nodes, which share the faults of the links
+// between them
+type pairKey struct {
+	a int
+	b int
+}
+
+func pairOf(i, j int) pairKey {
+	if i > j {
+		i, j = j, i
+	}
+	return pairKey{a: i, b: j}
+}
+
+// LinkFaults are the faults injected between the nodes named [Nodes]
+type LinkFaults struct {
+	Nodes  [2]string    `json:"nodes"`
+	Faults proxy.Faults `json:"faults"`
+}
+
+// linkAddr returns the address of the link the node at [from] dials the node
+// at [to] through, creating the link if needed. [lock] must be held.
+func linkAddr(from int, to int) (string, error) {
+	key := linkKey{from: from, to: to}
+	if l, ok := links[key]; ok {
+		return l.Addr(), nil
+	}
+	// The links of both directions share a slot, so that the nodes keep a
+	// single connection between them
+	slot := &proxy.Slot{}
+	if reverse, ok := links[linkKey{from: to, to: from}]; ok {
+		slot = reverse.Slot()
+	}
+	l, err := proxy.Listen(fmt.Sprintf("127.0.0.1:%d", nodePorts[to].staking), slot)
+	if err != nil {
+		return "", fmt.Errorf("could not create the link from node%d to node%d: %w", from+1, to+1, err)
+	}
+	links[key] = l
+	updateLink(key, l)
+	return l.Addr(), nil
+}
+
+// forgetNodeLinks closes the links between the node at [index] and the other
+// nodes and forgets the faults injected on them. [lock] must be held.
+func forgetNodeLinks(index int) {
+	for key, l := range links {
+		if key.from == index || key.to == index {
+			l.Close()
+			delete(links, key)
+		}
+	}
+	for pair := range pairFaults {
+		if pair.a == index || pair.b == index {
+			delete(pairFaults, pair)
+		}
+	}
+}
+
+// addBootstrapper makes the nodes that were not removed connect to the node
+// at [index] once they restart. [lock] must be held.
+func addBootstrapper(index int) error {
+	for i, n := range nodes {
+		if i == index || n.removed {
+			continue
+		}
+		addr, err := linkAddr(i, index)
+		if err != nil {
+			return err
+		}
+		n.args = applyFlagOverrides(n.args, map[string]string{
+			"bootstrap-ips": joinFlag(flagValue(n.args, "bootstrap-ips"), addr),
+			"bootstrap-ids": joinFlag(flagValue(n.args, "bootstrap-ids"), NodeIDs()[index]),
+		})
+	}
+	return nil
+}
+
+// joinFlag appends [value] to the comma-separated values of a flag
+func joinFlag(values string, value string) string {
+	if len(values) == 0 {
+		return value
+	}
+	return values + "," + value
+}
+
+// closeLinks closes the links between the nodes. [lock] must be held.
+func closeLinks() {
+	for _, l := range links {
+		l.Close()
+	}
+	links = nil
+	pairFaults = nil
+}
+
+// updateLink injects on the link with [key] the faults between the nodes it
+// connects, adding the faults of byzantine nodes to the data they send.
+// [lock] must be held.
+func updateLink(key linkKey, l *proxy.Link) {
+	faults := pairFaults[pairOf(key.from, key.to)]
+	l.SetFaults(faults.Combine(egressFaults(key.from)), faults.Combine(egressFaults(key.to)))
+}
+
+// setPairFaults injects [faults] between the nodes at [i] and [j]. [lock]
+// must be held.
+func setPairFaults(i int, j int, faults proxy.Faults) {
+	pair := pairOf(i, j)
+	if faults.Zero() {
+		delete(pairFaults, pair)
+	} else {
+		pairFaults[pair] = faults
+	}
+	for key, l := range links {
+		if pairOf(key.from, key.to) == pair {
+			updateLink(key, l)
+		}
+	}
+}
+
+// nodeIndices returns the indices of the nodes named [names], or of all nodes
+// if empty. [lock] must be held.
+func nodeIndices(names []string) ([]int, error) {
+	if len(names) == 0 {
+		return allNodes(), nil
+	}
+	indices := make([]int, len(names))
+	for i, name := range names {
+		index, err := nodeIndex(name)
+		if err != nil {
+			return nil, err
+		}
+		indices[i] = index
+	}
+	return indices, nil
+}
+
+// describeNodes returns a description of the nodes named [names], which are
+// all nodes if empty
+func describeNodes(names []string) string {
+	if len(names) == 0 {
+		return "all nodes"
+	}
+	return strings.Join(names, ",")
+}
+
+// checkFaults returns an error if faults cannot be injected on the network.
+// [lock] must be held.
+func checkFaults() error {
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	if !networkConfig.Faults {
+		return errFaultsDisabled
+	}
+	return nil
+}
+
+// SetFaults injects [faults] on the links between each node named [names]
+// and each node named [peers] (all nodes if empty), in both directions,
+// replacing the faults injected on them before
+func SetFaults(names []string, peers []string, faults proxy.Faults) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := checkFaults(); err != nil {
+		return err
+	}
+	if err := faults.Verify(); err != nil {
+		return &ValidationError{Err: err}
+	}
+	from, err := nodeIndices(names)
+	if err != nil {
+		return err
+	}
+	to, err := nodeIndices(peers)
+	if err != nil {
+		return err
+	}
+	for _, i := range from {
+		for _, j := range to {
+			if i != j {
+				setPairFaults(i, j, faults)
+			}
+		}
+	}
+	color.Cyan("injected %s between %s and %s", faults, describeNodes(names), describeNodes(peers))
+	return nil
+}
+
+// Partition cuts the links between nodes of different [groups] of node names,
+// keeping the other faults injected on them. Data sent across the partition
+// is held until it is healed. The links of nodes in no group are unaffected.
+func Partition(groups [][]string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := checkFaults(); err != nil {
+		return err
+	}
+	if len(groups) < 2 {
+		return invalidf("a partition needs at least 2 groups of nodes")
+	}
+	group := make(map[int]int)
+	for g, names := range groups {
+		if len(names) == 0 {
+			return invalidf("group %d of the partition is empty", g+1)
+		}
+		for _, name := range names {
+			index, err := nodeIndex(name)
+			if err != nil {
+				return err
+			}
+			if other, ok := group[index]; ok && other != g {
+				return invalidf("%s is in several groups of the partition", name)
+			}
+			group[index] = g
+		}
+	}
+	for i, gi := range group {
+		for j, gj := range group {
+			if i < j && gi != gj {
+				faults := pairFaults[pairOf(i, j)]
+				faults.Partitioned = true
+				setPairFaults(i, j, faults)
+			}
+		}
+	}
+	parts := make([]string, len(groups))
+	for i, names := range groups {
+		parts[i] = strings.Join(names, ",")
+	}
+	color.Cyan("partitioned the network into %s", strings.Join(parts, " | "))
+	return nil
+}
+
+// Heal removes all faults injected on the network, delivering the data held
+// by partitioned links. Byzantine nodes keep misbehaving.
+func Heal() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := checkFaults(); err != nil {
+		return err
+	}
+	for pair := range pairFaults {
+		setPairFaults(pair.a, pair.b, proxy.Faults{})
+	}
+	color.Cyan("healed all links")
+	return nil
+}
+
+// HealPartition removes the partitions of the links between each pair of
+// [pairs] of node names, keeping the other faults injected on them, e.g. to
+// heal only the links cut by a [Partition] while others stay cut
+func HealPartition(pairs [][2]string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := checkFaults(); err != nil {
+		return err
+	}
+	indices := make([][2]int, len(pairs))
+	for i, pair := range pairs {
+		for k, name := range pair {
+			index, err := nodeIndex(name)
+			if err != nil {
+				return err
+			}
+			indices[i][k] = index
+		}
+	}
+	var healed []string
+	for i, pair := range indices {
+		faults := pairFaults[pairOf(pair[0], pair[1])]
+		if !faults.Partitioned {
+			continue
+		}
+		faults.Partitioned = false
+		setPairFaults(pair[0], pair[1], faults)
+		healed = append(healed, pairs[i][0]+"-"+pairs[i][1])
+	}
+	if len(healed) > 0 {
+		color.Cyan("healed the partitions between %s", strings.Join(healed, ", "))
+	}
+	return nil
+}
+
+// Faults returns the faults injected between pairs of nodes
+func Faults() ([]LinkFaults, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := checkFaults(); err != nil {
+		return nil, err
+	}
+	var res []LinkFaults
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			faults, ok := pairFaults[pairOf(i, j)]
+			if !ok {
+				continue
+			}
+			res = append(res, LinkFaults{
+				Nodes:  [2]string{nodes[i].name(), nodes[j].name()},
+				Faults: faults,
+			})
+		}
+	}
+	return res, nil
+}
@@ -3,27 +3,108 @@ package manager
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/proxy"
+	"github.com/ava-labs/ava-sim/runner"
 	"github.com/ava-labs/ava-sim/utils"
 
-	"github.com/ava-labs/avalanchego/api/info"
-	"github.com/ava-labs/avalanchego/app/process"
-	"github.com/ava-labs/avalanchego/node"
+	"github.com/ava-labs/avalanchego/staking"
 	"github.com/fatih/color"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
-	bootstrapID = "NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg"
-	bootstrapIP = "127.0.0.1:9651"
-	waitDiff    = 10 * time.Second
+	waitDiff = 10 * time.Second
+
+	defaultEVMPath = "build/system-plugins/evm"
+)
+
+var (
+	errNotRunning     = errors.New("network is not running")
+	errAlreadyRunning = errors.New("a network is already running in this process")
 )
 
+// ValidationError is the error of a request that is invalid for the network,
+// e.g. naming an unknown node or injecting invalid faults, as opposed to a
+// request that failed
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalidf returns a [ValidationError] formatted as by fmt.Errorf
+func invalidf(format string, args ...interface{}) error {
+	return &ValidationError{Err: fmt.Errorf(format, args...)}
+}
+
+// Config describes the network started by [StartNetwork]
+type Config struct {
+	NumNodes int
+	// HTTP port of the first node. Node i listens for HTTP requests on
+	// BasePort+2i and for staking connections on BasePort+2i+1.
+	BasePort int
+	// Pick free ports for the nodes instead of deriving them from BasePort
+	DynamicPorts bool
+	// Directory holding the node DBs, logs and plugins. A fresh temporary
+	// directory is used if empty. A stopped network in DataDir is resumed
+	// unless Reset is set, in which case it is removed.
+	DataDir  string
+	Reset    bool
+	LogLevel string
+	// Path to an avalanchego binary to run each node in a separate process
+	// with. Nodes run in this process if empty.
+	AvalancheGoPath string
+	// Paths to the avalanchego binaries of the nodes at the given indices,
+	// overriding AvalancheGoPath
+	NodeAvalancheGoPaths map[int]string
+	// Path to the C-chain plugin of all nodes. Nodes running an avalanchego
+	// binary default to the plugins/evm next to it and nodes running in this
+	// process to build/system-plugins/evm.
+	EVMPath string
+	// Paths to the custom VM binaries to install on all nodes keyed by VM ID
+	VMs map[string]string
+	// Avalanchego flags keyed by flag name (without dashes) applied to all
+	// nodes, including nodes added at runtime, overriding the defaults
+	Flags map[string]string
+	// Avalanchego flags applied to the node at the given index, overriding
+	// [Flags]
+	NodeFlags map[int]map[string]string
+	// Route the staking connections between nodes through links that can
+	// inject faults (see [SetFaults]). Every node then connects to all other
+	// nodes on startup instead of bootstrapping from the first node only.
+	Faults bool
+	// Behaviors of the byzantine nodes at the given indices. Nodes only
+	// misbehave once all nodes have bootstrapped and behave honestly whenever
+	// nodes restart, so that the nodes can bootstrap and connect to them.
+	Behaviors map[int]Behavior
+	// Deadline for the nodes to bootstrap the primary network and connect to
+	// each other, on startup and whenever nodes restart or join the network.
+	// Defaults to [health.DefaultTimeouts].
+	BootstrapTimeout time.Duration
+	// URL of the control API serving the network, recorded in its state so
+	// that other ava-sim commands can manage it
+	API string
+	// Token clients of the control API must send, recorded with API
+	APIToken string
+}
+
 // Embed certs in binary and write to tmp file on startup (full binary)
 var (
 	//go:embed certs/keys1/staker.crt
@@ -51,104 +132,358 @@ var (
 	//go:embed certs/keys5/staker.key
 	keys5StakerKey []byte
 
-	nodeCerts = [][]byte{keys1StakerCrt, keys2StakerCrt, keys3StakerCrt, keys4StakerCrt, keys5StakerCrt}
-	nodeKeys  = [][]byte{keys1StakerKey, keys2StakerKey, keys3StakerKey, keys4StakerKey, keys5StakerKey}
+	embeddedCerts = [][]byte{keys1StakerCrt, keys2StakerCrt, keys3StakerCrt, keys4StakerCrt, keys5StakerCrt}
+	embeddedKeys  = [][]byte{keys1StakerKey, keys2StakerKey, keys3StakerKey, keys4StakerKey, keys5StakerKey}
+
+	// Staking key pairs and IDs of the nodes in the network, populated by
+	// [setStakingKeys] when the network is started
+	nodeCerts      [][]byte
+	nodeKeys       [][]byte
+	nodeStakingIDs []string
+
+	// HTTP and staking ports of the nodes in the network, allocated by
+	// [allocatePorts] when the nodes are configured
+	nodePorts []ports
+
+	// State of the running network, used to restart and add nodes
+	lock               sync.Mutex
+	networkDir         string
+	networkConfig      Config
+	nodePluginsDir     string
+	buildDirs          map[string]string
+	stakingEnabled     bool
+	whitelistedSubnets []string
+	nodes              []*localNode
+	nodeGroup          *errgroup.Group
+	nodeCtx            context.Context
+	// Held while a node is added (see [AddNode])
+	adding sync.Mutex
+	// Links the nodes dial each other through and the faults injected
+	// between pairs of nodes, if [Config.Faults] is set
+	links      map[linkKey]*proxy.Link
+	pairFaults map[pairKey]proxy.Faults
+	// Whether the byzantine nodes misbehave, and the number of restarts
+	// waiting for nodes to bootstrap, during which they behave honestly
+	misbehaving   bool
+	bootstrapping int
 )
 
-func NodeIDs() []string {
-	nodeCerts := [][]byte{keys1StakerCrt, keys2StakerCrt, keys3StakerCrt, keys4StakerCrt, keys5StakerCrt}
-	nodeIDs := make([]string, constants.NumNodes)
-	for i, cert := range nodeCerts {
+// loadStakingKeys selects the staking key pairs for a network of [numNodes]
+// nodes. The embedded key pairs (the genesis validators of the local network)
+// are used first and fresh key pairs are generated for any remaining nodes.
+func loadStakingKeys(numNodes int) error {
+	if numNodes < 1 {
+		return fmt.Errorf("network must have at least 1 node but got %d", numNodes)
+	}
+
+	certs := make([][]byte, numNodes)
+	keys := make([][]byte, numNodes)
+	for i := 0; i < numNodes; i++ {
+		if i < len(embeddedCerts) {
+			certs[i] = embeddedCerts[i]
+			keys[i] = embeddedKeys[i]
+			continue
+		}
+		cert, key, err := staking.NewCertAndKeyBytes()
+		if err != nil {
+			return fmt.Errorf("could not generate staking key pair for node%d: %w", i+1, err)
+		}
+		certs[i] = cert
+		keys[i] = key
+	}
+	return setStakingKeys(certs, keys)
+}
+
+// readStakingKeys reads the staking key pairs of the nodes of a previous
+// network described by [state]
+func readStakingKeys(state *State) error {
+	certs := make([][]byte, len(state.Nodes))
+	keys := make([][]byte, len(state.Nodes))
+	for i, n := range state.Nodes {
+		cert, err := ioutil.ReadFile(filepath.Join(n.Dir, "staker.crt"))
+		if err != nil {
+			return fmt.Errorf("could not read staking cert of node%d: %w", i+1, err)
+		}
+		key, err := ioutil.ReadFile(filepath.Join(n.Dir, "staker.key"))
+		if err != nil {
+			return fmt.Errorf("could not read staking key of node%d: %w", i+1, err)
+		}
+		certs[i] = cert
+		keys[i] = key
+	}
+	return setStakingKeys(certs, keys)
+}
+
+// setStakingKeys sets the staking key pairs of the nodes in the network
+func setStakingKeys(certs [][]byte, keys [][]byte) error {
+	ids := make([]string, len(certs))
+	for i, cert := range certs {
 		id, err := utils.LoadNodeID(cert)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("invalid staking cert of node%d: %w", i+1, err)
 		}
-		nodeIDs[i] = id
+		ids[i] = id
 	}
-	return nodeIDs
+	nodeCerts = certs
+	nodeKeys = keys
+	nodeStakingIDs = ids
+	return nil
+}
+
+func NodeIDs() []string {
+	return append([]string(nil), nodeStakingIDs...)
 }
 
 func NodeURLs() []string {
-	urls := make([]string, constants.NumNodes)
-	for i := 0; i < constants.NumNodes; i++ {
-		urls[i] = fmt.Sprintf("http://127.0.0.1:%d", constants.BaseHTTPPort+i*2)
+	urls := make([]string, len(nodePorts))
+	for i, p := range nodePorts {
+		urls[i] = fmt.Sprintf("http://127.0.0.1:%d", p.http)
 	}
 	return urls
 }
 
-func StartNetwork(ctx context.Context, vmPath string, bootstrapped chan struct{}) error {
-	dir, err := ioutil.TempDir("", "ava-sim")
-	if err != nil {
-		panic(err)
+// ports are the ports a node listens on
+type ports struct {
+	http    int
+	staking int
+}
+
+// allocatePorts returns the ports of the node at [index], which are free
+// ports if [Config.DynamicPorts] is set and derived from [Config.BasePort]
+// otherwise
+func allocatePorts(index int) (ports, error) {
+	if networkConfig.DynamicPorts {
+		// The ports of the other nodes are only bound once they start, so
+		// they may be returned as free again
+		for {
+			free, err := utils.FreePorts(2)
+			if err != nil {
+				return ports{}, err
+			}
+			p := ports{http: free[0], staking: free[1]}
+			if !portsAllocated(p, index) {
+				return p, nil
+			}
+		}
 	}
-	color.Cyan("tmp dir located at: %s", dir)
-	defer func() {
-		color.Cyan("tmp dir located at: %s", dir)
-	}()
+	basePort := networkConfig.BasePort
+	if basePort == 0 {
+		basePort = constants.BaseHTTPPort
+	}
+	p := ports{http: basePort + 2*index, staking: basePort + 2*index + 1}
+	if p.staking > 65535 {
+		return ports{}, fmt.Errorf("no ports left for node%d (base port %d)", index+1, basePort)
+	}
+	return p, nil
+}
 
-	// Copy files into custom plugins
-	pluginsDir := fmt.Sprintf("%s/plugins", dir)
-	if err := os.MkdirAll(pluginsDir, os.FileMode(constants.FilePerms)); err != nil {
-		panic(err)
+// portsAllocated returns true if any of [p] is allocated to a node other than
+// the node at [index]
+func portsAllocated(p ports, index int) bool {
+	for i, other := range nodePorts {
+		if i == index {
+			continue
+		}
+		if p.http == other.http || p.http == other.staking || p.staking == other.http || p.staking == other.staking {
+			return true
+		}
 	}
-	if err := utils.CopyFile("build/system-plugins/evm", fmt.Sprintf("%s/evm", pluginsDir)); err != nil {
-		panic(err)
+	return false
+}
+
+// prepareDataDir creates the data dir at [dir] and returns the state of the
+// previous network in it, if any, so that it can be resumed. The contents of
+// a previous network are removed instead if [reset] is set.
+func prepareDataDir(dir string, reset bool) (string, *State, error) {
+	if len(dir) == 0 {
+		dir, err := ioutil.TempDir("", "ava-sim")
+		return dir, nil, err
 	}
-	if len(vmPath) > 0 {
-		if err := utils.CopyFile(vmPath, fmt.Sprintf("%s/%s", pluginsDir, constants.VMID)); err != nil {
-			panic(err)
+
+	state, err := LoadState(dir)
+	switch {
+	case err == nil:
+		if state.Running() {
+			return "", nil, fmt.Errorf("network in %s is already running (pid %d)", dir, state.PID)
+		}
+		if !reset {
+			return dir, state, nil
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return "", nil, err
 		}
+	case errors.Is(err, os.ErrNotExist):
+		files, err := ioutil.ReadDir(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", nil, err
+		}
+		if len(files) > 0 {
+			return "", nil, fmt.Errorf("data dir %s is not empty", dir)
+		}
+	default:
+		return "", nil, err
+	}
+	return dir, nil, os.MkdirAll(dir, os.FileMode(constants.FilePerms))
+}
+
+// StartNetwork runs the network described by [config] until [ctx] is done or
+// a node fails, closing [bootstrapped] once all nodes have bootstrapped. Only
+// one network can run in a process at a time.
+func StartNetwork(ctx context.Context, config Config, bootstrapped chan struct{}) error {
+	lock.Lock()
+	if nodes != nil {
+		lock.Unlock()
+		return errAlreadyRunning
 	}
+	// Reserve the network until it is configured
+	nodes = []*localNode{}
+	lock.Unlock()
+	defer stopped()
 
-	nodeConfigs := make([]node.Config, constants.NumNodes)
-	for i := 0; i < constants.NumNodes; i++ {
-		nodeDir := fmt.Sprintf("%s/node%d", dir, i+1)
-		if err := os.MkdirAll(nodeDir, os.FileMode(constants.FilePerms)); err != nil {
-			panic(err)
+	numNodes := config.NumNodes
+	if config.BootstrapTimeout <= 0 {
+		config.BootstrapTimeout = health.DefaultTimeouts.NetworkBootstrap
+	}
+	if err := verifyBehaviors(config); err != nil {
+		return err
+	}
+	dir, previous, err := prepareDataDir(config.DataDir, config.Reset)
+	if err != nil {
+		return err
+	}
+	if config.AvalancheGoPath, err = absPath(config.AvalancheGoPath); err != nil {
+		return err
+	}
+	avalanchegoPaths := make(map[int]string, len(config.NodeAvalancheGoPaths))
+	for i, path := range config.NodeAvalancheGoPaths {
+		if avalanchegoPaths[i], err = absPath(path); err != nil {
+			return err
+		}
+	}
+	config.NodeAvalancheGoPaths = avalanchegoPaths
+	// Copied as VMs installed at runtime are added to it
+	vms := make(map[string]string, len(config.VMs))
+	for vmID, vmPath := range config.VMs {
+		if vms[vmID], err = absPath(vmPath); err != nil {
+			return err
 		}
-		certFile := fmt.Sprintf("%s/staker.crt", nodeDir)
-		if err := ioutil.WriteFile(certFile, nodeCerts[i], os.FileMode(constants.FilePerms)); err != nil {
-			panic(err)
+	}
+	config.VMs = vms
+	if previous != nil {
+		// The blockchains of the previous network run the VMs installed on
+		// it, which are installed again unless they are replaced
+		config.VMs = previous.resumedVMs(vms)
+		if len(previous.Nodes) != numNodes {
+			return fmt.Errorf("network in %s has %d nodes but %d were requested", dir, len(previous.Nodes), numNodes)
 		}
-		keyFile := fmt.Sprintf("%s/staker.key", nodeDir)
-		if err := ioutil.WriteFile(keyFile, nodeKeys[i], os.FileMode(constants.FilePerms)); err != nil {
-			panic(err)
+		color.Cyan("resuming network in %s", dir)
+		if err := readStakingKeys(previous); err != nil {
+			return err
 		}
+	} else if err := loadStakingKeys(numNodes); err != nil {
+		return err
+	}
+	logLevel := config.LogLevel
+	if len(logLevel) == 0 {
+		logLevel = "info"
+	}
+	config.LogLevel = logLevel
+	config.NumNodes = numNodes
 
-		df := defaultFlags()
-		df.LogLevel = "info"
-		df.LogDir = fmt.Sprintf("%s/logs", nodeDir)
-		df.DBDir = fmt.Sprintf("%s/db", nodeDir)
-		df.StakingEnabled = true
-		df.HTTPPort = uint(constants.BaseHTTPPort + 2*i)
-		df.StakingPort = uint(constants.BaseHTTPPort + 2*i + 1)
-		if i != 0 {
-			df.BootstrapIPs = bootstrapIP
-			df.BootstrapIDs = bootstrapID
-		} else {
-			df.BootstrapIPs = ""
-			df.BootstrapIDs = ""
+	color.Cyan("data dir located at: %s", dir)
+	defer func() {
+		color.Cyan("data dir located at: %s", dir)
+	}()
+
+	state := &State{
+		PID:   os.Getpid(),
+		API:   config.API,
+		VMs:   config.VMs,
+		Nodes: make([]NodeState, numNodes),
+	}
+	if previous != nil {
+		// Nodes keep validating the subnets of the previous network
+		state.WhitelistedSubnets = previous.WhitelistedSubnets
+		state.Subnets = previous.Subnets
+		state.Txs = previous.Txs
+		state.Accounts = previous.Accounts
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	lock.Lock()
+	networkDir = dir
+	networkConfig = config
+	nodePluginsDir = filepath.Join(dir, "plugins")
+	buildDirs = make(map[string]string)
+	whitelistedSubnets = state.WhitelistedSubnets
+	nodes = make([]*localNode, numNodes)
+	nodePorts = nil
+	nodeGroup = g
+	nodeCtx = gctx
+	links = make(map[linkKey]*proxy.Link)
+	pairFaults = make(map[pairKey]proxy.Faults)
+	misbehaving = false
+	bootstrapping = 0
+	// Nodes bootstrap from the first node that was not removed, or connect
+	// to all other nodes if faults are injected on the links between them
+	var bootstrappers []int
+	for i := 0; i < numNodes; i++ {
+		if previous == nil || !previous.Nodes[i].Removed {
+			bootstrappers = append(bootstrappers, i)
 		}
-		if len(vmPath) > 0 {
-			df.WhitelistedSubnets = constants.WhitelistedSubnets
+	}
+	if len(bootstrappers) == 0 {
+		bootstrappers = []int{0}
+	}
+	if !config.Faults {
+		bootstrappers = bootstrappers[:1]
+	}
+	// The ports of all nodes are allocated first as nodes may connect to the
+	// nodes configured after them
+	for i := 0; i < numNodes; i++ {
+		p, err := allocatePorts(i)
+		if err != nil {
+			lock.Unlock()
+			return fmt.Errorf("could not configure node%d: %w", i+1, err)
 		}
-		df.StakingTLSCertFile = certFile
-		df.StakingTLSKeyFile = keyFile
-		nodeConfig, err := createNodeConfig(pluginsDir, flagsToArgs(df))
+		nodePorts = append(nodePorts, p)
+	}
+	args := make([][]string, numNodes)
+	for i := 0; i < numNodes; i++ {
+		n, err := newLocalNode(i, bootstrappers)
 		if err != nil {
-			panic(err)
+			lock.Unlock()
+			return fmt.Errorf("could not configure node%d: %w", i+1, err)
+		}
+		nodes[i] = n
+		args[i] = append([]string(nil), n.args...)
+		if previous != nil && previous.Nodes[i].Removed {
+			n.running = false
+			n.removed = true
 		}
-		nodeConfig.PluginDir = pluginsDir
-		nodeConfigs[i] = nodeConfig
+		state.Nodes[i] = n.state()
+	}
+	stakingEnabled = flagValue(args[0], "staking-enabled") != "false"
+	if err := checkMuted(); err != nil {
+		lock.Unlock()
+		return err
+	}
+	lock.Unlock()
+	if err := state.Save(dir); err != nil {
+		return fmt.Errorf("could not save network state: %w", err)
+	}
+	if err := saveAPIToken(dir, config.APIToken); err != nil {
+		return fmt.Errorf("could not save control API token: %w", err)
 	}
 
 	// Start all nodes and check if bootstrapped
-	g, gctx := errgroup.WithContext(ctx)
-	for i, config := range nodeConfigs {
-		c := config
-		j := i
+	for i, n := range nodes {
+		n, nodeArgs := n, args[i]
+		if n.removed {
+			nodeArgs = nil
+		}
 		g.Go(func() error {
-			return runApp(g, gctx, j, c)
+			return runApp(gctx, n, nodeArgs)
 		})
 	}
 	g.Go(func() error {
@@ -157,75 +492,283 @@ func StartNetwork(ctx context.Context, vmPath string, bootstrapped chan struct{}
 	return g.Wait()
 }
 
-func checkBootstrapped(ctx context.Context, bootstrapped chan struct{}) error {
-	if bootstrapped == nil {
-		return nil
+// withNetwork returns a context that is done once [ctx] is or once the
+// running network stopped, e.g. as a node failed. [lock] must be held.
+func withNetwork(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if nodeCtx == nil {
+		return ctx, cancel
 	}
+	stopped := nodeCtx.Done()
+	go func() {
+		select {
+		case <-stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
 
-	var (
-		nodeURLs = NodeURLs()
-		nodeIDs  = NodeIDs()
-	)
+// stopped clears the state of the network that stopped running in this
+// process so that another network can be started
+func stopped() {
+	lock.Lock()
+	defer lock.Unlock()
 
-	for i, url := range nodeURLs {
-		client := info.NewClient(url, constants.HTTPTimeout)
-		for {
-			if ctx.Err() != nil {
-				color.Red("stopping bootstrapped check: %v", ctx.Err())
-				return ctx.Err()
-			}
-			bootstrapped := true
-			for _, chain := range constants.Chains {
-				chainBootstrapped, _ := client.IsBootstrapped(chain)
-				if !chainBootstrapped {
-					color.Yellow("waiting for %s to bootstrap %s-chain", nodeIDs[i], chain)
-					bootstrapped = false
-					break
-				}
-			}
-			if !bootstrapped {
-				time.Sleep(waitDiff)
-				continue
+	if len(networkDir) > 0 {
+		err := UpdateState(networkDir, func(state *State) error {
+			if state.PID == os.Getpid() {
+				state.PID = 0
+				state.API = ""
+				return saveAPIToken(networkDir, "")
 			}
-			if peers, _ := client.Peers(); len(peers) < constants.NumNodes-1 {
-				color.Yellow("waiting for %s to connect to all peers (%d/%d)", nodeIDs[i], len(peers), constants.NumNodes-1)
-				time.Sleep(waitDiff)
-				continue
-			}
-			color.Cyan("%s is bootstrapped and connected", nodeIDs[i])
-			break
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			color.Red("could not save network state: %v", err)
 		}
 	}
+	networkDir = ""
+	closeLinks()
+	nodes = nil
+	nodeGroup = nil
+	nodeCtx = nil
+}
+
+func checkBootstrapped(ctx context.Context, bootstrapped chan struct{}) error {
+	if bootstrapped == nil {
+		return nil
+	}
+
+	lock.Lock()
+	running := runningNodes()
+	lock.Unlock()
+	if err := waitBootstrapped(ctx, running, len(running)-1); err != nil {
+		color.Red("stopping bootstrapped check: %v", err)
+		return err
+	}
 
 	color.Cyan("all nodes bootstrapped")
+	lock.Lock()
+	setMisbehaving(true)
+	lock.Unlock()
 	close(bootstrapped)
 
 	// Print endpoints where VM is accessible
 	color.Green("standard VM endpoints now accessible at:")
-	for i, url := range nodeURLs {
-		color.Green("%s: %s", nodeIDs[i], url)
+	nodeIDs := NodeIDs()
+	nodeURLs := NodeURLs()
+	for _, i := range running {
+		color.Green("%s: %s", nodeIDs[i], nodeURLs[i])
 	}
 
 	return nil
 }
 
-func runApp(g *errgroup.Group, ctx context.Context, nodeNum int, config node.Config) error {
-	app := process.NewApp(config)
+// WhitelistSubnets makes all nodes validate [subnetIDs] in addition to the
+// subnets they already whitelist. Nodes only read their whitelist on startup,
+// so they are restarted and this waits for them to bootstrap again. Nodes
+// run the chains of all subnets when staking is disabled, in which case no
+// restart is required.
+func WhitelistSubnets(ctx context.Context, subnetIDs []string) error {
+	lock.Lock()
+	defer lock.Unlock()
 
-	// Start running the AvalancheGo application
-	if err := app.Start(); err != nil {
-		return fmt.Errorf("node%d failed to start: %w", nodeNum+1, err)
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	whitelistedSubnets = append(whitelistedSubnets, subnetIDs...)
+	for i, n := range nodes {
+		n.args = applyFlagOverrides(n.args, map[string]string{
+			"whitelisted-subnets": whitelistFlag(i),
+		})
+	}
+	if stakingEnabled {
+		if err := restartNodes(ctx, allNodes()); err != nil {
+			return err
+		}
 	}
 
-	g.Go(func() error {
-		<-ctx.Done()
-		_ = app.Stop()
-		return ctx.Err()
+	// Record the whitelisted subnets for the ava-sim processes waiting on
+	// [RequestWhitelist]
+	return UpdateState(networkDir, func(state *State) error {
+		state.WhitelistedSubnets = whitelistedSubnets
+		state.PendingSubnets = subtract(state.PendingSubnets, whitelistedSubnets)
+		return nil
+	})
+}
+
+// WhitelistPendingSubnets whitelists the subnets requested by other ava-sim
+// processes with [RequestWhitelist]
+func WhitelistPendingSubnets(ctx context.Context) error {
+	lock.Lock()
+	dir := networkDir
+	whitelisted := whitelistedSubnets
+	lock.Unlock()
+	if len(dir) == 0 {
+		return errNotRunning
+	}
+
+	state, err := LoadState(dir)
+	if err != nil {
+		return err
+	}
+	pending := subtract(state.PendingSubnets, whitelisted)
+	if len(pending) == 0 {
+		return nil
+	}
+	color.Cyan("whitelisting subnets %v", pending)
+	return WhitelistSubnets(ctx, pending)
+}
+
+// RequestWhitelist asks the ava-sim process running the network in [dataDir]
+// to whitelist [subnetID] and waits until it has
+func RequestWhitelist(ctx context.Context, dataDir string, subnetID string) error {
+	var pid int
+	err := UpdateState(dataDir, func(state *State) error {
+		state.PendingSubnets = append(state.PendingSubnets, subnetID)
+		pid = state.PID
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		return fmt.Errorf("could not signal ava-sim (pid %d): %w", pid, err)
+	}
 
-	exitCode, err := app.ExitCode()
-	if (exitCode > 0 || err != nil) && ctx.Err() == nil {
-		color.Red("node%d exited with code %d: %v", nodeNum+1, exitCode, err)
+	return health.Poll(ctx, waitDiff, fmt.Sprintf("nodes to whitelist subnet %s", subnetID), func() (bool, string, error) {
+		state, err := LoadState(dataDir)
+		if err != nil {
+			return false, "", err
+		}
+		if !state.Running() {
+			return false, "", fmt.Errorf("network in %s stopped", dataDir)
+		}
+		if contains(state.WhitelistedSubnets, subnetID) {
+			return true, "", nil
+		}
+		return false, "pending", nil
+	})
+}
+
+// RecordedSubnet returns the ID of the subnet named [name] if it was created
+// on the network before
+func RecordedSubnet(name string) (string, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := LoadState(networkDir)
+	if err != nil {
+		return "", false
+	}
+	for _, subnet := range state.Subnets {
+		if len(name) > 0 && subnet.Name == name {
+			return subnet.ID, true
+		}
+	}
+	return "", false
+}
+
+// RecordSubnet adds [subnet] to the state of the network in [dataDir],
+// replacing any previous record of the same subnet
+func RecordSubnet(dataDir string, subnet SubnetState) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return UpdateState(dataDir, func(state *State) error {
+		for i, s := range state.Subnets {
+			if s.ID == subnet.ID {
+				state.Subnets[i] = subnet
+				return nil
+			}
+		}
+		state.Subnets = append(state.Subnets, subnet)
+		return nil
+	})
+}
+
+// RecordTx adds [tx] to the state of the network in [dataDir], replacing any
+// previous record of the same tx
+func RecordTx(dataDir string, tx runner.Tx) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return UpdateState(dataDir, func(state *State) error {
+		for i, t := range state.Txs {
+			if t.ID == tx.ID {
+				state.Txs[i] = tx
+				return nil
+			}
+		}
+		state.Txs = append(state.Txs, tx)
+		return nil
+	})
+}
+
+// RecordAccounts records [accts] as the test accounts funded on the network
+// in [dataDir]
+func RecordAccounts(dataDir string, accts AccountsState) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return UpdateState(dataDir, func(state *State) error {
+		state.Accounts = &accts
+		return nil
+	})
+}
+
+// RecordedAccounts returns the test accounts funded on the running network,
+// if any
+func RecordedAccounts() (AccountsState, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := LoadState(networkDir)
+	if err != nil || state.Accounts == nil {
+		return AccountsState{}, false
+	}
+	return *state.Accounts, true
+}
+
+// Subnets returns the subnets created on the running network
+func Subnets() ([]SubnetState, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(networkDir) == 0 {
+		return nil, errNotRunning
+	}
+	state, err := LoadState(networkDir)
+	if err != nil {
+		return nil, err
+	}
+	return state.Subnets, nil
+}
+
+// DataDir returns the data dir of the network started by [StartNetwork]
+func DataDir() string {
+	lock.Lock()
+	defer lock.Unlock()
+	return networkDir
+}
+
+// subtract returns the elements of [a] that are not in [b]
+func subtract(a, b []string) []string {
+	var res []string
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			found = found || x == y
+		}
+		if !found {
+			res = append(res, x)
+		}
 	}
-	return err
+	return res
 }
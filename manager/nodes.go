@@ -0,0 +1,927 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/metrics"
+	"github.com/ava-labs/ava-sim/utils"
+
+	"github.com/ava-labs/avalanchego/staking"
+	"github.com/fatih/color"
+)
+
+// localNode is a node of the network running in this process
+type localNode struct {
+	index int
+	dir   string
+	// Avalanchego arguments of the node, used to restart it with an updated
+	// config
+	args []string
+	// Path to the avalanchego binary running the node, which runs in this
+	// process if empty. Changed before the node is restarted to upgrade it.
+	avalanchegoPath string
+	// Whether the node should be running and whether it was removed from the
+	// network, in which case it is never started again
+	running  bool
+	removed  bool
+	restarts chan restartRequest
+
+	// Process running the node (nil if stopped), closed once it exits, the
+	// exit code of its last process and whether the process is paused,
+	// guarded by [processLock] as they change when the process exits
+	processLock sync.Mutex
+	process     nodeProcess
+	exited      chan struct{}
+	exitCode    int
+	paused      bool
+}
+
+// restartRequest asks a node to restart with [args], or to stay stopped if
+// [args] is nil. The node stops once [stop] is closed, closes [stopped] and
+// starts again once [start] is closed.
+type restartRequest struct {
+	args    []string
+	stop    chan struct{}
+	stopped chan struct{}
+	start   chan struct{}
+}
+
+// NodeInfo describes a node of the running network
+type NodeInfo struct {
+	Name    string `json:"name"`
+	ID      string `json:"id"`
+	URL     string `json:"url"`
+	Running bool   `json:"running"`
+	// ID of the process running the node, which is this process unless it
+	// runs an avalanchego binary
+	PID int `json:"pid,omitempty"`
+	// Path to the avalanchego binary running the node
+	AvalancheGoPath string `json:"avalanchegoPath,omitempty"`
+	// Exit code of the last process of the node (-1 if it was killed by a
+	// signal)
+	ExitCode int  `json:"exitCode,omitempty"`
+	Paused   bool `json:"paused,omitempty"`
+	Removed  bool `json:"removed,omitempty"`
+	// How the node misbehaves, if it is byzantine
+	Byzantine *Behavior `json:"byzantine,omitempty"`
+}
+
+// newLocalNode configures the node at [index] of the network in [networkDir]
+// bootstrapping from the nodes at [bootstrappers]. [lock] must be held.
+func newLocalNode(index int, bootstrappers []int) (*localNode, error) {
+	nodeDir := fmt.Sprintf("%s/node%d", networkDir, index+1)
+	if err := os.MkdirAll(nodeDir, os.FileMode(constants.FilePerms)); err != nil {
+		return nil, err
+	}
+	certFile := fmt.Sprintf("%s/staker.crt", nodeDir)
+	if err := ioutil.WriteFile(certFile, nodeCerts[index], os.FileMode(constants.FilePerms)); err != nil {
+		return nil, err
+	}
+	keyFile := fmt.Sprintf("%s/staker.key", nodeDir)
+	if err := ioutil.WriteFile(keyFile, nodeKeys[index], os.FileMode(constants.FilePerms)); err != nil {
+		return nil, err
+	}
+
+	avalanchegoPath, ok := networkConfig.NodeAvalancheGoPaths[index]
+	if !ok {
+		avalanchegoPath = networkConfig.AvalancheGoPath
+	}
+	buildDir, err := buildDir(avalanchegoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	numNodes := networkConfig.NumNodes
+	df := defaultFlags()
+	df.LogLevel = networkConfig.LogLevel
+	df.LogDir = fmt.Sprintf("%s/logs", nodeDir)
+	df.DBDir = fmt.Sprintf("%s/db", nodeDir)
+	// Separate avalanchego processes load their plugins from
+	// [build-dir]/plugins
+	df.BuildDir = buildDir
+	// The local network genesis only includes the embedded key pairs as
+	// validators, so staking must be disabled if some of them are not
+	// running or consensus would sample offline validators.
+	df.StakingEnabled = numNodes >= len(embeddedCerts)
+	if numNodes < df.SnowSampleSize {
+		df.SnowSampleSize = numNodes
+		df.SnowQuorumSize = numNodes
+	}
+	if numNodes-1 < df.NetworkHealthMinConnPeers {
+		df.NetworkHealthMinConnPeers = numNodes - 1
+	}
+	// The ports of nodes added at runtime are allocated here
+	if index == len(nodePorts) {
+		p, err := allocatePorts(index)
+		if err != nil {
+			return nil, err
+		}
+		nodePorts = append(nodePorts, p)
+	}
+	df.HTTPPort = uint(nodePorts[index].http)
+	df.StakingPort = uint(nodePorts[index].staking)
+	if df.BootstrapIPs, df.BootstrapIDs, err = bootstrapFlags(index, bootstrappers); err != nil {
+		return nil, err
+	}
+	df.StakingTLSCertFile = certFile
+	df.StakingTLSKeyFile = keyFile
+	df.WhitelistedSubnets = whitelistFlag(index)
+
+	args := flagsToArgs(df)
+	if networkConfig.Faults {
+		args = applyFlagOverrides(args, faultFlags)
+	}
+	n := &localNode{
+		index:           index,
+		dir:             nodeDir,
+		args:            applyFlagOverrides(applyFlagOverrides(args, networkConfig.Flags), networkConfig.NodeFlags[index]),
+		avalanchegoPath: avalanchegoPath,
+		running:         true,
+		restarts:        make(chan restartRequest),
+	}
+	return n, n.checkArgs()
+}
+
+// bootstrapFlags returns the values of the bootstrap-ips and bootstrap-ids
+// flags making the node at [index] bootstrap from the nodes at
+// [bootstrappers], which it dials through the links injecting faults if
+// [Config.Faults] is set. [lock] must be held.
+func bootstrapFlags(index int, bootstrappers []int) (string, string, error) {
+	nodeIDs := NodeIDs()
+	var bootstrapIPs, bootstrapIDs []string
+	for _, i := range bootstrappers {
+		if i == index {
+			continue
+		}
+		addr := fmt.Sprintf("127.0.0.1:%d", nodePorts[i].staking)
+		if networkConfig.Faults {
+			var err error
+			if addr, err = linkAddr(index, i); err != nil {
+				return "", "", err
+			}
+		}
+		bootstrapIPs = append(bootstrapIPs, addr)
+		bootstrapIDs = append(bootstrapIDs, nodeIDs[i])
+	}
+	return strings.Join(bootstrapIPs, ","), strings.Join(bootstrapIDs, ","), nil
+}
+
+// checkArgs returns an error if the arguments of the node are invalid. The
+// arguments of nodes running an avalanchego binary are checked by the binary
+// instead, which may not be the version ava-sim is built with.
+func (n *localNode) checkArgs() error {
+	if len(n.avalanchegoPath) > 0 {
+		return nil
+	}
+	_, err := createNodeConfig(nodePluginsDir, n.args)
+	return err
+}
+
+// inProcess returns true if the node runs, or is going to run, in this
+// process. [lock] must be held.
+func (n *localNode) inProcess() bool {
+	n.processLock.Lock()
+	defer n.processLock.Unlock()
+	_, local := n.process.(*appProcess)
+	return local || (n.running && len(n.avalanchegoPath) == 0)
+}
+
+func (n *localNode) name() string {
+	return fmt.Sprintf("node%d", n.index+1)
+}
+
+// state returns the record of the node
+func (n *localNode) state() NodeState {
+	return NodeState{
+		ID:      NodeIDs()[n.index],
+		URL:     NodeURLs()[n.index],
+		Dir:     n.dir,
+		Removed: n.removed,
+	}
+}
+
+// NodeError is the error of a node that failed, which stops the network
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("%s %v", e.Node, e.Err)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// runApp runs [n] with [args] until [ctx] is done, restarting it whenever a
+// restart is requested. The node waits for a restart if [args] is nil or if
+// it exits on its own, and fails with a [NodeError] if it cannot run.
+func runApp(ctx context.Context, n *localNode, args []string) error {
+	type exit struct {
+		code int
+		err  error
+	}
+	nodeNum := n.index
+	nodeID := NodeIDs()[n.index]
+	restarts := n.restarts
+	started := false
+	for {
+		if args == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case req := <-restarts:
+				select {
+				case <-req.stop:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				close(req.stopped)
+				select {
+				case <-req.start:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				args = req.args
+			}
+			continue
+		}
+
+		// Start running the AvalancheGo application
+		p, err := startProcess(n, args)
+		if err != nil {
+			return &NodeError{Node: n.name(), Err: fmt.Errorf("failed to start: %w", err)}
+		}
+		n.setProcess(p)
+		if started {
+			metrics.NodeRestarted(n.name(), nodeID)
+		}
+		started = true
+
+		exited := make(chan exit, 1)
+		go func() {
+			exitCode, err := p.wait()
+			n.setExited(exitCode)
+			exited <- exit{code: exitCode, err: err}
+		}()
+
+		select {
+		case <-ctx.Done():
+			_ = p.stop()
+			<-exited
+			return ctx.Err()
+		case e := <-exited:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if e.err != nil {
+				return &NodeError{Node: n.name(), Err: fmt.Errorf("exited: %w", e.err)}
+			}
+			color.Red("node%d exited with code %d", nodeNum+1, e.code)
+			args = nil
+		case req := <-restarts:
+			select {
+			case <-req.stop:
+			case <-ctx.Done():
+				_ = p.stop()
+				<-exited
+				return ctx.Err()
+			}
+			if req.args != nil {
+				color.Cyan("restarting node%d", nodeNum+1)
+			} else {
+				color.Cyan("stopping node%d", nodeNum+1)
+			}
+			_ = p.stop()
+			<-exited
+			close(req.stopped)
+			select {
+			case <-req.start:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			args = req.args
+		}
+	}
+}
+
+// setProcess records that the node runs in [p]
+func (n *localNode) setProcess(p nodeProcess) {
+	n.processLock.Lock()
+	defer n.processLock.Unlock()
+	n.process = p
+	n.exited = make(chan struct{})
+	n.paused = false
+}
+
+// setExited records that the process of the node exited with [exitCode]
+func (n *localNode) setExited(exitCode int) {
+	n.processLock.Lock()
+	defer n.processLock.Unlock()
+	n.process = nil
+	n.exitCode = exitCode
+	n.paused = false
+	close(n.exited)
+}
+
+// alive returns true if the process of the node is running
+func (n *localNode) alive() bool {
+	n.processLock.Lock()
+	defer n.processLock.Unlock()
+	return n.process != nil
+}
+
+// info returns the description of the node
+func (n *localNode) info() NodeInfo {
+	n.processLock.Lock()
+	defer n.processLock.Unlock()
+	pid := 0
+	if n.process != nil {
+		pid = n.process.pid()
+	}
+	var byzantine *Behavior
+	if b, ok := networkConfig.Behaviors[n.index]; ok && !b.Zero() {
+		byzantine = &b
+	}
+	return NodeInfo{
+		Name:            n.name(),
+		ID:              NodeIDs()[n.index],
+		URL:             NodeURLs()[n.index],
+		Running:         n.running,
+		PID:             pid,
+		AvalancheGoPath: n.avalanchegoPath,
+		ExitCode:        n.exitCode,
+		Paused:          n.paused,
+		Removed:         n.removed,
+		Byzantine:       byzantine,
+	}
+}
+
+// restartNodes stops the nodes at [indices] and starts the ones that should
+// be running again with their current arguments, waiting for them to
+// bootstrap. Byzantine nodes behave honestly meanwhile. Only waiting for the
+// nodes to bootstrap is interrupted once [ctx] is done. [lock] must be held.
+// It is released while waiting for the nodes to bootstrap, so that the
+// network can be queried and changed meanwhile, and held again on return.
+func restartNodes(ctx context.Context, indices []int) error {
+	restoreBehaviors := behaveHonestly()
+	defer restoreBehaviors()
+
+	// Nodes that fail to restart stop the network
+	ctx, cancel := withNetwork(ctx)
+	defer cancel()
+
+	started, err := cycleNodes(indices)
+	if err != nil {
+		return err
+	}
+	peers := len(runningNodes()) - 1
+	lock.Unlock()
+	err = waitBootstrapped(ctx, started, peers)
+	lock.Lock()
+	if err == nil && len(nodes) == 0 {
+		// The network stopped meanwhile
+		return errNotRunning
+	}
+	return err
+}
+
+// cycleNodes stops the nodes at [indices] and starts the ones that should be
+// running again with their current arguments, returning the indices of the
+// started nodes. Once a node is handed its restart request, the nodes are
+// always stopped and started, as they would otherwise wait for it forever, so
+// this is only interrupted by the network stopping. [lock] must be held.
+func cycleNodes(indices []int) ([]int, error) {
+	ctx, cancel := withNetwork(context.Background())
+	defer cancel()
+
+	// Stopping a node kills the VM plugin processes of all nodes running in
+	// this process, so they must all be stopped before any is restarted.
+	for _, i := range indices {
+		if !nodes[i].inProcess() {
+			continue
+		}
+		restart := make(map[int]bool)
+		for _, j := range indices {
+			restart[j] = true
+		}
+		requested := len(indices)
+		indices = nil
+		for j, n := range nodes {
+			if restart[j] || n.inProcess() {
+				indices = append(indices, j)
+			}
+		}
+		if len(indices) > requested {
+			color.Yellow("also restarting the other nodes running in the ava-sim process, as they share their VM plugins (start the network with --avalanchego-path to restart nodes on their own)")
+		}
+		break
+	}
+
+	var (
+		stop     = make(chan struct{})
+		start    = make(chan struct{})
+		requests = make([]restartRequest, len(indices))
+		started  []int
+	)
+	for j, i := range indices {
+		n := nodes[i]
+		requests[j] = restartRequest{
+			stop:    stop,
+			stopped: make(chan struct{}),
+			start:   start,
+		}
+		if !n.running {
+			continue
+		}
+		if err := n.checkArgs(); err != nil {
+			return nil, err
+		}
+		requests[j].args = append([]string(nil), n.args...)
+		started = append(started, i)
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			close(stop)
+		}
+		close(start)
+	}()
+	for j, req := range requests {
+		select {
+		case nodes[indices[j]].restarts <- req:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	close(stop)
+	stopped = true
+	for _, req := range requests {
+		select {
+		case <-req.stopped:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return started, nil
+}
+
+// allNodes returns the indices of all nodes. [lock] must be held.
+func allNodes() []int {
+	indices := make([]int, len(nodes))
+	for i := range nodes {
+		indices[i] = i
+	}
+	return indices
+}
+
+// runningNodes returns the indices of the nodes that should be running.
+// [lock] must be held.
+func runningNodes() []int {
+	var running []int
+	for i, n := range nodes {
+		if n.running {
+			running = append(running, i)
+		}
+	}
+	return running
+}
+
+// waitBootstrapped waits for the nodes at [indices] to bootstrap the primary
+// network and connect to at least [peers] peers, failing once
+// [Config.BootstrapTimeout] passed
+func waitBootstrapped(ctx context.Context, indices []int, peers int) error {
+	var (
+		nodeURLs = NodeURLs()
+		nodeIDs  = NodeIDs()
+	)
+
+	return health.Phase(ctx, health.NetworkBootstrap, networkConfig.BootstrapTimeout, func(ctx context.Context) error {
+		for _, i := range indices {
+			node := health.Node{
+				Name: fmt.Sprintf("node%d", i+1),
+				ID:   nodeIDs[i],
+				URL:  nodeURLs[i],
+			}
+			if _, err := health.Wait(ctx, node, health.PrimaryNetwork(peers), waitDiff); err != nil {
+				return err
+			}
+			color.Cyan("%s is bootstrapped and connected", nodeIDs[i])
+		}
+		return nil
+	})
+}
+
+// Nodes returns the nodes of the running network
+func Nodes() []NodeInfo {
+	lock.Lock()
+	defer lock.Unlock()
+
+	infos := make([]NodeInfo, len(nodes))
+	for i, n := range nodes {
+		infos[i] = n.info()
+	}
+	return infos
+}
+
+// nodeIndex returns the index of the node named [name]. [lock] must be held.
+func nodeIndex(name string) (int, error) {
+	index, err := strconv.Atoi(strings.TrimPrefix(name, "node"))
+	if !strings.HasPrefix(name, "node") || err != nil || index < 1 || index > len(nodes) {
+		return 0, invalidf("unknown node %q", name)
+	}
+	return index - 1, nil
+}
+
+// StopNode stops the node named [name]. Only nodes running an avalanchego
+// binary can be stopped: nodes running in this process share their VM plugin
+// processes, so stopping one would restart all the others.
+func StopNode(ctx context.Context, name string) error {
+	return setRunning(ctx, name, false)
+}
+
+// StartNode starts the node named [name] if it was stopped, killed or exited
+// on its own. The other nodes running in this process are restarted as well,
+// as they share their VM plugin processes.
+func StartNode(ctx context.Context, name string) error {
+	return setRunning(ctx, name, true)
+}
+
+// RestartNode restarts the node named [name] with the same staking key and
+// DB. The other nodes running in this process are restarted as well (see
+// [StartNode]).
+func RestartNode(ctx context.Context, name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	index, err := nodeIndex(name)
+	if err != nil {
+		return err
+	}
+	if !nodes[index].running {
+		return invalidf("%s is not running", name)
+	}
+	return restartNodes(ctx, []int{index})
+}
+
+func setRunning(ctx context.Context, name string, running bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	index, err := nodeIndex(name)
+	if err != nil {
+		return err
+	}
+	n := nodes[index]
+	if n.removed {
+		return invalidf("%s was removed from the network", name)
+	}
+	if !running && n.running && n.inProcess() {
+		return errStopInProcess
+	}
+	// Nodes that exited on their own are started again
+	if n.running == running && (!running || n.alive()) {
+		return nil
+	}
+	n.running = running
+	return restartNodes(ctx, []int{index})
+}
+
+// KillNode kills the process of the node named [name] and its VM plugin
+// processes, as if the node crashed, and waits for it to exit. The node stays
+// down until it is started again. Only nodes running an avalanchego binary can
+// be killed.
+func KillNode(ctx context.Context, name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	index, err := nodeIndex(name)
+	if err != nil {
+		return err
+	}
+	n := nodes[index]
+	n.processLock.Lock()
+	p, exited := n.process, n.exited
+	n.processLock.Unlock()
+	if p == nil {
+		return invalidf("%s is not running", name)
+	}
+	if err := p.kill(); err != nil {
+		return err
+	}
+	n.running = false
+	color.Cyan("killed node%d", index+1)
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PauseNode freezes the process of the node named [name] and its VM plugin
+// processes with SIGSTOP, as if the node hung, until [ResumeNode] is called.
+// Its peers keep their connections to it open. Only nodes running an
+// avalanchego binary can be paused.
+func PauseNode(name string) error {
+	return setPaused(name, true)
+}
+
+// ResumeNode resumes the node named [name] paused by [PauseNode]
+func ResumeNode(name string) error {
+	return setPaused(name, false)
+}
+
+func setPaused(name string, paused bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	index, err := nodeIndex(name)
+	if err != nil {
+		return err
+	}
+	n := nodes[index]
+	n.processLock.Lock()
+	defer n.processLock.Unlock()
+	if n.process == nil {
+		return invalidf("%s is not running", name)
+	}
+	if n.paused == paused {
+		return nil
+	}
+	if paused {
+		err = n.process.pause()
+	} else {
+		err = n.process.resume()
+	}
+	if err != nil {
+		return err
+	}
+	n.paused = paused
+	if paused {
+		color.Cyan("paused node%d", index+1)
+	} else {
+		color.Cyan("resumed node%d", index+1)
+	}
+	return nil
+}
+
+// AddNode adds a node with a fresh staking key to the running network and
+// waits for it to bootstrap. A node that fails to bootstrap is stopped and
+// removed from the network again, along with its data dir.
+func AddNode(ctx context.Context) (NodeInfo, error) {
+	// Nodes are added one at a time, so that a node that failed to be added
+	// is the last one when it is removed
+	adding.Lock()
+	defer adding.Unlock()
+
+	lock.Lock()
+	if len(nodes) == 0 {
+		lock.Unlock()
+		return NodeInfo{}, errNotRunning
+	}
+	index, dir := len(nodes), networkDir
+	cert, key, err := staking.NewCertAndKeyBytes()
+	if err != nil {
+		lock.Unlock()
+		return NodeInfo{}, fmt.Errorf("could not generate staking key pair: %w", err)
+	}
+	// Arguments of the other nodes, restored if the node is removed
+	otherArgs := make([][]string, index)
+	for i, other := range nodes {
+		otherArgs[i] = other.args
+	}
+	if err := setStakingKeys(append(nodeCerts, cert), append(nodeKeys, key)); err != nil {
+		lock.Unlock()
+		return NodeInfo{}, err
+	}
+	n, err := newLocalNode(index, runningNodes())
+	if err != nil {
+		discardNode(index, otherArgs)
+		lock.Unlock()
+		return NodeInfo{}, fmt.Errorf("could not configure node%d: %w", index+1, err)
+	}
+	nodes = append(nodes, n)
+	if networkConfig.Faults {
+		// The other nodes connect to the new node themselves when they
+		// restart, as it does not connect to them again
+		if err := addBootstrapper(index); err != nil {
+			discardNode(index, otherArgs)
+			lock.Unlock()
+			return NodeInfo{}, err
+		}
+	}
+	err = UpdateState(dir, func(state *State) error {
+		state.Nodes = append(state.Nodes, n.state())
+		return nil
+	})
+	if err != nil {
+		discardNode(index, otherArgs)
+		lock.Unlock()
+		return NodeInfo{}, fmt.Errorf("could not save network state: %w", err)
+	}
+	args := append([]string(nil), n.args...)
+	restoreBehaviors := behaveHonestly()
+	nodeGroup.Go(func() error {
+		return runApp(nodeCtx, n, args)
+	})
+	peers := len(runningNodes()) - 1
+	// A node that fails to start stops the network
+	ctx, cancel := withNetwork(ctx)
+	defer cancel()
+	lock.Unlock()
+
+	color.Cyan("added node%d", index+1)
+	err = waitBootstrapped(ctx, []int{index}, peers)
+	lock.Lock()
+	defer lock.Unlock()
+	restoreBehaviors()
+	if err == nil {
+		return n.info(), nil
+	}
+
+	color.Red("removing node%d as it could not be added: %v", index+1, err)
+	if len(nodes) > index && nodes[index] == n {
+		// The node waits for a restart request once stopped, until the
+		// network stops, as it is never started again
+		n.running = false
+		if stopErr := restartNodes(context.Background(), []int{index}); stopErr != nil {
+			color.Red("could not stop node%d: %v", index+1, stopErr)
+		} else if rmErr := os.RemoveAll(n.dir); rmErr != nil {
+			color.Red("could not remove the data dir of node%d: %v", index+1, rmErr)
+		}
+	}
+	if len(nodes) > index && nodes[index] == n {
+		discardNode(index, otherArgs)
+	}
+	// The node is not started again if the network stopped meanwhile and is
+	// resumed
+	stateErr := UpdateState(dir, func(state *State) error {
+		if len(state.Nodes) == index+1 {
+			state.Nodes = state.Nodes[:index]
+		}
+		return nil
+	})
+	if stateErr != nil {
+		color.Red("could not save network state: %v", stateErr)
+	}
+	return NodeInfo{}, err
+}
+
+// discardNode forgets the node at [index], the last node of the network, and
+// restores [otherArgs], the arguments the other nodes had before it was
+// added. [lock] must be held.
+func discardNode(index int, otherArgs [][]string) {
+	for i, args := range otherArgs {
+		nodes[i].args = args
+	}
+	if len(nodes) > index {
+		nodes = nodes[:index]
+	}
+	_ = setStakingKeys(nodeCerts[:index], nodeKeys[:index])
+	if len(nodePorts) > index {
+		nodePorts = nodePorts[:index]
+	}
+	forgetNodeLinks(index)
+}
+
+// RemoveNode stops the node named [name] and removes it from the network, so
+// that it is not started again, even when the network is resumed. Its data
+// dir is kept. Validators cannot leave the validator sets before the end of
+// their validation period with this avalanchego version, so a validator stays
+// in them, offline, until then.
+func RemoveNode(ctx context.Context, name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	index, err := nodeIndex(name)
+	if err != nil {
+		return err
+	}
+	n := nodes[index]
+	if n.removed {
+		return nil
+	}
+	n.removed = true
+	err = UpdateState(networkDir, func(state *State) error {
+		state.Nodes[index].Removed = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not save network state: %w", err)
+	}
+	color.Cyan("removing node%d", index+1)
+	if !n.running {
+		return nil
+	}
+	n.running = false
+	return restartNodes(ctx, []int{index})
+}
+
+// InstallVM installs the VM binary at [vmPath] under [vmID] on all nodes.
+// Nodes only load VMs on startup, so they are restarted.
+func InstallVM(ctx context.Context, vmID string, vmPath string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	// The VM is installed again from its recorded path when the network is
+	// resumed, possibly from another directory
+	vmPath, err := absPath(vmPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(vmPath); err != nil {
+		return invalidf("invalid VM binary: %w", err)
+	}
+	for _, dir := range buildDirs {
+		if err := utils.CopyFile(vmPath, fmt.Sprintf("%s/plugins/%s", dir, vmID)); err != nil {
+			return fmt.Errorf("could not install VM %s: %w", vmPath, err)
+		}
+	}
+	networkConfig.VMs[vmID] = vmPath
+	err = UpdateState(networkDir, func(state *State) error {
+		if state.VMs == nil {
+			state.VMs = make(map[string]string)
+		}
+		state.VMs[vmID] = vmPath
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return restartNodes(ctx, allNodes())
+}
+
+// UpgradeNodes restarts the nodes named [names] (all nodes if empty) with the
+// avalanchego binary at [avalanchegoPath] one at a time, waiting for each to
+// bootstrap before upgrading the next. The nodes keep their staking keys and
+// DBs. Stopped nodes run the new binary once they are started.
+func UpgradeNodes(ctx context.Context, avalanchegoPath string, names []string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(nodes) == 0 {
+		return errNotRunning
+	}
+	path, err := absPath(avalanchegoPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return invalidf("invalid avalanchego binary: %w", err)
+	}
+	indices := allNodes()
+	if len(names) > 0 {
+		indices = make([]int, len(names))
+		for i, name := range names {
+			if indices[i], err = nodeIndex(name); err != nil {
+				return err
+			}
+		}
+	}
+	dir, err := buildDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range indices {
+		n := nodes[i]
+		color.Cyan("upgrading node%d to %s", i+1, path)
+		n.avalanchegoPath = path
+		n.args = applyFlagOverrides(n.args, map[string]string{"build-dir": dir})
+		if !n.running {
+			continue
+		}
+		if err := restartNodes(ctx, []int{i}); err != nil {
+			return fmt.Errorf("could not upgrade node%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ava-labs/ava-sim/proxy"
+)
+
+func TestNodeIndex(t *testing.T) {
+	defer func(n []*localNode) { nodes = n }(nodes)
+	nodes = make([]*localNode, 3)
+
+	tests := []struct {
+		name  string
+		index int
+		err   bool
+	}{
+		{"node1", 0, false},
+		{"node3", 2, false},
+		{"node0", 0, true},
+		{"node4", 0, true},
+		{"node", 0, true},
+		{"1", 0, true},
+		{"Node1", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, err := nodeIndex(test.name)
+			if test.err {
+				var invalid *ValidationError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("expected a validation error but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if index != test.index {
+				t.Fatalf("expected index %d but got %d", test.index, index)
+			}
+		})
+	}
+}
+
+func TestFaultsInProcess(t *testing.T) {
+	defer func(n []*localNode) { nodes = n }(nodes)
+	nodes = []*localNode{
+		{index: 0, running: true, process: &appProcess{}},
+	}
+
+	tests := []struct {
+		name   string
+		action func() error
+		err    error
+	}{
+		{"stop", func() error { return StopNode(context.Background(), "node1") }, errStopInProcess},
+		{"kill", func() error { return KillNode(context.Background(), "node1") }, errKillInProcess},
+		{"pause", func() error { return PauseNode("node1") }, errPauseInProcess},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.action()
+			if err != test.err {
+				t.Fatalf("expected %v but got %v", test.err, err)
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected a validation error but got %v", err)
+			}
+			if !nodes[0].running {
+				t.Fatal("expected node1 to keep running")
+			}
+		})
+	}
+}
+
+func TestDiscardNode(t *testing.T) {
+	defer func(n []*localNode, p []ports, f map[pairKey]proxy.Faults) {
+		nodes, nodePorts, pairFaults = n, p, f
+	}(nodes, nodePorts, pairFaults)
+	if err := loadStakingKeys(3); err != nil {
+		t.Fatal(err)
+	}
+	otherArgs := [][]string{{"--node1"}, {"--node2"}}
+	nodes = []*localNode{
+		{index: 0, args: []string{"--node1", "--bootstrap-ips=node3"}},
+		{index: 1, args: []string{"--node2", "--bootstrap-ips=node3"}},
+		{index: 2},
+	}
+	nodePorts = make([]ports, 3)
+	pairFaults = map[pairKey]proxy.Faults{
+		pairOf(0, 1): {Partitioned: true},
+		pairOf(0, 2): {Partitioned: true},
+		pairOf(1, 2): {Partitioned: true},
+	}
+
+	discardNode(2, otherArgs)
+	if len(nodes) != 2 || len(nodePorts) != 2 || len(NodeIDs()) != 2 {
+		t.Fatalf("expected 2 nodes but got %d nodes, %d ports and %d node IDs", len(nodes), len(nodePorts), len(NodeIDs()))
+	}
+	for i, n := range nodes {
+		if !reflect.DeepEqual(n.args, otherArgs[i]) {
+			t.Fatalf("expected node%d to have args %q but got %q", i+1, otherArgs[i], n.args)
+		}
+	}
+	expected := map[pairKey]proxy.Faults{pairOf(0, 1): {Partitioned: true}}
+	if !reflect.DeepEqual(pairFaults, expected) {
+		t.Fatalf("expected faults %v but got %v", expected, pairFaults)
+	}
+}
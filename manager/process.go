@@ -0,0 +1,246 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/utils"
+
+	"github.com/ava-labs/avalanchego/app"
+	"github.com/ava-labs/avalanchego/app/process"
+)
+
+var (
+	errStopInProcess  = &ValidationError{Err: errors.New("nodes running in the ava-sim process cannot be stopped on their own: start the network with --avalanchego-path [binary] to run each node in its own process")}
+	errKillInProcess  = &ValidationError{Err: errors.New("nodes running in the ava-sim process cannot be killed: start the network with --avalanchego-path [binary] to run each node in its own process")}
+	errPauseInProcess = &ValidationError{Err: errors.New("nodes running in the ava-sim process cannot be paused: start the network with --avalanchego-path [binary] to run each node in its own process")}
+	errProcessExited  = errors.New("node process already exited")
+)
+
+const (
+	// Name of the file in each node directory capturing the output of the
+	// node's avalanchego process
+	processLogFile = "process.log"
+	// Name of the file in each node directory recording the avalanchego
+	// flags the node was last started with
+	flagsFile = "flags.json"
+	// Time given to an avalanchego process to exit after SIGTERM before it is
+	// killed
+	processStopTimeout = 30 * time.Second
+)
+
+// nodeProcess is a running avalanchego node
+type nodeProcess interface {
+	// wait waits for the node to exit and returns its exit code
+	wait() (int, error)
+	// stop makes the node exit
+	stop() error
+	// kill makes the node exit immediately, as if it crashed
+	kill() error
+	// pause freezes the node until resume is called, as if it hung
+	pause() error
+	resume() error
+	// pid returns the ID of the process running the node
+	pid() int
+}
+
+// startProcess starts [n] with [args], in this process or as a separate
+// process if the node runs an avalanchego binary
+func startProcess(n *localNode, args []string) (nodeProcess, error) {
+	b, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(n.dir, flagsFile), b, os.FileMode(constants.FilePerms)); err != nil {
+		return nil, fmt.Errorf("could not record flags: %w", err)
+	}
+	if len(n.avalanchegoPath) > 0 {
+		return startExecProcess(n.avalanchegoPath, n.dir, args)
+	}
+	config, err := createNodeConfig(nodePluginsDir, args)
+	if err != nil {
+		return nil, err
+	}
+	config.PluginDir = nodePluginsDir
+	a := process.NewApp(config)
+	if err := a.Start(); err != nil {
+		return nil, err
+	}
+	return &appProcess{app: a}, nil
+}
+
+// appProcess is a node running in this process
+type appProcess struct {
+	app app.App
+}
+
+func (p *appProcess) wait() (int, error) { return p.app.ExitCode() }
+func (p *appProcess) stop() error        { return p.app.Stop() }
+func (p *appProcess) kill() error        { return errKillInProcess }
+func (p *appProcess) pause() error       { return errPauseInProcess }
+func (p *appProcess) resume() error      { return errPauseInProcess }
+func (p *appProcess) pid() int           { return os.Getpid() }
+
+// execProcess is a node running in a separate avalanchego process
+type execProcess struct {
+	cmd *exec.Cmd
+	log *os.File
+
+	// Timer killing the process if it does not exit in time once stopped,
+	// and whether it exited, guarded by [lock]
+	lock      sync.Mutex
+	killTimer *time.Timer
+	exited    bool
+}
+
+// startExecProcess runs the avalanchego binary at [path] with [args],
+// writing its output to [processLogFile] in [dir]. The process runs in its
+// own process group so that it can be killed along with its VM plugin
+// processes.
+func startExecProcess(path string, dir string, args []string) (*execProcess, error) {
+	log, err := os.OpenFile(filepath.Join(dir, processLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("could not run %s: %w", path, err)
+	}
+	return &execProcess{cmd: cmd, log: log}, nil
+}
+
+func (p *execProcess) wait() (int, error) {
+	defer p.log.Close()
+
+	err := p.cmd.Wait()
+	// The process group must not be killed once the process exited, as its
+	// ID may be reused
+	p.lock.Lock()
+	p.exited = true
+	if p.killTimer != nil {
+		p.killTimer.Stop()
+	}
+	p.lock.Unlock()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+func (p *execProcess) stop() error {
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// A paused process only handles SIGTERM once it is resumed
+	_ = p.signalGroup(syscall.SIGCONT)
+	if !p.exited && p.killTimer == nil {
+		p.killTimer = time.AfterFunc(processStopTimeout, func() {
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			_ = p.signalGroup(syscall.SIGKILL)
+		})
+	}
+	return nil
+}
+
+// kill kills the process group, unless the process already exited
+func (p *execProcess) kill() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.exited {
+		return nil
+	}
+	return p.signalGroup(syscall.SIGKILL)
+}
+
+func (p *execProcess) pause() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.signalGroup(syscall.SIGSTOP)
+}
+
+// resume resumes the process group, unless the process already exited
+func (p *execProcess) resume() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.exited {
+		return nil
+	}
+	return p.signalGroup(syscall.SIGCONT)
+}
+
+// signalGroup sends [sig] to the process group of the process. The process
+// group is not signaled once the process exited, as its ID may be reused.
+// [lock] must be held.
+func (p *execProcess) signalGroup(sig syscall.Signal) error {
+	if p.exited {
+		return errProcessExited
+	}
+	return syscall.Kill(-p.cmd.Process.Pid, sig)
+}
+
+func (p *execProcess) pid() int { return p.cmd.Process.Pid }
+
+// buildDir returns the build dir of the nodes running the avalanchego binary
+// at [avalanchegoPath], or running in this process if it is empty. The C-chain
+// plugin and the custom VMs are installed in its plugins dir when it is first
+// used. [lock] must be held.
+func buildDir(avalanchegoPath string) (string, error) {
+	if dir, ok := buildDirs[avalanchegoPath]; ok {
+		return dir, nil
+	}
+
+	dir := networkDir
+	evmPath := networkConfig.EVMPath
+	if len(avalanchegoPath) > 0 {
+		// Each binary loads its plugins from its own build dir so that it
+		// runs with the C-chain plugin released with it
+		dir = filepath.Join(networkDir, "builds", strconv.Itoa(len(buildDirs)))
+		if len(evmPath) == 0 {
+			// Layout of the avalanchego release archives
+			evmPath = filepath.Join(filepath.Dir(avalanchegoPath), "plugins", "evm")
+		}
+	} else if len(evmPath) == 0 {
+		evmPath = defaultEVMPath
+	}
+	pluginsDir := filepath.Join(dir, "plugins")
+	if err := os.MkdirAll(pluginsDir, os.FileMode(constants.FilePerms)); err != nil {
+		return "", err
+	}
+	if err := utils.CopyFile(evmPath, filepath.Join(pluginsDir, "evm")); err != nil {
+		return "", fmt.Errorf("could not install the C-chain plugin: %w", err)
+	}
+	for vmID, vmPath := range networkConfig.VMs {
+		if err := utils.CopyFile(vmPath, filepath.Join(pluginsDir, vmID)); err != nil {
+			return "", fmt.Errorf("could not install VM %s: %w", vmPath, err)
+		}
+	}
+	buildDirs[avalanchegoPath] = dir
+	return dir, nil
+}
+
+// absPath returns the absolute form of [path], or an empty path if it is empty
+func absPath(path string) (string, error) {
+	if len(path) == 0 {
+		return "", nil
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestExecProcessExited(t *testing.T) {
+	path, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("true is not available")
+	}
+	p, err := startExecProcess(path, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := p.wait(); err != nil || code != 0 {
+		t.Fatalf("expected the process to exit with code 0 but got %d (%v)", code, err)
+	}
+
+	// The process group of an exited process is never signaled, as its ID
+	// may have been reused
+	tests := []struct {
+		name   string
+		action func() error
+		err    error
+	}{
+		{"kill", p.kill, nil},
+		{"pause", p.pause, errProcessExited},
+		{"resume", p.resume, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.action(); err != test.err {
+				t.Fatalf("expected %v but got %v", test.err, err)
+			}
+		})
+	}
+	if err := p.stop(); err == nil {
+		t.Fatal("expected an error stopping an exited process")
+	}
+	if p.killTimer != nil {
+		t.Fatal("expected no kill timer for an exited process")
+	}
+}
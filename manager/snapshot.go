@@ -0,0 +1,244 @@
+package manager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ava-labs/ava-sim/constants"
+)
+
+// Files of each node directory included in a snapshot (plugins and logs are
+// not)
+var snapshotNodeFiles = []string{"staker.crt", "staker.key", "db"}
+
+// Directory of a snapshot holding the binaries of the VMs of the network,
+// named after their ID, which the network is resumed with once the snapshot
+// is loaded
+const snapshotVMsDir = "vms"
+
+// SaveSnapshot archives the state of the stopped network in [dataDir] (its
+// record, the staking keys and DB of each node and the binaries of its VMs)
+// to [path]
+func SaveSnapshot(dataDir string, path string) error {
+	state, err := LoadState(dataDir)
+	if err != nil {
+		return fmt.Errorf("could not find network in %s: %w", dataDir, err)
+	}
+	if state.Running() {
+		return fmt.Errorf("network in %s is still running (pid %d)", dataDir, state.PID)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(constants.FilePerms)); err != nil {
+		return err
+	}
+	// The snapshot is written next to [path] first, so that a snapshot
+	// already saved under the same name is left as is if this save fails
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	// Readable as if created with os.Create
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	files := []string{stateFile}
+	for _, n := range state.Nodes {
+		for _, file := range snapshotNodeFiles {
+			files = append(files, filepath.Join(filepath.Base(n.Dir), file))
+		}
+	}
+	for _, file := range files {
+		if err := archive(tw, dataDir, file); err != nil {
+			return fmt.Errorf("could not archive %s: %w", file, err)
+		}
+	}
+	vmIDs := make([]string, 0, len(state.VMs))
+	for vmID := range state.VMs {
+		vmIDs = append(vmIDs, vmID)
+	}
+	sort.Strings(vmIDs)
+	for _, vmID := range vmIDs {
+		info, err := os.Stat(state.VMs[vmID])
+		if err == nil {
+			err = archiveFile(tw, state.VMs[vmID], snapshotVMsDir+"/"+vmID, info)
+		}
+		if err != nil {
+			return fmt.Errorf("could not archive VM %s: %w", vmID, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// archive writes [file] (relative to [dir]) and, if it is a directory, all
+// of its contents to [tw]
+func archive(tw *tar.Writer, dir string, file string) error {
+	return filepath.Walk(filepath.Join(dir, file), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return archiveFile(tw, path, filepath.ToSlash(name), info)
+	})
+}
+
+// archiveFile writes the file at [path], described by [info], to [tw] as
+// [name]
+func archiveFile(tw *tar.Writer, path string, name string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(tw, in)
+	return err
+}
+
+// LoadSnapshot replaces the contents of [dataDir] with the network archived
+// at [path]. The network can then be resumed with [StartNetwork], which
+// installs the VMs of the snapshot again. The archive
+// is extracted next to [dataDir] first, so that [dataDir] is left as is if
+// the archive is not a valid snapshot.
+func LoadSnapshot(path string, dataDir string) error {
+	if state, err := LoadState(dataDir); err == nil && state.Running() {
+		return fmt.Errorf("network in %s is still running (pid %d)", dataDir, state.PID)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("could not read snapshot %s: %w", path, err)
+	}
+
+	dataDir = filepath.Clean(dataDir)
+	if err := os.MkdirAll(filepath.Dir(dataDir), os.FileMode(constants.FilePerms)); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dataDir), "."+filepath.Base(dataDir)+"-snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, os.FileMode(constants.FilePerms)); err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read snapshot %s: %w", path, err)
+		}
+		if err := extract(tr, header, tmpDir); err != nil {
+			return fmt.Errorf("could not extract %s: %w", header.Name, err)
+		}
+	}
+
+	// Node directories are recorded with absolute paths, which must point to
+	// the new data dir
+	state, err := LoadState(tmpDir)
+	if err != nil {
+		return fmt.Errorf("snapshot %s has no network record: %w", path, err)
+	}
+	state.PID = 0
+	state.PendingSubnets = nil
+	for i := range state.Nodes {
+		state.Nodes[i].Dir = filepath.Join(dataDir, filepath.Base(state.Nodes[i].Dir))
+	}
+	// The VMs are installed from the snapshot when the network is resumed.
+	// Snapshots saved without them keep the recorded binaries.
+	for vmID := range state.VMs {
+		if _, err := os.Stat(filepath.Join(tmpDir, snapshotVMsDir, vmID)); err == nil {
+			state.VMs[vmID] = filepath.Join(dataDir, snapshotVMsDir, vmID)
+		}
+	}
+	if err := state.Save(tmpDir); err != nil {
+		return err
+	}
+	return replaceDir(tmpDir, dataDir)
+}
+
+// replaceDir replaces [dir] with [newDir], restoring [dir] if it cannot
+func replaceDir(newDir string, dir string) error {
+	oldDir := newDir + "-old"
+	switch err := os.Rename(dir, oldDir); {
+	case errors.Is(err, os.ErrNotExist):
+		return os.Rename(newDir, dir)
+	case err != nil:
+		return err
+	}
+	if err := os.Rename(newDir, dir); err != nil {
+		_ = os.Rename(oldDir, dir)
+		return err
+	}
+	return os.RemoveAll(oldDir)
+}
+
+// extract writes the entry described by [header] to [dir]
+func extract(tr *tar.Reader, header *tar.Header, dir string) error {
+	path := filepath.Join(dir, filepath.FromSlash(header.Name))
+	if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return fmt.Errorf("invalid path %s", header.Name)
+	}
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(path, os.FileMode(constants.FilePerms))
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(path), os.FileMode(constants.FilePerms)); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if _, err := io.Copy(out, tr); err != nil {
+			return err
+		}
+		return out.Close()
+	default:
+		return fmt.Errorf("unsupported file type %c", header.Typeflag)
+	}
+}
@@ -0,0 +1,184 @@
+package manager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeNetwork writes a stopped network of a single node to [dir]
+func writeNetwork(t *testing.T, dir string, nodeID string) {
+	nodeDir := filepath.Join(dir, "node1")
+	if err := os.MkdirAll(filepath.Join(nodeDir, "db"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"staker.crt", "staker.key", "db/data"} {
+		if err := ioutil.WriteFile(filepath.Join(nodeDir, file), []byte(nodeID), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := &State{Nodes: []NodeState{{ID: nodeID, Dir: nodeDir}}}
+	if err := state.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	writeNetwork(t, src, "NodeID-src")
+	writeNetwork(t, dst, "NodeID-dst")
+	snapshot := filepath.Join(root, "snapshot.tar.gz")
+	if err := SaveSnapshot(src, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadSnapshot(snapshot, dst); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadState(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Nodes[0].ID != "NodeID-src" || state.Nodes[0].Dir != filepath.Join(dst, "node1") {
+		t.Fatalf("unexpected node record %+v", state.Nodes[0])
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dst, "node1", "db", "data"))
+	if err != nil || string(b) != "NodeID-src" {
+		t.Fatalf("unexpected node DB %q (%v)", b, err)
+	}
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected no leftover directories but got %d files", len(files))
+	}
+}
+
+func TestLoadSnapshotVMs(t *testing.T) {
+	const vmID = "tGas3T58KzdjLHhBDMnH2TvrddhqTji5iZAMZ3RXs2NLpSnhH"
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "fresh")
+	writeNetwork(t, src, "NodeID-src")
+	vmPath := filepath.Join(root, "vm")
+	if err := ioutil.WriteFile(vmPath, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := UpdateState(src, func(state *State) error {
+		state.VMs = map[string]string{vmID: vmPath}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := filepath.Join(root, "snapshot.tar.gz")
+	if err := SaveSnapshot(src, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshot is loaded where the VM binary is not available
+	if err := os.Remove(vmPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSnapshot(snapshot, dst); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadState(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	installed := filepath.Join(dst, snapshotVMsDir, vmID)
+	if state.VMs[vmID] != installed {
+		t.Fatalf("expected VM %s to be installed from %s but got %s", vmID, installed, state.VMs[vmID])
+	}
+	info, err := os.Stat(installed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&0o100 == 0 {
+		t.Fatalf("expected VM binary to be executable but got mode %s", info.Mode())
+	}
+	if b, err := ioutil.ReadFile(installed); err != nil || string(b) != "binary" {
+		t.Fatalf("unexpected VM binary %q (%v)", b, err)
+	}
+}
+
+func TestSaveSnapshotMissingVM(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	writeNetwork(t, src, "NodeID-src")
+	err := UpdateState(src, func(state *State) error {
+		state.VMs = map[string]string{"vm": filepath.Join(root, "missing")}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A snapshot saved before under the same name is left as is
+	snapshot := filepath.Join(root, "snapshot.tar.gz")
+	if err := ioutil.WriteFile(snapshot, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSnapshot(src, snapshot); err == nil {
+		t.Fatal("expected an error saving a snapshot without its VM binary")
+	}
+	if b, err := ioutil.ReadFile(snapshot); err != nil || string(b) != "previous" {
+		t.Fatalf("expected the previous snapshot to be left as is but got %q (%v)", b, err)
+	}
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected no leftover files but got %d files", len(files))
+	}
+}
+
+func TestLoadSnapshotInvalid(t *testing.T) {
+	root := t.TempDir()
+	dst := filepath.Join(root, "dst")
+	writeNetwork(t, dst, "NodeID-dst")
+
+	// An archive without a network record
+	snapshot := filepath.Join(root, "snapshot.tar.gz")
+	f, err := os.Create(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	content := []byte("key")
+	if err := tw.WriteHeader(&tar.Header{Name: "node1/staker.key", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []interface{ Close() error }{tw, gw, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := LoadSnapshot(snapshot, dst); err == nil {
+		t.Fatal("expected an error loading a snapshot without a network record")
+	}
+	state, err := LoadState(dst)
+	if err != nil {
+		t.Fatalf("expected the data dir to be left as is but got %v", err)
+	}
+	if state.Nodes[0].ID != "NodeID-dst" {
+		t.Fatalf("unexpected node record %+v", state.Nodes[0])
+	}
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected no leftover directories but got %d files", len(files))
+	}
+}
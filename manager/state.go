@@ -0,0 +1,249 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/logs"
+	"github.com/ava-labs/ava-sim/runner"
+	"github.com/ava-labs/ava-sim/utils"
+)
+
+const (
+	stateFile = "network.json"
+	// File locked by the ava-sim processes updating the network record, so
+	// that their updates are not lost
+	stateLockFile = "network.lock"
+	// File holding the token clients of the control API must send, readable
+	// by its owner only
+	apiTokenFile  = "api.token"
+	apiTokenPerms = 0o600
+)
+
+// State is the record of a network written to its data dir so that other
+// ava-sim commands can find and interact with it
+type State struct {
+	PID int `json:"pid"`
+	// URL of the control API of the ava-sim process running the network
+	API string `json:"api,omitempty"`
+	// Paths to the installed custom VM binaries keyed by VM ID
+	VMs   map[string]string `json:"vms,omitempty"`
+	Nodes []NodeState       `json:"nodes"`
+	// Subnets validated by the nodes and subnets waiting to be whitelisted
+	WhitelistedSubnets []string `json:"whitelistedSubnets,omitempty"`
+	PendingSubnets     []string `json:"pendingSubnets,omitempty"`
+	// Subnets created on the network and the blockchains deployed on them
+	Subnets []SubnetState `json:"subnets,omitempty"`
+	// P-chain txs issued to set up the network, with their last status
+	Txs []runner.Tx `json:"txs,omitempty"`
+	// Test accounts funded on the network
+	Accounts *AccountsState `json:"accounts,omitempty"`
+}
+
+// AccountsState describes the first [Count] accounts derived from [Mnemonic]
+// with addresses prefixed by [HRP], funded with [Balance] nAVAX on each chain
+// when they were created
+type AccountsState struct {
+	Mnemonic string `json:"mnemonic"`
+	HRP      string `json:"hrp"`
+	Count    int    `json:"count"`
+	Balance  uint64 `json:"balance"`
+}
+
+// SubnetState describes a subnet created on the network
+type SubnetState struct {
+	Name        string            `json:"name,omitempty"`
+	ID          string            `json:"id"`
+	Blockchains []BlockchainState `json:"blockchains,omitempty"`
+}
+
+// BlockchainState describes a blockchain deployed on a subnet
+type BlockchainState struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	VMID string `json:"vmID"`
+}
+
+// NodeState describes a single node of the network
+type NodeState struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	Dir string `json:"dir"`
+	// Removed nodes are not started when the network is resumed
+	Removed bool `json:"removed,omitempty"`
+}
+
+// LoadState reads the network record from [dataDir]
+func LoadState(dataDir string) (*State, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dataDir, stateFile))
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", stateFile, err)
+	}
+	return state, nil
+}
+
+// Save writes the network record to [dataDir]. The record is replaced
+// atomically so that other ava-sim processes never read it partially.
+// Records read and then saved must be updated with [UpdateState] instead.
+func (s *State) Save(dataDir string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dataDir, "."+stateFile+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(os.FileMode(constants.FilePerms)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dataDir, stateFile))
+}
+
+// UpdateState applies [update] to the network record in [dataDir] and saves
+// it. The record is locked meanwhile, so that concurrent updates by this and
+// other ava-sim processes (e.g. the one running the network and a subnet
+// command) are not lost. The record is left as is if [update] fails.
+func UpdateState(dataDir string, update func(*State) error) error {
+	unlock, err := lockState(dataDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := LoadState(dataDir)
+	if err != nil {
+		return err
+	}
+	if err := update(state); err != nil {
+		return err
+	}
+	return state.Save(dataDir)
+}
+
+// lockState waits for an exclusive lock on the network record in [dataDir]
+// and returns the function releasing it
+func lockState(dataDir string) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(dataDir, stateLockFile), os.O_CREATE|os.O_RDWR, os.FileMode(constants.FilePerms))
+	if err != nil {
+		return nil, fmt.Errorf("could not lock network state: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not lock network state: %w", err)
+	}
+	// Closing the file releases the lock
+	return func() { _ = f.Close() }, nil
+}
+
+// LoadAPIToken reads the token of the control API serving the network in
+// [dataDir]
+func LoadAPIToken(dataDir string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dataDir, apiTokenFile))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// saveAPIToken writes [token] to [dataDir], or removes the token there if
+// [token] is empty
+func saveAPIToken(dataDir string, token string) error {
+	path := filepath.Join(dataDir, apiTokenFile)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(token) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(token+"\n"), apiTokenPerms)
+}
+
+// Running returns true if the process that started the network is alive
+func (s *State) Running() bool {
+	return utils.ProcessRunning(s.PID)
+}
+
+func (s *State) NodeIDs() []string {
+	nodeIDs := make([]string, len(s.Nodes))
+	for i, n := range s.Nodes {
+		nodeIDs[i] = n.ID
+	}
+	return nodeIDs
+}
+
+func (s *State) NodeURLs() []string {
+	urls := make([]string, len(s.Nodes))
+	for i, n := range s.Nodes {
+		urls[i] = n.URL
+	}
+	return urls
+}
+
+// LogSources returns the log directories of the nodes
+func (s *State) LogSources() []logs.Source {
+	sources := make([]logs.Source, len(s.Nodes))
+	for i, n := range s.Nodes {
+		sources[i] = logs.Source{
+			Node: fmt.Sprintf("node%d", i+1),
+			ID:   n.ID,
+			Dir:  filepath.Join(n.Dir, "logs"),
+		}
+	}
+	return sources
+}
+
+// ChainID returns the ID of the blockchain named [chain], or [chain] itself
+// if no blockchain is named so (e.g. if it is an alias or an ID)
+func (s *State) ChainID(chain string) string {
+	for _, subnet := range s.Subnets {
+		for _, blockchain := range subnet.Blockchains {
+			if blockchain.Name == chain {
+				return blockchain.ID
+			}
+		}
+	}
+	return chain
+}
+
+// resumedVMs returns the VMs of the network when it is resumed with [vms]:
+// the VMs installed on it before, including at runtime, along with [vms],
+// which replace the recorded binaries of the same VMs
+func (s *State) resumedVMs(vms map[string]string) map[string]string {
+	res := make(map[string]string, len(s.VMs)+len(vms))
+	for vmID, vmPath := range s.VMs {
+		res[vmID] = vmPath
+	}
+	for vmID, vmPath := range vms {
+		res[vmID] = vmPath
+	}
+	return res
+}
+
+// FundedAccounts returns the test accounts funded on the network
+func (s *State) FundedAccounts() ([]accounts.Account, error) {
+	if s.Accounts == nil {
+		return nil, nil
+	}
+	return accounts.Derive(s.Accounts.Mnemonic, s.Accounts.HRP, s.Accounts.Count)
+}
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ava-labs/ava-sim/runner"
+)
+
+func TestUpdateStateConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	if err := (&State{PID: 1}).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	const updates = 50
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each update locks the record on its own, as in separate
+			// processes
+			err := UpdateState(dir, func(state *State) error {
+				state.Txs = append(state.Txs, runner.Tx{ID: fmt.Sprint(i)})
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Txs) != updates {
+		t.Fatalf("expected %d txs but got %d", updates, len(state.Txs))
+	}
+	if state.PID != 1 {
+		t.Fatalf("expected pid 1 but got %d", state.PID)
+	}
+}
+
+func TestUpdateStateFailure(t *testing.T) {
+	dir := t.TempDir()
+	if err := (&State{PID: 1}).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	err := UpdateState(dir, func(state *State) error {
+		state.PID = 2
+		return os.ErrInvalid
+	})
+	if err != os.ErrInvalid {
+		t.Fatalf("expected %v but got %v", os.ErrInvalid, err)
+	}
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.PID != 1 {
+		t.Fatalf("expected the record to be left as is but got pid %d", state.PID)
+	}
+}
+
+func TestSaveLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		if err := (&State{PID: i}).Save(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != stateFile {
+		t.Fatalf("expected only %s in the data dir but got %v", stateFile, files)
+	}
+	if _, err := LoadState(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error but got %v", err)
+	}
+}
+
+func TestResumedVMs(t *testing.T) {
+	const (
+		vmID      = "tGas3T58KzdjLHhBDMnH2TvrddhqTji5iZAMZ3RXs2NLpSnhH"
+		runtimeID = "srEXiWaHuhNyGwPUi444Tu47ZEDwxTWrbQiuD7FmgSAQ6X7Dy"
+	)
+	tests := []struct {
+		name     string
+		vms      map[string]string
+		expected map[string]string
+	}{
+		{
+			name:     "without --vm",
+			expected: map[string]string{vmID: "/bin/vm", runtimeID: "/bin/deployed"},
+		},
+		{
+			name:     "new binary of a recorded VM",
+			vms:      map[string]string{vmID: "/bin/vm2"},
+			expected: map[string]string{vmID: "/bin/vm2", runtimeID: "/bin/deployed"},
+		},
+		{
+			name:     "other VM",
+			vms:      map[string]string{"other": "/bin/other"},
+			expected: map[string]string{vmID: "/bin/vm", runtimeID: "/bin/deployed", "other": "/bin/other"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// A VM the network was started with and one installed at
+			// runtime
+			err := (&State{VMs: map[string]string{vmID: "/bin/vm"}}).Save(dir)
+			if err == nil {
+				err = UpdateState(dir, func(state *State) error {
+					state.VMs[runtimeID] = "/bin/deployed"
+					return nil
+				})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, previous, err := prepareDataDir(dir, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if previous == nil {
+				t.Fatal("expected the network to be resumed")
+			}
+			if vms := previous.resumedVMs(test.vms); !reflect.DeepEqual(vms, test.expected) {
+				t.Fatalf("expected VMs %v but got %v", test.expected, vms)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package metrics aggregates the Prometheus metrics of the nodes of a network,
+// scraped from their /ext/metrics endpoints and labeled with the node they
+// come from, with the metrics of ava-sim itself: the durations of the phases
+// of the setup of the network, the time P-chain txs take to be accepted and
+// the restarts of the nodes.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "avasim"
+
+// Results of the phases of the setup of a network
+const (
+	Succeeded = "succeeded"
+	Failed    = "failed"
+	TimedOut  = "timed_out"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "phase_duration_seconds",
+		Help:      "Duration of the phases of the setup of the network",
+		Buckets:   []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"phase", "result"})
+	txAcceptance = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tx_acceptance_seconds",
+		Help:      "Time from the issuance of P-chain txs to their acceptance, abortion or drop",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, []string{"tx", "status"})
+	nodeRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_restarts_total",
+		Help:      "Number of times the nodes were started again after their first start",
+	}, []string{"node", NodeIDLabel})
+)
+
+func init() {
+	registry.MustRegister(phaseDuration, txAcceptance, nodeRestarts)
+}
+
+// ObservePhase records that [phase] ran for [d] and failed with [err] if it
+// is not nil
+func ObservePhase(phase string, d time.Duration, err error) {
+	result := Succeeded
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result = TimedOut
+	case err != nil:
+		result = Failed
+	}
+	phaseDuration.WithLabelValues(phase, result).Observe(d.Seconds())
+}
+
+// ObserveTx records that a tx of kind [tx] reached [status] [d] after it was
+// issued
+func ObserveTx(tx string, status string, d time.Duration) {
+	txAcceptance.WithLabelValues(tx, status).Observe(d.Seconds())
+}
+
+// NodeRestarted records that the node named [node] with ID [nodeID] was
+// started again
+func NodeRestarted(node string, nodeID string) {
+	nodeRestarts.WithLabelValues(node, nodeID).Inc()
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ava-labs/ava-sim/constants"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// Labels added to the metrics of the nodes
+	NodeLabel   = "node"
+	NodeIDLabel = "node_id"
+
+	// Format the metrics are written in
+	Format = expfmt.FmtText
+)
+
+// Target is a node named [Node] with ID [ID] whose metrics are served at
+// [URL]/ext/metrics
+type Target struct {
+	Node string
+	ID   string
+	URL  string
+}
+
+// scrape is the result of the scrape of a target
+type scrape struct {
+	families map[string]*dto.MetricFamily
+	err      error
+}
+
+// Gather scrapes the metrics of [targets] concurrently and returns them,
+// labeled with the name and ID of their node, along with the metrics of
+// ava-sim and whether each target could be scraped (avasim_scrape_up).
+// Metrics of a node with the same name as metrics of another node but a
+// different type (e.g. as the nodes run different avalanchego versions) are
+// skipped.
+func Gather(ctx context.Context, targets []Target) ([]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(ctx, constants.HTTPTimeout)
+	defer cancel()
+
+	var (
+		scrapes = make([]scrape, len(targets))
+		wg      sync.WaitGroup
+	)
+	for i, target := range targets {
+		i, target := i, target
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scrapes[i].families, scrapes[i].err = scrapeTarget(ctx, target)
+		}()
+	}
+	wg.Wait()
+
+	families, err := registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	up := &dto.MetricFamily{
+		Name: proto.String(namespace + "_scrape_up"),
+		Help: proto.String("Whether the metrics of the nodes could be scraped"),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	merged := make(map[string]*dto.MetricFamily)
+	for i, target := range targets {
+		value := 1.
+		if scrapes[i].err != nil {
+			value = 0
+		}
+		up.Metric = append(up.Metric, &dto.Metric{
+			Label: targetLabels(target),
+			Gauge: &dto.Gauge{Value: proto.Float64(value)},
+		})
+		for name, family := range scrapes[i].families {
+			for _, m := range family.Metric {
+				m.Label = append(targetLabels(target), m.Label...)
+			}
+			existing, ok := merged[name]
+			switch {
+			case !ok:
+				merged[name] = family
+			case existing.GetType() == family.GetType():
+				existing.Metric = append(existing.Metric, family.Metric...)
+			}
+		}
+	}
+	families = append(families, up)
+	for _, family := range merged {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families, nil
+}
+
+// Write writes [families] to [w] in [Format]
+func Write(w io.Writer, families []*dto.MetricFamily) error {
+	encoder := expfmt.NewEncoder(w, Format)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrapeTarget returns the metrics of [target]
+func scrapeTarget(ctx context.Context, target Target) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL+"/ext/metrics", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not scrape %s: %s", target.Node, res.Status)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the metrics of %s: %w", target.Node, err)
+	}
+	return families, nil
+}
+
+// targetLabels returns the labels of the metrics of [target]
+func targetLabels(target Target) []*dto.LabelPair {
+	return []*dto.LabelPair{
+		{Name: proto.String(NodeLabel), Value: proto.String(target.Node)},
+		{Name: proto.String(NodeIDLabel), Value: proto.String(target.ID)},
+	}
+}
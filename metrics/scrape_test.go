@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// serve serves [metrics] in the text format on /ext/metrics
+func serve(t *testing.T, metrics string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ext/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, metrics)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// labels returns the labels of [m] formatted as name=value, sorted by name
+func labels(m *dto.Metric) string {
+	pairs := make([]string, len(m.Label))
+	for i, l := range m.Label {
+		pairs[i] = l.GetName() + "=" + l.GetValue()
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func TestGather(t *testing.T) {
+	targets := []Target{
+		{
+			Node: "node1",
+			ID:   "NodeID-1",
+			URL: serve(t, "# TYPE avalanche_requests counter\n"+
+				"avalanche_requests{chain=\"P\"} 3\n"+
+				"# TYPE avalanche_peers gauge\n"+
+				"avalanche_peers 4\n"),
+		},
+		{
+			// Runs another avalanchego version, where avalanche_peers is a
+			// counter
+			Node: "node2",
+			ID:   "NodeID-2",
+			URL: serve(t, "# TYPE avalanche_requests counter\n"+
+				"avalanche_requests{chain=\"P\"} 5\n"+
+				"# TYPE avalanche_peers counter\n"+
+				"avalanche_peers 4\n"),
+		},
+		{Node: "node3", ID: "NodeID-3", URL: serve(t, "not metrics {")},
+		{Node: "node4", ID: "NodeID-4", URL: "http://127.0.0.1:1"},
+	}
+	families, err := Gather(context.Background(), targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]*dto.MetricFamily)
+	for i, family := range families {
+		if i > 0 && families[i-1].GetName() >= family.GetName() {
+			t.Fatalf("expected families sorted by name but got %s after %s", family.GetName(), families[i-1].GetName())
+		}
+		byName[family.GetName()] = family
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]float64
+	}{
+		{
+			name: "avalanche_requests",
+			values: map[string]float64{
+				"chain=P,node=node1,node_id=NodeID-1": 3,
+				"chain=P,node=node2,node_id=NodeID-2": 5,
+			},
+		},
+		{
+			// The metric of node2 is skipped as its type differs
+			name:   "avalanche_peers",
+			values: map[string]float64{"node=node1,node_id=NodeID-1": 4},
+		},
+		{
+			name: "avasim_scrape_up",
+			values: map[string]float64{
+				"node=node1,node_id=NodeID-1": 1,
+				"node=node2,node_id=NodeID-2": 1,
+				"node=node3,node_id=NodeID-3": 0,
+				"node=node4,node_id=NodeID-4": 0,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			family, ok := byName[test.name]
+			if !ok {
+				t.Fatalf("expected metric %s", test.name)
+			}
+			values := make(map[string]float64)
+			for _, m := range family.Metric {
+				switch family.GetType() {
+				case dto.MetricType_COUNTER:
+					values[labels(m)] = m.GetCounter().GetValue()
+				default:
+					values[labels(m)] = m.GetGauge().GetValue()
+				}
+			}
+			if len(values) != len(test.values) {
+				t.Fatalf("expected %v but got %v", test.values, values)
+			}
+			for l, v := range test.values {
+				if values[l] != v {
+					t.Fatalf("expected %v but got %v", test.values, values)
+				}
+			}
+		})
+	}
+}
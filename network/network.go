@@ -0,0 +1,1009 @@
+// Package network runs a local Avalanche network in the current process, e.g.
+// to spin up a network from a go test:
+//
+//	n, err := network.New(network.Options{NumNodes: 5})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	if err := n.Start(ctx); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer n.Stop()
+//
+// The nodes share the state of the process, so only one network can run in a
+// process at a time.
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/logs"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/metrics"
+	"github.com/ava-labs/ava-sim/proxy"
+	"github.com/ava-labs/ava-sim/runner"
+	"github.com/ava-labs/ava-sim/utils"
+
+	"github.com/ava-labs/avalanchego/ids"
+	avalancheConstants "github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	healthCheckInterval = 5 * time.Second
+	logPollInterval     = 500 * time.Millisecond
+)
+
+var (
+	// ErrNotFound is returned when a node or subnet does not exist
+	ErrNotFound = errors.New("not found")
+
+	errAlreadyStarted = errors.New("network already started")
+	errNotStarted     = errors.New("network not started")
+	errNoRunningNodes = errors.New("no node is running")
+	errOtherRunning   = errors.New("another network is already running in this process (only one network can run in a process at a time)")
+
+	// Network started in this process, if any
+	activeLock sync.Mutex
+	active     *Network
+)
+
+// Options describes a network
+type Options struct {
+	// Defaults to [constants.DefaultNumNodes]
+	NumNodes int
+	// HTTP port of the first node. Node i listens for HTTP requests on
+	// BasePort+2i and for staking connections on BasePort+2i+1. Defaults to
+	// [constants.BaseHTTPPort].
+	BasePort int
+	// Pick free ports for the nodes instead of deriving them from BasePort,
+	// e.g. to run networks in parallel
+	DynamicPorts bool
+	// Directory holding the node DBs, logs and plugins. A fresh temporary
+	// directory is used if empty. A stopped network in DataDir is resumed
+	// unless Reset is set, in which case it is removed.
+	DataDir string
+	Reset   bool
+	// Defaults to info
+	LogLevel string
+	// Path to an avalanchego binary to run each node in a separate process
+	// with, e.g. to test a specific avalanchego version. Nodes run in this
+	// process if empty.
+	AvalancheGoPath string
+	// Paths to the avalanchego binaries of the nodes at the given indices,
+	// overriding AvalancheGoPath, e.g. to run a mix of versions
+	NodeAvalancheGoPaths map[int]string
+	// Path to the C-chain plugin of all nodes. Nodes running an avalanchego
+	// binary default to the plugins/evm next to it and nodes running in this
+	// process to build/system-plugins/evm (relative to the working
+	// directory).
+	EVMPath string
+	// Paths to the custom VM binaries to install on all nodes keyed by VM ID
+	VMs map[string]string
+	// Avalanchego flags keyed by flag name (without dashes) applied to all
+	// nodes, overriding the defaults
+	Flags map[string]string
+	// Avalanchego flags applied to the node at the given index, overriding
+	// [Flags]
+	NodeFlags map[int]map[string]string
+	// Route the staking connections between nodes through local links that
+	// can inject latency, packet loss, bandwidth limits and partitions (see
+	// [Network.SetFaults])
+	Faults bool
+	// Behaviors of the byzantine nodes at the given indices, e.g. to check
+	// that the other nodes bench them and stay live. Unresponsive and delayed
+	// nodes require Faults.
+	Behaviors map[int]manager.Behavior
+	// Deadlines of the phases of the setup of the network, e.g. to fail fast
+	// in CI when a VM cannot bootstrap its genesis. Defaults to
+	// [health.DefaultTimeouts].
+	Timeouts health.Timeouts
+	// Number of test accounts derived from Mnemonic (defaults to
+	// [accounts.DefaultMnemonic]) to fund with AccountBalance nAVAX (defaults
+	// to [accounts.DefaultBalance]) on the X, P and C chains once the
+	// network bootstrapped, and in the genesis of the Subnet-EVM blockchains
+	// deployed afterwards (see [Network.Accounts]). Accounts funded on a
+	// resumed network are not funded again.
+	Accounts       int
+	Mnemonic       string
+	AccountBalance uint64
+	// URL of a control API serving the network (see control.Serve), recorded
+	// in DataDir so that ava-sim commands can manage the network
+	API string
+	// Token clients of the control API must send (see control.NewToken),
+	// recorded in DataDir with API
+	APIToken string
+}
+
+// Network is a local network running in this process
+type Network struct {
+	config   manager.Config
+	timeouts health.Timeouts
+	accounts manager.AccountsState
+
+	lock   sync.Mutex
+	cancel context.CancelFunc
+	// Closed once the network stopped running
+	done chan struct{}
+	err  error
+}
+
+// New returns the network described by [opts], which is started by [Start]
+func New(opts Options) (*Network, error) {
+	config := manager.Config{
+		NumNodes:             opts.NumNodes,
+		BasePort:             opts.BasePort,
+		DynamicPorts:         opts.DynamicPorts,
+		DataDir:              opts.DataDir,
+		Reset:                opts.Reset,
+		LogLevel:             opts.LogLevel,
+		AvalancheGoPath:      opts.AvalancheGoPath,
+		NodeAvalancheGoPaths: opts.NodeAvalancheGoPaths,
+		EVMPath:              opts.EVMPath,
+		VMs:                  opts.VMs,
+		Flags:                opts.Flags,
+		NodeFlags:            opts.NodeFlags,
+		Faults:               opts.Faults,
+		Behaviors:            opts.Behaviors,
+		BootstrapTimeout:     opts.Timeouts.WithDefaults().NetworkBootstrap,
+		API:                  opts.API,
+		APIToken:             opts.APIToken,
+	}
+	if config.NumNodes == 0 {
+		config.NumNodes = constants.DefaultNumNodes
+	}
+	if config.BasePort == 0 {
+		config.BasePort = constants.BaseHTTPPort
+	}
+	if len(config.LogLevel) == 0 {
+		config.LogLevel = "info"
+	}
+
+	if config.NumNodes < 1 {
+		return nil, fmt.Errorf("invalid number of nodes %d (expecting at least 1)", config.NumNodes)
+	}
+	if !config.DynamicPorts && (config.BasePort < 1 || config.BasePort+2*config.NumNodes > 65535) {
+		return nil, fmt.Errorf("invalid base port %d for %d nodes", config.BasePort, config.NumNodes)
+	}
+	if _, err := logging.ToLevel(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+	if len(config.AvalancheGoPath) > 0 {
+		if _, err := os.Stat(config.AvalancheGoPath); err != nil {
+			return nil, fmt.Errorf("invalid avalanchego binary: %w", err)
+		}
+	}
+	for i, path := range config.NodeAvalancheGoPaths {
+		if i < 0 || i >= config.NumNodes {
+			return nil, fmt.Errorf("avalanchego binary set for unknown node%d", i+1)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("invalid avalanchego binary for node%d: %w", i+1, err)
+		}
+	}
+	for vmID, vmPath := range config.VMs {
+		if _, err := ids.FromString(vmID); err != nil {
+			return nil, fmt.Errorf("invalid VM ID %q: %w", vmID, err)
+		}
+		if _, err := os.Stat(vmPath); err != nil {
+			return nil, fmt.Errorf("invalid VM %s: %w", vmID, err)
+		}
+	}
+	accts := manager.AccountsState{
+		Mnemonic: opts.Mnemonic,
+		Count:    opts.Accounts,
+		Balance:  opts.AccountBalance,
+	}
+	if len(accts.Mnemonic) == 0 {
+		accts.Mnemonic = accounts.DefaultMnemonic
+	}
+	if accts.Balance == 0 {
+		accts.Balance = accounts.DefaultBalance
+	}
+	if accts.Count < 0 {
+		return nil, fmt.Errorf("invalid number of accounts %d", accts.Count)
+	}
+	if _, err := accounts.Derive(accts.Mnemonic, avalancheConstants.LocalHRP, 0); err != nil {
+		return nil, err
+	}
+	return &Network{
+		config:   config,
+		timeouts: opts.Timeouts.WithDefaults(),
+		accounts: accts,
+	}, nil
+}
+
+// Start starts the network, waits for all nodes to bootstrap and funds the
+// test accounts of [Options.Accounts]. The network keeps running until [Stop]
+// is called or a node fails, even once [ctx] is done. It is stopped if [ctx]
+// is done or the accounts cannot be funded before the network is set up.
+// Starting a network while another one runs in this process fails.
+func (n *Network) Start(ctx context.Context) error {
+	n.lock.Lock()
+	if n.done != nil {
+		n.lock.Unlock()
+		return errAlreadyStarted
+	}
+	activeLock.Lock()
+	if active != nil {
+		activeLock.Unlock()
+		n.lock.Unlock()
+		return errOtherRunning
+	}
+	active = n
+	activeLock.Unlock()
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	n.cancel = cancel
+	n.done = done
+	n.err = nil
+	n.lock.Unlock()
+
+	// The txs issued to set the network up are recorded in its data dir
+	runner.RecordTxs(recordTx)
+	bootstrapped := make(chan struct{})
+	go func() {
+		err := manager.StartNetwork(runCtx, n.config, bootstrapped)
+		activeLock.Lock()
+		active = nil
+		activeLock.Unlock()
+		n.lock.Lock()
+		n.err = err
+		n.lock.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-bootstrapped:
+		if err := n.fundAccounts(ctx); err != nil {
+			_ = n.Stop()
+			return err
+		}
+		return nil
+	case <-done:
+		if err := n.Err(); err != nil {
+			return err
+		}
+		return errors.New("network stopped before bootstrapping")
+	case <-ctx.Done():
+		_ = n.Stop()
+		return ctx.Err()
+	}
+}
+
+// fundAccounts funds the test accounts of the network that were not funded
+// yet, records them in its data dir and makes the runner allocate them funds
+// in the genesis of the EVM blockchains it deploys
+func (n *Network) fundAccounts(ctx context.Context) error {
+	recorded, ok := manager.RecordedAccounts()
+	if n.accounts.Count == 0 && !ok {
+		runner.AllocateAccounts(nil, 0)
+		return nil
+	}
+	_, urls, err := n.runningNodes()
+	if err != nil {
+		return err
+	}
+	hrp, err := runner.HRP(urls[0])
+	if err != nil {
+		return err
+	}
+	record := n.accounts
+	record.HRP = hrp
+	funded := 0
+	if ok && recorded.Mnemonic == record.Mnemonic && recorded.HRP == hrp {
+		funded = recorded.Count
+		if record.Count < funded {
+			record.Count = funded
+		}
+		if n.accounts.Count == 0 {
+			record.Balance = recorded.Balance
+		}
+	}
+	accts, err := accounts.Derive(record.Mnemonic, hrp, record.Count)
+	if err != nil {
+		return err
+	}
+	if funded < len(accts) {
+		if err := runner.FundAccounts(ctx, urls[0], accts[funded:], record.Balance, n.timeouts); err != nil {
+			return err
+		}
+	}
+	if err := manager.RecordAccounts(n.DataDir(), record); err != nil {
+		return err
+	}
+	runner.AllocateAccounts(accts, record.Balance)
+	return nil
+}
+
+// Accounts returns the test accounts funded on the network
+func (n *Network) Accounts() ([]accounts.Account, error) {
+	dir := n.DataDir()
+	if len(dir) == 0 {
+		return nil, errNotStarted
+	}
+	state, err := manager.LoadState(dir)
+	if err != nil {
+		return nil, err
+	}
+	return state.FundedAccounts()
+}
+
+// Stop stops the network and waits for all nodes to exit. It returns the
+// error that made the network stop on its own, if any. A stopped network can
+// be started again (and is resumed if [Options.DataDir] is set).
+func (n *Network) Stop() error {
+	n.lock.Lock()
+	cancel, done := n.cancel, n.done
+	n.lock.Unlock()
+	if done == nil {
+		return errNotStarted
+	}
+
+	cancel()
+	<-done
+	err := n.Err()
+	n.lock.Lock()
+	n.cancel = nil
+	n.done = nil
+	n.lock.Unlock()
+	return err
+}
+
+// Done returns a channel that is closed once the network stopped running,
+// either because of [Stop] or because a node failed (see [Err])
+func (n *Network) Done() <-chan struct{} {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.done == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return n.done
+}
+
+// Err returns the error that made the network stop, if any
+func (n *Network) Err() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if errors.Is(n.err, context.Canceled) {
+		return nil
+	}
+	return n.err
+}
+
+// DataDir returns the data dir of the running network
+func (n *Network) DataDir() string {
+	return manager.DataDir()
+}
+
+// Nodes returns the nodes of the network
+func (n *Network) Nodes() []manager.NodeInfo {
+	return manager.Nodes()
+}
+
+// node returns the node named [name]
+func (n *Network) node(name string) (manager.NodeInfo, error) {
+	for _, node := range n.Nodes() {
+		if node.Name == name {
+			return node, nil
+		}
+	}
+	return manager.NodeInfo{}, fmt.Errorf("node %s: %w", name, ErrNotFound)
+}
+
+// NodeOptions describes the validator sets a node added to the network
+// joins
+type NodeOptions struct {
+	// Add the node as a validator of the primary network
+	Validator bool `json:"validator"`
+	// Subnets (by ID or name) to add the node to as a validator with
+	// [Weight], which makes it a primary network validator as well
+	Subnets []string `json:"subnets"`
+	// Defaults to [runner.DefaultValidatorWeight]
+	Weight uint64 `json:"weight"`
+}
+
+// AddNode adds a node with a fresh staking key to the network, waits for it
+// to bootstrap from the running nodes and then adds it to the validator sets
+// described by [opts]. The node starts validating shortly after its validator
+// txs are accepted.
+func (n *Network) AddNode(ctx context.Context, opts NodeOptions) (manager.NodeInfo, error) {
+	subnetIDs := make([]ids.ID, len(opts.Subnets))
+	for i, subnet := range opts.Subnets {
+		subnetID, err := n.subnetID(subnet)
+		if err != nil {
+			return manager.NodeInfo{}, err
+		}
+		subnetIDs[i] = subnetID
+	}
+	weight := opts.Weight
+	if weight == 0 {
+		weight = runner.DefaultValidatorWeight
+	}
+
+	node, err := manager.AddNode(ctx)
+	if err != nil || (!opts.Validator && len(subnetIDs) == 0) {
+		return node, err
+	}
+	_, nodeURLs, err := n.runningNodes()
+	if err != nil {
+		return manager.NodeInfo{}, err
+	}
+	if err := runner.AddValidator(ctx, nodeURLs[0], node.ID, weight, subnetIDs, n.timeouts); err != nil {
+		return manager.NodeInfo{}, fmt.Errorf("could not add %s as a validator: %w", node.Name, err)
+	}
+	return node, nil
+}
+
+// RemoveNode stops the node named [name] for good, keeping its data dir.
+// Validators cannot leave the validator sets before the end of their
+// validation period with avalanchego v1.7, so a validator stays in them,
+// offline, until then.
+func (n *Network) RemoveNode(ctx context.Context, name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.RemoveNode(ctx, name)
+}
+
+// subnetID returns the ID of the subnet created on the network with the ID or
+// name [subnet]
+func (n *Network) subnetID(subnet string) (ids.ID, error) {
+	subnets, err := n.Subnets()
+	if err != nil {
+		return ids.Empty, err
+	}
+	for _, s := range subnets {
+		if s.ID == subnet || s.Name == subnet {
+			return ids.FromString(s.ID)
+		}
+	}
+	return ids.Empty, fmt.Errorf("subnet %s: %w", subnet, ErrNotFound)
+}
+
+// StopNode stops the node named [name]. Only nodes running an avalanchego
+// binary can be stopped: nodes running in this process share their VM plugin
+// processes, so stopping one would restart all the others.
+func (n *Network) StopNode(ctx context.Context, name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.StopNode(ctx, name)
+}
+
+// StartNode starts the node named [name] if it was stopped, killed or exited
+// on its own. The other nodes running in this process are restarted as well,
+// as they share their VM plugin processes.
+func (n *Network) StartNode(ctx context.Context, name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.StartNode(ctx, name)
+}
+
+// RestartNode restarts the node named [name] (see [StartNode])
+func (n *Network) RestartNode(ctx context.Context, name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.RestartNode(ctx, name)
+}
+
+// KillNode kills the node named [name] and its VM plugin processes as if it
+// crashed, leaving the other nodes running. The node stays down until it is
+// started by [StartNode]. Only nodes running an avalanchego binary can be
+// killed.
+func (n *Network) KillNode(ctx context.Context, name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.KillNode(ctx, name)
+}
+
+// PauseNode freezes the node named [name] and its VM plugin processes as if
+// it hung, until [ResumeNode] is called. Only nodes running an avalanchego
+// binary can be paused.
+func (n *Network) PauseNode(name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.PauseNode(name)
+}
+
+// ResumeNode resumes the node named [name] paused by [PauseNode]
+func (n *Network) ResumeNode(name string) error {
+	if _, err := n.node(name); err != nil {
+		return err
+	}
+	return manager.ResumeNode(name)
+}
+
+// UpgradeNodes restarts the nodes named [names] (all nodes if empty) with the
+// avalanchego binary at [avalanchegoPath] one at a time, waiting for each to
+// bootstrap before upgrading the next. The nodes keep their staking keys and
+// DBs.
+func (n *Network) UpgradeNodes(ctx context.Context, avalanchegoPath string, names ...string) error {
+	for _, name := range names {
+		if _, err := n.node(name); err != nil {
+			return err
+		}
+	}
+	return manager.UpgradeNodes(ctx, avalanchegoPath, names)
+}
+
+// SetFaults injects [faults] on the links between each node named [names]
+// and each node named [peers] (all nodes if empty), in both directions,
+// replacing the faults injected on them before. Faults are only injected if
+// [Options.Faults] is set.
+func (n *Network) SetFaults(names []string, peers []string, faults proxy.Faults) error {
+	for _, name := range append(append([]string(nil), names...), peers...) {
+		if _, err := n.node(name); err != nil {
+			return err
+		}
+	}
+	return manager.SetFaults(names, peers, faults)
+}
+
+// Partition cuts the links between the nodes of different [groups] of node
+// names until [Heal] is called. The links of nodes in no group are
+// unaffected.
+func (n *Network) Partition(groups ...[]string) error {
+	for _, names := range groups {
+		for _, name := range names {
+			if _, err := n.node(name); err != nil {
+				return err
+			}
+		}
+	}
+	return manager.Partition(groups)
+}
+
+// Heal removes all faults injected on the links between nodes, including
+// partitions
+func (n *Network) Heal() error {
+	return manager.Heal()
+}
+
+// HealPartition removes the partitions of the links between each pair of
+// [pairs] of node names, keeping the other faults injected on them
+func (n *Network) HealPartition(pairs ...[2]string) error {
+	for _, pair := range pairs {
+		for _, name := range pair {
+			if _, err := n.node(name); err != nil {
+				return err
+			}
+		}
+	}
+	return manager.HealPartition(pairs)
+}
+
+// FaultInjection returns true if faults can be injected between the nodes
+// (see [Options.Faults])
+func (n *Network) FaultInjection() bool {
+	return n.config.Faults
+}
+
+// Faults returns the faults injected between pairs of nodes
+func (n *Network) Faults() ([]manager.LinkFaults, error) {
+	return manager.Faults()
+}
+
+// runningNodes returns the IDs and URLs of the running nodes
+func (n *Network) runningNodes() ([]string, []string, error) {
+	var nodeIDs, nodeURLs []string
+	for _, node := range n.Nodes() {
+		if node.Running {
+			nodeIDs = append(nodeIDs, node.ID)
+			nodeURLs = append(nodeURLs, node.URL)
+		}
+	}
+	if len(nodeURLs) == 0 {
+		return nil, nil, errNoRunningNodes
+	}
+	return nodeIDs, nodeURLs, nil
+}
+
+// NodeHealth is the health of a running node
+type NodeHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Names of the failing health checks
+	Failing []string `json:"failing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Health is the health of the network, which is healthy if all running nodes
+// are
+type Health struct {
+	Healthy bool         `json:"healthy"`
+	Nodes   []NodeHealth `json:"nodes"`
+}
+
+// Status is the readiness of the network, which is ready once all running
+// nodes bootstrapped the primary network, are connected to each other and
+// validate and bootstrapped the blockchains of the subnets they validate
+type Status struct {
+	Ready   bool `json:"ready"`
+	Healthy bool `json:"healthy"`
+	// Readiness of the running nodes
+	Nodes []health.Report `json:"nodes"`
+}
+
+// Status returns the readiness of the running nodes
+func (n *Network) Status() (Status, error) {
+	nodes, reqs, err := n.requirements()
+	if err != nil {
+		return Status{}, err
+	}
+	res := Status{Ready: true, Healthy: true}
+	for i, node := range nodes {
+		r := health.Check(node, reqs[i])
+		res.Ready = res.Ready && r.Ready
+		res.Healthy = res.Healthy && r.Healthy
+		res.Nodes = append(res.Nodes, r)
+	}
+	return res, nil
+}
+
+// requirements returns the running nodes and the requirements each must meet
+// to be ready
+func (n *Network) requirements() ([]health.Node, []health.Requirements, error) {
+	if _, _, err := n.runningNodes(); err != nil {
+		return nil, nil, err
+	}
+	var (
+		nodes []health.Node
+		reqs  []health.Requirements
+	)
+	for _, node := range n.Nodes() {
+		if node.Running {
+			nodes = append(nodes, health.Node{Name: node.Name, ID: node.ID, URL: node.URL})
+			reqs = append(reqs, health.PrimaryNetwork(0))
+		}
+	}
+	subnets, err := n.Subnets()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, subnet := range subnets {
+		if len(subnet.Blockchains) == 0 {
+			continue
+		}
+		subnetID, err := ids.FromString(subnet.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		validators, err := runner.SubnetValidators(nodes[0].URL, subnetID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot query validators of subnet %s: %w", subnet.ID, err)
+		}
+		muted := manager.MutedNodeIDs(subnet.ID)
+		validates := make(map[string]bool, len(validators))
+		for _, validator := range validators {
+			validates[validator.NodeID] = !muted[validator.NodeID]
+		}
+		for i, node := range nodes {
+			if !validates[node.ID] {
+				continue
+			}
+			for _, blockchain := range subnet.Blockchains {
+				reqs[i].Validating = append(reqs[i].Validating, blockchain.ID)
+			}
+		}
+	}
+	for i := range reqs {
+		reqs[i].MinPeers = len(nodes) - 1
+	}
+	return nodes, reqs, nil
+}
+
+// Health returns the health of the running nodes
+func (n *Network) Health() (Health, error) {
+	status, err := n.Status()
+	if err != nil {
+		return Health{}, err
+	}
+	res := Health{Healthy: status.Healthy}
+	for _, r := range status.Nodes {
+		res.Nodes = append(res.Nodes, NodeHealth{
+			Name:    r.Name,
+			Healthy: r.Healthy,
+			Failing: r.Failing,
+			Error:   r.Error,
+		})
+	}
+	return res, nil
+}
+
+// WaitReady waits for all running nodes to be ready (see [Status])
+func (n *Network) WaitReady(ctx context.Context) error {
+	return n.wait(ctx, false)
+}
+
+// WaitHealthy waits for all running nodes to report healthy. The P-chain of a
+// node is only healthy once it is connected to 80% of the stake, which
+// networks with fewer than 5 nodes (missing some genesis validators) never
+// are.
+func (n *Network) WaitHealthy(ctx context.Context) error {
+	return n.wait(ctx, true)
+}
+
+// wait waits for all running nodes to be ready, and healthy if [healthy] is
+// set, failing once the network stops
+func (n *Network) wait(ctx context.Context, healthy bool) error {
+	nodes, reqs, err := n.requirements()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-n.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for i, node := range nodes {
+		reqs[i].Healthy = healthy
+		if _, err := health.Wait(ctx, node, reqs[i], healthCheckInterval); err != nil {
+			select {
+			case <-n.Done():
+				return errNotStarted
+			default:
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Logs returns the entries of the logs of the nodes selected by [f], ordered
+// by time. Blockchains can be selected by name as well.
+func (n *Network) Logs(f logs.Filter) ([]logs.Entry, error) {
+	sources, f, err := n.logSources(f)
+	if err != nil {
+		return nil, err
+	}
+	return logs.Read(sources, f)
+}
+
+// FollowLogs calls [fn] with the entries of the logs of the nodes selected by
+// [f] as the nodes write them, until [ctx] is done or [fn] fails
+func (n *Network) FollowLogs(ctx context.Context, f logs.Filter, fn func(logs.Entry) error) error {
+	sources, f, err := n.logSources(f)
+	if err != nil {
+		return err
+	}
+	return logs.Follow(ctx, sources, f, logPollInterval, fn)
+}
+
+// Metrics returns the Prometheus metrics of the nodes of the network, labeled
+// with the name and ID of their node, along with the metrics of ava-sim. Nodes
+// that are stopped or that cannot be scraped are reported by
+// avasim_scrape_up.
+func (n *Network) Metrics(ctx context.Context) ([]*dto.MetricFamily, error) {
+	var targets []metrics.Target
+	for _, node := range n.Nodes() {
+		if node.Removed {
+			continue
+		}
+		targets = append(targets, metrics.Target{Node: node.Name, ID: node.ID, URL: node.URL})
+	}
+	return metrics.Gather(ctx, targets)
+}
+
+// logSources returns the log directories of the nodes and [f] with the
+// blockchains it selects by name replaced by their IDs
+func (n *Network) logSources(f logs.Filter) ([]logs.Source, logs.Filter, error) {
+	state, err := manager.LoadState(n.DataDir())
+	if err != nil {
+		return nil, f, err
+	}
+	chains := make([]string, len(f.Chains))
+	for i, chain := range f.Chains {
+		chains[i] = state.ChainID(chain)
+	}
+	f.Chains = chains
+	return state.LogSources(), f, nil
+}
+
+// recordTx records [tx] in the data dir of the running network
+func recordTx(tx runner.Tx) {
+	if dir := manager.DataDir(); len(dir) > 0 {
+		_ = manager.RecordTx(dir, tx)
+	}
+}
+
+// SaveDiagnostics archives the logs, flags and record of the network, and
+// snapshots of the APIs of its nodes if it is running, to [path] along with
+// a summary of [failure] if it is not nil, e.g. to upload a single artifact
+// from a failed CI job (see [manager.SaveDiagnostics])
+func (n *Network) SaveDiagnostics(path string, failure error) error {
+	dir := n.DataDir()
+	if len(dir) == 0 {
+		dir = n.config.DataDir
+	}
+	if len(dir) == 0 {
+		return errNotStarted
+	}
+	return manager.SaveDiagnostics(dir, path, failure)
+}
+
+// Subnets returns the subnets created on the network
+func (n *Network) Subnets() ([]manager.SubnetState, error) {
+	return manager.Subnets()
+}
+
+// Validator is the node named [Node] validating a subnet with [Weight]
+type Validator struct {
+	Node   string `json:"node"`
+	Weight uint64 `json:"weight"`
+}
+
+// SubnetOptions describes a subnet validated by [Validators] (all running
+// nodes with equal weight if empty)
+type SubnetOptions struct {
+	Name       string      `json:"name"`
+	Validators []Validator `json:"validators"`
+}
+
+// CreateSubnet creates the subnet described by [opts], whitelists it on all
+// nodes and waits for its validators to start validating it
+func (n *Network) CreateSubnet(ctx context.Context, opts SubnetOptions) (manager.SubnetState, error) {
+	nodeIDs, nodeURLs, err := n.runningNodes()
+	if err != nil {
+		return manager.SubnetState{}, err
+	}
+	subnet := runner.DefaultSubnet(nodeIDs, nil)
+	subnet.Name = opts.Name
+	subnet.Blockchains = nil
+	if len(opts.Validators) > 0 {
+		subnet.Validators = nil
+		for _, validator := range opts.Validators {
+			node, err := n.node(validator.Node)
+			if err != nil {
+				return manager.SubnetState{}, err
+			}
+			weight := validator.Weight
+			if weight == 0 {
+				weight = runner.DefaultValidatorWeight
+			}
+			subnet.Validators = append(subnet.Validators, runner.Validator{
+				NodeID: node.ID,
+				Weight: weight,
+			})
+		}
+	}
+
+	subnetID, err := runner.CreateSubnet(ctx, nodeURLs[0], n.timeouts)
+	if err != nil {
+		return manager.SubnetState{}, err
+	}
+	record := manager.SubnetState{
+		Name: opts.Name,
+		ID:   subnetID.String(),
+	}
+	if err := manager.RecordSubnet(n.DataDir(), record); err != nil {
+		return manager.SubnetState{}, err
+	}
+	if err := manager.WhitelistSubnets(ctx, []string{subnetID.String()}); err != nil {
+		return manager.SubnetState{}, fmt.Errorf("could not whitelist subnet: %w", err)
+	}
+	subnet.MuteValidators(manager.MutedNodeIDs(subnetID.String()))
+	if _, err := runner.SetupSubnet(ctx, nodeURLs, nodeIDs, subnetID, subnet, n.timeouts); err != nil {
+		return manager.SubnetState{}, err
+	}
+	return record, nil
+}
+
+// ChainOptions describes a blockchain of a VM, identified by [VMID] or
+// [VMName] (the VM of [constants.VMID] if neither is set), created from
+// [Genesis]. If [VM] is set, the VM binary at that path is installed on all
+// nodes first.
+type ChainOptions struct {
+	Name    string
+	VM      string
+	VMID    string
+	VMName  string
+	Genesis []byte
+}
+
+// DeployChain creates the blockchain described by [opts] on the subnet
+// [subnetID] and waits for its validators to bootstrap it
+func (n *Network) DeployChain(ctx context.Context, subnetID string, opts ChainOptions) (manager.BlockchainState, error) {
+	subnets, err := n.Subnets()
+	if err != nil {
+		return manager.BlockchainState{}, err
+	}
+	var record *manager.SubnetState
+	for i := range subnets {
+		if subnets[i].ID == subnetID {
+			record = &subnets[i]
+		}
+	}
+	if record == nil {
+		return manager.BlockchainState{}, fmt.Errorf("subnet %s: %w", subnetID, ErrNotFound)
+	}
+	rSubnetID, err := ids.FromString(subnetID)
+	if err != nil {
+		return manager.BlockchainState{}, err
+	}
+	if len(opts.Name) == 0 {
+		return manager.BlockchainState{}, &manager.ValidationError{Err: errors.New("missing blockchain name")}
+	}
+
+	vmID := opts.VMID
+	switch {
+	case len(opts.VMID) > 0 && len(opts.VMName) > 0:
+		return manager.BlockchainState{}, &manager.ValidationError{Err: errors.New("VM ID and VM name are mutually exclusive")}
+	case len(opts.VMName) > 0:
+		vmID, err = utils.VMID(opts.VMName)
+		if err != nil {
+			return manager.BlockchainState{}, &manager.ValidationError{Err: err}
+		}
+	case len(opts.VMID) == 0:
+		vmID = constants.VMID
+	}
+	if len(opts.VM) > 0 {
+		if err := manager.InstallVM(ctx, vmID, opts.VM); err != nil {
+			return manager.BlockchainState{}, err
+		}
+	}
+
+	nodeIDs, nodeURLs, err := n.runningNodes()
+	if err != nil {
+		return manager.BlockchainState{}, err
+	}
+	validators, err := runner.SubnetValidators(nodeURLs[0], rSubnetID)
+	if err != nil {
+		return manager.BlockchainState{}, fmt.Errorf("cannot query subnet validators: %w", err)
+	}
+	// Validators that are stopped or were removed cannot bootstrap the
+	// blockchain
+	running := make(map[string]bool, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		running[nodeID] = true
+	}
+	var runningValidators []runner.Validator
+	for _, validator := range validators {
+		if running[validator.NodeID] {
+			runningValidators = append(runningValidators, validator)
+		}
+	}
+	subnet := runner.Subnet{
+		Name:       record.Name,
+		Validators: runningValidators,
+		Blockchains: []runner.Blockchain{{
+			Name:    opts.Name,
+			VMID:    vmID,
+			Genesis: opts.Genesis,
+		}},
+	}
+	subnet.MuteValidators(manager.MutedNodeIDs(subnetID))
+	blockchainIDs, err := runner.SetupSubnet(ctx, nodeURLs, nodeIDs, rSubnetID, subnet, n.timeouts)
+	if err != nil {
+		return manager.BlockchainState{}, err
+	}
+
+	blockchain := manager.BlockchainState{
+		Name: opts.Name,
+		ID:   blockchainIDs[0].String(),
+		VMID: vmID,
+	}
+	record.Blockchains = append(record.Blockchains, blockchain)
+	return blockchain, manager.RecordSubnet(n.DataDir(), *record)
+}
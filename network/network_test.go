@@ -0,0 +1,31 @@
+package network
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartWhileOtherRunning(t *testing.T) {
+	other := &Network{}
+	activeLock.Lock()
+	active = other
+	activeLock.Unlock()
+	defer func() {
+		activeLock.Lock()
+		active = nil
+		activeLock.Unlock()
+	}()
+
+	n := &Network{}
+	if err := n.Start(context.Background()); err != errOtherRunning {
+		t.Fatalf("expected %v but got %v", errOtherRunning, err)
+	}
+	// The network is left stopped, so that it can be started once the other
+	// one stopped
+	if n.done != nil {
+		t.Fatal("expected the network not to be started")
+	}
+	if err := n.Stop(); err != errNotStarted {
+		t.Fatalf("expected %v but got %v", errNotStarted, err)
+	}
+}
@@ -0,0 +1,436 @@
+// Package proxy forwards TCP connections between two nodes while injecting
+// faults on the link: latency, jitter, packet loss, bandwidth limits and
+// partitions.
+//
+// Nodes talk over TLS, so a proxy cannot drop or alter single messages
+// without breaking the connection. Faults are injected the way a real network
+// would affect a TCP stream instead: lost packets delay the data behind them
+// until they are retransmitted, and partitioned links stop delivering data
+// until they are healed while the connections stay open.
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Size of the chunks of data forwarded on a link
+	chunkSize = 16 * 1024
+	// Number of chunks read ahead of the chunks being delayed, beyond which
+	// the sender is slowed down
+	maxQueuedChunks = 64
+	// Minimum time TCP waits before retransmitting a lost packet
+	minRetransmitTimeout = 200 * time.Millisecond
+)
+
+// Faults are the faults injected on the data sent in a direction of a link
+type Faults struct {
+	// Time each chunk of data takes to cross the link, plus a random delay of
+	// up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// Probability that a chunk of data is lost and has to be retransmitted
+	DropRate float64
+	// Maximum rate of data sent in bytes per second, unlimited if 0
+	Bandwidth int
+	// Whether the link is cut. Data sent on a partitioned link is held until
+	// the link is healed, and senders block once it piles up.
+	Partitioned bool
+}
+
+// faultsJSON is the JSON encoding of [Faults] with durations such as "150ms"
+type faultsJSON struct {
+	Latency     string  `json:"latency,omitempty"`
+	Jitter      string  `json:"jitter,omitempty"`
+	DropRate    float64 `json:"dropRate,omitempty"`
+	Bandwidth   int     `json:"bandwidth,omitempty"`
+	Partitioned bool    `json:"partitioned,omitempty"`
+}
+
+func (f Faults) MarshalJSON() ([]byte, error) {
+	res := faultsJSON{
+		DropRate:    f.DropRate,
+		Bandwidth:   f.Bandwidth,
+		Partitioned: f.Partitioned,
+	}
+	if f.Latency != 0 {
+		res.Latency = f.Latency.String()
+	}
+	if f.Jitter != 0 {
+		res.Jitter = f.Jitter.String()
+	}
+	return json.Marshal(res)
+}
+
+func (f *Faults) UnmarshalJSON(b []byte) error {
+	res := faultsJSON{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	*f = Faults{
+		DropRate:    res.DropRate,
+		Bandwidth:   res.Bandwidth,
+		Partitioned: res.Partitioned,
+	}
+	var err error
+	if len(res.Latency) > 0 {
+		if f.Latency, err = time.ParseDuration(res.Latency); err != nil {
+			return fmt.Errorf("invalid latency: %w", err)
+		}
+	}
+	if len(res.Jitter) > 0 {
+		if f.Jitter, err = time.ParseDuration(res.Jitter); err != nil {
+			return fmt.Errorf("invalid jitter: %w", err)
+		}
+	}
+	return nil
+}
+
+// Verify returns an error if the faults are invalid
+func (f Faults) Verify() error {
+	switch {
+	case f.Latency < 0:
+		return fmt.Errorf("invalid latency %s", f.Latency)
+	case f.Jitter < 0:
+		return fmt.Errorf("invalid jitter %s", f.Jitter)
+	case f.DropRate < 0 || f.DropRate >= 1:
+		return fmt.Errorf("invalid drop rate %g (expecting a probability below 1)", f.DropRate)
+	case f.Bandwidth < 0:
+		return fmt.Errorf("invalid bandwidth %d", f.Bandwidth)
+	}
+	return nil
+}
+
+func (f Faults) String() string {
+	if f.Zero() {
+		return "no faults"
+	}
+	var desc []string
+	if f.Partitioned {
+		desc = append(desc, "partitioned")
+	}
+	if f.Latency > 0 || f.Jitter > 0 {
+		desc = append(desc, fmt.Sprintf("latency=%s jitter=%s", f.Latency, f.Jitter))
+	}
+	if f.DropRate > 0 {
+		desc = append(desc, fmt.Sprintf("dropRate=%g", f.DropRate))
+	}
+	if f.Bandwidth > 0 {
+		desc = append(desc, fmt.Sprintf("bandwidth=%dB/s", f.Bandwidth))
+	}
+	return strings.Join(desc, " ")
+}
+
+// Zero returns true if no fault is injected
+func (f Faults) Zero() bool {
+	return f == Faults{}
+}
+
+// Combine returns the faults of data crossing links with [f] and [other] one
+// after the other
+func (f Faults) Combine(other Faults) Faults {
+	res := Faults{
+		Latency:     f.Latency + other.Latency,
+		Jitter:      f.Jitter + other.Jitter,
+		DropRate:    1 - (1-f.DropRate)*(1-other.DropRate),
+		Bandwidth:   f.Bandwidth,
+		Partitioned: f.Partitioned || other.Partitioned,
+	}
+	if res.Bandwidth == 0 || (other.Bandwidth > 0 && other.Bandwidth < res.Bandwidth) {
+		res.Bandwidth = other.Bandwidth
+	}
+	return res
+}
+
+// Direction is a direction of the data sent on a link
+type Direction int
+
+const (
+	// Data sent by the node dialing through the link
+	Outbound Direction = iota
+	// Data sent back by the node the link dials
+	Inbound
+)
+
+// Slot lets a single connection at a time through the links sharing it.
+//
+// Two nodes dialing each other at the same time each keep one of the two
+// connections and close the other as a duplicate, which leaves them with no
+// connection at all if they pick different ones. Sharing a slot between the
+// links of both directions makes the second dial fail before its handshake
+// instead, so that the node retries it once the first connection is closed.
+type Slot struct {
+	lock sync.Mutex
+	busy bool
+}
+
+// acquire returns false if a connection already holds the slot
+func (s *Slot) acquire() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.busy {
+		return false
+	}
+	s.busy = true
+	return true
+}
+
+func (s *Slot) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.busy = false
+}
+
+// Link forwards the connections accepted on its address to a target address,
+// injecting its faults on them
+type Link struct {
+	listener net.Listener
+	target   string
+	slot     *Slot
+
+	lock sync.Mutex
+	// Faults injected on the data sent in each direction
+	faults [2]Faults
+	// Closed and replaced whenever the faults change
+	changed chan struct{}
+	conns   map[net.Conn]struct{}
+	closed  bool
+}
+
+// Listen returns a link forwarding the connections accepted on a free
+// loopback port to [target], one at a time with the other links sharing
+// [slot]
+func Listen(target string, slot *Slot) (*Link, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	l := &Link{
+		listener: listener,
+		target:   target,
+		slot:     slot,
+		changed:  make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go l.accept()
+	return l, nil
+}
+
+// Addr returns the address the link accepts connections on
+func (l *Link) Addr() string {
+	return l.listener.Addr().String()
+}
+
+// Slot returns the slot the link shares with other links
+func (l *Link) Slot() *Slot {
+	return l.slot
+}
+
+// Faults returns the faults injected on the data sent in [dir]
+func (l *Link) Faults(dir Direction) Faults {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.faults[dir]
+}
+
+// SetFaults replaces the faults injected on the data sent in each direction
+// of the link, including on the data already in flight
+func (l *Link) SetFaults(outbound Faults, inbound Faults) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.faults = [2]Faults{outbound, inbound}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Close stops accepting connections and closes the open ones
+func (l *Link) Close() error {
+	l.lock.Lock()
+	l.closed = true
+	for conn := range l.conns {
+		conn.Close()
+	}
+	// Wakes up the pipes waiting for a partition to heal
+	close(l.changed)
+	l.changed = make(chan struct{})
+	l.lock.Unlock()
+	return l.listener.Close()
+}
+
+// state returns the faults injected on the data sent in [dir] and a channel
+// closed once they change
+func (l *Link) state(dir Direction) (Faults, chan struct{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.faults[dir], l.changed
+}
+
+// track records that [conns] are open, returning false if the link is closed
+func (l *Link) track(conns ...net.Conn) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return false
+	}
+	for _, conn := range conns {
+		l.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (l *Link) untrack(conns ...net.Conn) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, conn := range conns {
+		delete(l.conns, conn)
+	}
+}
+
+func (l *Link) accept() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return
+		}
+		go l.forward(conn)
+	}
+}
+
+// forward forwards the data of [conn] to and from a new connection to the
+// target until either side closes its connection
+func (l *Link) forward(conn net.Conn) {
+	if !l.slot.acquire() {
+		conn.Close()
+		return
+	}
+	defer l.slot.release()
+
+	target, err := net.Dial("tcp", l.target)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if !l.track(conn, target) {
+		conn.Close()
+		target.Close()
+		return
+	}
+	defer l.untrack(conn, target)
+
+	var (
+		done    = make(chan struct{}, 2)
+		closing = make(chan struct{})
+	)
+	go func() {
+		l.pipe(target, conn, Outbound, closing)
+		done <- struct{}{}
+	}()
+	go func() {
+		l.pipe(conn, target, Inbound, closing)
+		done <- struct{}{}
+	}()
+	// Closing both connections once a side is done ends the other pipe
+	<-done
+	close(closing)
+	conn.Close()
+	target.Close()
+	<-done
+}
+
+// chunk is data read from a connection, due to be written at [due]
+type chunk struct {
+	data []byte
+	due  time.Time
+}
+
+// pipe copies the data read from [src] to [dst], sent in [dir], until [src]
+// is closed, a write fails or [closing] is closed
+func (l *Link) pipe(dst net.Conn, src net.Conn, dir Direction, closing chan struct{}) {
+	chunks := make(chan chunk, maxQueuedChunks)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(chunks)
+		// TCP delivers data in order, so data cannot overtake the data before
+		// it because of jitter or retransmissions
+		var last time.Time
+		for {
+			buf := make([]byte, chunkSize)
+			n, err := src.Read(buf)
+			if n > 0 {
+				faults, _ := l.state(dir)
+				due := time.Now().Add(faults.Latency)
+				if faults.Jitter > 0 {
+					due = due.Add(time.Duration(rand.Int63n(int64(faults.Jitter)))) // #nosec G404
+				}
+				for rand.Float64() < faults.DropRate { // #nosec G404
+					due = due.Add(minRetransmitTimeout + 2*faults.Latency)
+				}
+				if due.Before(last) {
+					due = last
+				}
+				last = due
+				select {
+				case chunks <- chunk{data: buf[:n], due: due}:
+				case <-stop:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var next time.Time
+	for c := range chunks {
+		faults, err := l.wait(c.due, dir, closing)
+		if err != nil {
+			return
+		}
+		if faults.Bandwidth > 0 {
+			now := time.Now()
+			if next.Before(now) {
+				next = now
+			}
+			time.Sleep(time.Until(next))
+			next = next.Add(time.Duration(len(c.data)) * time.Second / time.Duration(faults.Bandwidth))
+		}
+		if _, err := dst.Write(c.data); err != nil {
+			return
+		}
+	}
+}
+
+var errLinkClosed = errors.New("link closed")
+
+// wait waits until [due] and until [dir] is not partitioned, returning the
+// faults injected on [dir] at that time. It fails if the link or [closing] is
+// closed while [dir] is partitioned.
+func (l *Link) wait(due time.Time, dir Direction, closing chan struct{}) (Faults, error) {
+	time.Sleep(time.Until(due))
+	for {
+		faults, changed := l.state(dir)
+		if !faults.Partitioned {
+			return faults, nil
+		}
+		l.lock.Lock()
+		closed := l.closed
+		l.lock.Unlock()
+		if closed {
+			return faults, errLinkClosed
+		}
+		select {
+		case <-changed:
+		case <-closing:
+			return faults, errLinkClosed
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultsCombine(t *testing.T) {
+	tests := []struct {
+		name     string
+		f, other Faults
+		expected Faults
+	}{
+		{
+			name: "no faults",
+		},
+		{
+			name:     "latency and jitter add up",
+			f:        Faults{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond},
+			other:    Faults{Latency: 50 * time.Millisecond, Jitter: 5 * time.Millisecond},
+			expected: Faults{Latency: 150 * time.Millisecond, Jitter: 15 * time.Millisecond},
+		},
+		{
+			name:     "data crosses both links",
+			f:        Faults{DropRate: 0.5},
+			other:    Faults{DropRate: 0.5},
+			expected: Faults{DropRate: 0.75},
+		},
+		{
+			name:     "single drop rate",
+			f:        Faults{DropRate: 0.25},
+			expected: Faults{DropRate: 0.25},
+		},
+		{
+			name:     "lowest bandwidth",
+			f:        Faults{Bandwidth: 1000},
+			other:    Faults{Bandwidth: 500},
+			expected: Faults{Bandwidth: 500},
+		},
+		{
+			name:     "unlimited bandwidth of the other link",
+			f:        Faults{Bandwidth: 1000},
+			expected: Faults{Bandwidth: 1000},
+		},
+		{
+			name:     "unlimited bandwidth of this link",
+			other:    Faults{Bandwidth: 1000},
+			expected: Faults{Bandwidth: 1000},
+		},
+		{
+			name:     "either link partitioned",
+			f:        Faults{Latency: time.Second},
+			other:    Faults{Partitioned: true},
+			expected: Faults{Latency: time.Second, Partitioned: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if res := test.f.Combine(test.other); res != test.expected {
+				t.Fatalf("expected %+v but got %+v", test.expected, res)
+			}
+			if res := test.other.Combine(test.f); res != test.expected {
+				t.Fatalf("expected %+v in the other order but got %+v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestFaultsJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults Faults
+		json   string
+	}{
+		{"no faults", Faults{}, `{}`},
+		{"latency", Faults{Latency: 150 * time.Millisecond, Jitter: 20 * time.Millisecond}, `{"latency":"150ms","jitter":"20ms"}`},
+		{"drop rate", Faults{DropRate: 0.05}, `{"dropRate":0.05}`},
+		{"all", Faults{Latency: time.Second, DropRate: 0.1, Bandwidth: 1024, Partitioned: true}, `{"latency":"1s","dropRate":0.1,"bandwidth":1024,"partitioned":true}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := json.Marshal(test.faults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.json {
+				t.Fatalf("expected %s but got %s", test.json, b)
+			}
+			var faults Faults
+			if err := json.Unmarshal(b, &faults); err != nil {
+				t.Fatal(err)
+			}
+			if faults != test.faults {
+				t.Fatalf("expected %+v but got %+v", test.faults, faults)
+			}
+		})
+	}
+}
+
+func TestFaultsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"latency without unit", `{"latency": "150"}`, "invalid latency"},
+		{"invalid jitter", `{"jitter": "soon"}`, "invalid jitter"},
+		{"numeric latency", `{"latency": 150}`, "cannot unmarshal"},
+		{"negative latency", `{"latency": "-1ms"}`, "invalid latency -1ms"},
+		{"negative jitter", `{"jitter": "-1ms"}`, "invalid jitter -1ms"},
+		{"certain drop", `{"dropRate": 1}`, "invalid drop rate 1"},
+		{"negative drop rate", `{"dropRate": -0.1}`, "invalid drop rate -0.1"},
+		{"negative bandwidth", `{"bandwidth": -1}`, "invalid bandwidth -1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var faults Faults
+			err := json.Unmarshal([]byte(test.json), &faults)
+			if err == nil {
+				err = faults.Verify()
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
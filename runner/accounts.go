@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ava-labs/ava-sim/accounts"
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/health"
+
+	"github.com/ava-labs/avalanchego/api/info"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	avalancheConstants "github.com/ava-labs/avalanchego/utils/constants"
+	cjson "github.com/ava-labs/avalanchego/utils/json"
+	"github.com/ava-labs/avalanchego/vms/avm"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/fatih/color"
+)
+
+// Gas limit of a C-chain value transfer
+const transferGas = 21000
+
+var (
+	allocationLock    sync.Mutex
+	allocatedAccounts []accounts.Account
+	allocatedBalance  uint64
+)
+
+// AllocateAccounts makes the runner allocate [balance] nAVAX to [accts] in
+// the genesis of the EVM blockchains it deploys (see [accounts.Allocate]).
+// Accounts are no longer allocated funds if [accts] is empty.
+func AllocateAccounts(accts []accounts.Account, balance uint64) {
+	allocationLock.Lock()
+	defer allocationLock.Unlock()
+	allocatedAccounts = accts
+	allocatedBalance = balance
+}
+
+// allocate returns [genesis] with the allocations of [AllocateAccounts]
+func allocate(genesis []byte) []byte {
+	allocationLock.Lock()
+	defer allocationLock.Unlock()
+	return accounts.Allocate(genesis, allocatedAccounts, allocatedBalance)
+}
+
+// HRP returns the prefix of the addresses of the network of [nodeURL]
+func HRP(nodeURL string) (string, error) {
+	networkID, err := info.NewClient(nodeURL, constants.HTTPTimeout).GetNetworkID()
+	if err != nil {
+		return "", fmt.Errorf("could not query network ID: %w", err)
+	}
+	return avalancheConstants.GetHRP(networkID), nil
+}
+
+// FundAccounts sends [balance] nAVAX from the genesis key to each of [accts]
+// on the X, P and C chains of the network of [nodeURL], waiting for the txs to
+// be accepted. Fails once the account funding timeout of [timeouts] passed
+// (see [health.Timeouts.WithDefaults]).
+func FundAccounts(ctx context.Context, nodeURL string, accts []accounts.Account, balance uint64, timeouts health.Timeouts) error {
+	if len(accts) == 0 {
+		return nil
+	}
+	timeouts = timeouts.WithDefaults()
+	return health.Phase(ctx, health.AccountFunding, timeouts.AccountFunding, func(ctx context.Context) error {
+		color.Cyan("funding %d accounts with %d nAVAX on the X, P and C chains", len(accts), balance)
+		if err := fundXP(ctx, nodeURL, accts, balance); err != nil {
+			return err
+		}
+		return fundC(ctx, nodeURL, accts, balance)
+	})
+}
+
+// fundXP funds [accts] on the X-chain, from which each account exports its
+// P-chain funds, as the P-chain has no transfer tx
+func fundXP(ctx context.Context, nodeURL string, accts []accounts.Account, balance uint64) error {
+	pClient, _, err := importGenesisKey(nodeURL)
+	if err != nil {
+		return err
+	}
+	xClient := avm.NewClient(nodeURL, "X", constants.HTTPTimeout)
+	fundedAddress, err := xClient.ImportKey(userPass, GenesisKey)
+	if err != nil {
+		return fmt.Errorf("unable to import genesis key: %w", err)
+	}
+	fees, err := info.NewClient(nodeURL, constants.HTTPTimeout).GetTxFee()
+	if err != nil {
+		return fmt.Errorf("could not query tx fee: %w", err)
+	}
+	fee := uint64(fees.TxFee)
+
+	// Each account is sent its X-chain balance, and its P-chain balance plus
+	// the fees of the export and the import
+	outputs := make([]avm.SendOutput, len(accts))
+	for i, a := range accts {
+		if _, err := xClient.ImportKey(userPass, a.PrivateKey); err != nil {
+			return fmt.Errorf("unable to import key of account %d: %w", a.Index, err)
+		}
+		if _, err := pClient.ImportKey(userPass, a.PrivateKey); err != nil {
+			return fmt.Errorf("unable to import key of account %d: %w", a.Index, err)
+		}
+		outputs[i] = avm.SendOutput{
+			Amount:  cjson.Uint64(2*balance + 2*fee),
+			AssetID: "AVAX",
+			To:      a.XAddress,
+		}
+	}
+	txID, err := xClient.SendMultiple(userPass, []string{fundedAddress}, fundedAddress, outputs, "")
+	if err != nil {
+		return fmt.Errorf("unable to fund accounts: %w", err)
+	}
+	if err := waitXTx(ctx, xClient, txID, "X-chain funding"); err != nil {
+		return err
+	}
+
+	exports := make([]ids.ID, len(accts))
+	for i, a := range accts {
+		exports[i], err = xClient.Export(userPass, []string{a.XAddress}, a.XAddress, balance+fee, a.PAddress, "AVAX")
+		if err != nil {
+			return fmt.Errorf("unable to export funds of account %d: %w", a.Index, err)
+		}
+	}
+	for _, txID := range exports {
+		if err := waitXTx(ctx, xClient, txID, "export to P-chain"); err != nil {
+			return err
+		}
+	}
+	// The P-chain drops atomic txs issued while another one is processing,
+	// so the imports are issued one at a time
+	for _, a := range accts {
+		txID, err := pClient.ImportAVAX(userPass, []string{a.PAddress}, a.PAddress, a.PAddress, "X")
+		if err != nil {
+			return fmt.Errorf("unable to import funds of account %d: %w", a.Index, err)
+		}
+		if err := waitTx(ctx, pClient, txID, "import funds", a.PAddress); err != nil {
+			return err
+		}
+	}
+	color.Cyan("funded %d accounts on the X and P chains", len(accts))
+	return nil
+}
+
+// waitXTx waits for the X-chain tx [txID] of kind [kind] to be accepted,
+// failing if it is rejected
+func waitXTx(ctx context.Context, client avm.Client, txID ids.ID, kind string) error {
+	return health.Poll(ctx, waitTime, fmt.Sprintf("%s tx (%s) to be accepted", kind, txID), func() (bool, string, error) {
+		status, err := client.GetTxStatus(txID)
+		if err != nil {
+			return false, fmt.Sprintf("could not query tx status: %v", err), nil
+		}
+		if status == choices.Rejected {
+			return false, "", fmt.Errorf("%s tx (%s) was rejected", kind, txID)
+		}
+		return status == choices.Accepted, status.String(), nil
+	})
+}
+
+// fundC sends [balance] nAVAX to each of [accts] on the C-chain
+func fundC(ctx context.Context, nodeURL string, accts []accounts.Account, balance uint64) error {
+	client, err := ethclient.DialContext(ctx, nodeURL+"/ext/bc/C/rpc")
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("could not query chain ID: %w", err)
+	}
+	key, err := accounts.ECDSAKey(GenesisKey)
+	if err != nil {
+		return err
+	}
+	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+	if err != nil {
+		return fmt.Errorf("could not query nonce: %w", err)
+	}
+	// The gas price is paid twice over so that the txs are not underpriced
+	// if the base fee rises in between
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("could not query gas price: %w", err)
+	}
+	gasPrice.Mul(gasPrice, big.NewInt(2))
+
+	signer := types.LatestSignerForChainID(chainID)
+	hashes := make([]common.Hash, len(accts))
+	for i, a := range accts {
+		to := common.HexToAddress(a.EthAddress)
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    nonce + uint64(i),
+			GasPrice: gasPrice,
+			Gas:      transferGas,
+			To:       &to,
+			Value:    accounts.WeiBalance(balance),
+		})
+		if err != nil {
+			return err
+		}
+		if err := client.SendTransaction(ctx, tx); err != nil {
+			return fmt.Errorf("unable to fund account %d: %w", a.Index, err)
+		}
+		hashes[i] = tx.Hash()
+	}
+	for _, hash := range hashes {
+		err := health.Poll(ctx, waitTime, fmt.Sprintf("C-chain funding tx (%s) to be accepted", hash.Hex()), func() (bool, string, error) {
+			receipt, err := client.TransactionReceipt(ctx, hash)
+			switch {
+			case errors.Is(err, ethereum.NotFound):
+				return false, "pending", nil
+			case err != nil:
+				return false, fmt.Sprintf("could not query receipt: %v", err), nil
+			case receipt.Status != types.ReceiptStatusSuccessful:
+				return false, "", fmt.Errorf("C-chain funding tx (%s) failed", hash.Hex())
+			}
+			return true, "", nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	color.Cyan("funded %d accounts on the C-chain", len(accts))
+	return nil
+}
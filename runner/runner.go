@@ -4,60 +4,192 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"sync"
 	"time"
 
 	"github.com/ava-labs/ava-sim/constants"
-	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/health"
+	"github.com/ava-labs/ava-sim/metrics"
 
 	"github.com/ava-labs/avalanchego/api"
-	"github.com/ava-labs/avalanchego/api/info"
 	"github.com/ava-labs/avalanchego/api/keystore"
 	"github.com/ava-labs/avalanchego/ids"
+	avalancheConstants "github.com/ava-labs/avalanchego/utils/constants"
 	"github.com/ava-labs/avalanchego/vms/platformvm"
 	"github.com/fatih/color"
 )
 
 const (
-	genesisKey   = "PrivateKey-ewoqjP7PxY4yr3iLTpLisriqt94hdyDFNgchSxGGztUrTXtNN"
-	waitTime     = 1 * time.Second
-	longWaitTime = 10 * waitTime
+	// GenesisKey is the private key funded on the X, P and C chains by the
+	// genesis of local networks
+	GenesisKey = "PrivateKey-ewoqjP7PxY4yr3iLTpLisriqt94hdyDFNgchSxGGztUrTXtNN"
+	waitTime   = 1 * time.Second
+
+	DefaultValidatorWeight = 50
 
-	validatorWeight    = 50
 	validatorStartDiff = 30 * time.Second
 	validatorEndDiff   = 30 * 24 * time.Hour // 30 days
+
+	// Nodes that are not genesis validators must validate the primary
+	// network for longer than they validate the subnet
+	primaryValidatorStake   = 5000000 // --min-validator-stake
+	primaryValidatorEndDiff = 2 * validatorEndDiff
+	primaryValidatorFeeRate = 2
 )
 
-func SetupSubnet(ctx context.Context, vmGenesis string) error {
-	color.Cyan("creating subnet")
-	var (
-		nodeURLs = manager.NodeURLs()
-		nodeIDs  = manager.NodeIDs()
+var userPass = api.UserPass{
+	Username: "test",
+	Password: "vmsrkewl",
+}
+
+var (
+	txRecorderLock sync.Mutex
+	txRecorder     func(Tx)
+)
+
+// Tx is a P-chain tx issued to set up a network
+type Tx struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	// Issued until the status of the tx is known, then Processing,
+	// Committed, Aborted or Dropped
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// Time the status was last updated
+	Time time.Time `json:"time"`
+}
+
+// RecordTxs makes the runner call [record] with the txs it issues whenever
+// their status changes, e.g. to find the tx a setup got stuck on. Txs are no
+// longer recorded if [record] is nil.
+func RecordTxs(record func(Tx)) {
+	txRecorderLock.Lock()
+	defer txRecorderLock.Unlock()
+	txRecorder = record
+}
 
-		userPass = api.UserPass{
-			Username: "test",
-			Password: "vmsrkewl",
+// recordTx records the tx [txID] described by [desc] with [status]
+func recordTx(txID ids.ID, desc string, status string, reason string) {
+	txRecorderLock.Lock()
+	record := txRecorder
+	txRecorderLock.Unlock()
+	if record == nil {
+		return
+	}
+	record(Tx{
+		ID:          txID.String(),
+		Description: desc,
+		Status:      status,
+		Reason:      reason,
+		Time:        time.Now(),
+	})
+}
+
+// Subnet describes a subnet to create and the blockchains to deploy on it
+type Subnet struct {
+	Name        string
+	Validators  []Validator
+	Blockchains []Blockchain
+}
+
+// Validator is a node validating a subnet with [Weight]
+type Validator struct {
+	NodeID string
+	Weight uint64
+	// Whether the node validates the subnet without running its blockchains
+	// (see manager.Behavior), in which case they are not waited for
+	Mute bool
+}
+
+// Blockchain is a blockchain of the VM with ID [VMID] created from [Genesis]
+type Blockchain struct {
+	Name    string
+	VMID    string
+	Genesis []byte
+}
+
+// MuteValidators marks the validators whose node IDs are in [muted] as muted
+func (s *Subnet) MuteValidators(muted map[string]bool) {
+	for i := range s.Validators {
+		s.Validators[i].Mute = muted[s.Validators[i].NodeID]
+	}
+}
+
+// DefaultSubnet returns a subnet validated by all of [nodeIDs] with equal
+// weight that runs a single blockchain of the custom VM
+func DefaultSubnet(nodeIDs []string, genesis []byte) Subnet {
+	validators := make([]Validator, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		validators[i] = Validator{
+			NodeID: nodeID,
+			Weight: DefaultValidatorWeight,
 		}
-	)
+	}
+	return Subnet{
+		Name:       constants.VMName,
+		Validators: validators,
+		Blockchains: []Blockchain{{
+			Name:    constants.VMName,
+			VMID:    constants.VMID,
+			Genesis: genesis,
+		}},
+	}
+}
 
+// importGenesisKey imports the funded genesis key into the keystore of the
+// node at [nodeURL], returning a client of the node and the funded address
+func importGenesisKey(nodeURL string) (platformvm.Client, string, error) {
 	// Create user
-	kclient := keystore.NewClient(nodeURLs[0], constants.HTTPTimeout)
-	ok, err := kclient.CreateUser(userPass)
-	if !ok || err != nil {
-		return fmt.Errorf("could not create user: %w", err)
+	kclient := keystore.NewClient(nodeURL, constants.HTTPTimeout)
+	users, err := kclient.ListUsers()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not list users: %w", err)
+	}
+	exists := false
+	for _, user := range users {
+		exists = exists || user == userPass.Username
+	}
+	if !exists {
+		ok, err := kclient.CreateUser(userPass)
+		if !ok || err != nil {
+			return nil, "", fmt.Errorf("could not create user: %w", err)
+		}
 	}
 
 	// Connect to local network
-	client := platformvm.NewClient(nodeURLs[0], constants.HTTPTimeout)
+	client := platformvm.NewClient(nodeURL, constants.HTTPTimeout)
 
 	// Import genesis key
-	fundedAddress, err := client.ImportKey(userPass, genesisKey)
+	fundedAddress, err := client.ImportKey(userPass, GenesisKey)
 	if err != nil {
-		return fmt.Errorf("unable to import genesis key: %w", err)
+		return nil, "", fmt.Errorf("unable to import genesis key: %w", err)
+	}
+	return client, fundedAddress, nil
+}
+
+// CreateSubnet creates a subnet controlled by the genesis key on the network
+// of [nodeURL] and returns its ID, failing once the subnet creation timeout of
+// [timeouts] passed (see [health.Timeouts.WithDefaults])
+func CreateSubnet(ctx context.Context, nodeURL string, timeouts health.Timeouts) (ids.ID, error) {
+	var subnetID ids.ID
+	timeouts = timeouts.WithDefaults()
+	err := health.Phase(ctx, health.SubnetCreation, timeouts.SubnetCreation, func(ctx context.Context) error {
+		var err error
+		subnetID, err = createSubnet(ctx, nodeURL)
+		return err
+	})
+	return subnetID, err
+}
+
+func createSubnet(ctx context.Context, nodeURL string) (ids.ID, error) {
+	color.Cyan("creating subnet")
+	client, fundedAddress, err := importGenesisKey(nodeURL)
+	if err != nil {
+		return ids.Empty, err
 	}
 	balance, err := client.GetBalance(fundedAddress)
 	if err != nil {
-		return fmt.Errorf("unable to get genesis key balance: %w", err)
+		return ids.Empty, fmt.Errorf("unable to get genesis key balance: %w", err)
 	}
 	color.Cyan("found %d on address %s", balance, fundedAddress)
 
@@ -70,138 +202,326 @@ func SetupSubnet(ctx context.Context, vmGenesis string) error {
 		1,
 	)
 	if err != nil {
-		return fmt.Errorf("unable to create subnet: %w", err)
+		return ids.Empty, fmt.Errorf("unable to create subnet: %w", err)
 	}
 
-	for {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		status, _ := client.GetTxStatus(subnetIDTx, true)
-		if status.Status == platformvm.Committed {
-			break
-		}
-		color.Yellow("waiting for subnet creation tx (%s) to be accepted", subnetIDTx)
-		time.Sleep(waitTime)
+	if err := waitTx(ctx, client, subnetIDTx, "subnet creation", ""); err != nil {
+		return ids.Empty, err
 	}
-	color.Cyan("subnet creation tx (%s) accepted", subnetIDTx)
 
-	// Confirm created subnet appears in subnet list
-	subnets, err := client.GetSubnets([]ids.ID{})
+	// Confirm created subnet appears in subnet list (the ID of a subnet is
+	// the ID of the tx that created it)
+	subnets, err := client.GetSubnets([]ids.ID{subnetIDTx})
 	if err != nil {
-		return fmt.Errorf("cannot query subnets: %w", err)
+		return ids.Empty, fmt.Errorf("cannot query subnets: %w", err)
 	}
-	rSubnetID := subnets[0].ID
-	subnetID := rSubnetID.String()
-	if subnetID != constants.WhitelistedSubnets {
-		return fmt.Errorf("expected subnet %s but got %s", constants.WhitelistedSubnets, subnetID)
+	if len(subnets) != 1 || subnets[0].ID != subnetIDTx {
+		return ids.Empty, fmt.Errorf("could not find subnet %s", subnetIDTx)
 	}
+	return subnetIDTx, nil
+}
 
-	// Add all validators to subnet with equal weight
-	for _, nodeID := range manager.NodeIDs() {
-		txID, err := client.AddSubnetValidator(
-			userPass, []string{fundedAddress}, fundedAddress,
-			subnetID, nodeID, validatorWeight,
-			uint64(time.Now().Add(validatorStartDiff).Unix()),
-			uint64(time.Now().Add(validatorEndDiff).Unix()),
-		)
-		if err != nil {
-			return fmt.Errorf("unable to add subnet validator: %w", err)
-		}
+// SetupSubnet adds the validators of [subnet] to the subnet [rSubnetID] on
+// the network of [nodeURLs], deploys its blockchains and waits for its
+// validators to validate and bootstrap each of them. Validators and
+// blockchains (matched by name) that already exist are skipped, so that a
+// resumed network can be set up again. The validator addition and the
+// deployment of the blockchains fail once their timeout in [timeouts]
+// passed (see [health.Timeouts.WithDefaults]). Returns the IDs of the blockchains.
+func SetupSubnet(ctx context.Context, nodeURLs []string, nodeIDs []string, rSubnetID ids.ID, subnet Subnet, timeouts health.Timeouts) ([]ids.ID, error) {
+	color.Cyan("setting up subnet %s (%s)", subnet.Name, rSubnetID)
+	timeouts = timeouts.WithDefaults()
+	client, fundedAddress, err := importGenesisKey(nodeURLs[0])
+	if err != nil {
+		return nil, err
+	}
 
-		for {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			status, _ := client.GetTxStatus(txID, true)
-			if status.Status == platformvm.Committed {
-				break
-			}
-			color.Yellow("waiting for add subnet validator (%s) tx (%s) to be accepted", nodeID, txID)
-			time.Sleep(waitTime)
+	// Add any validators that are not yet validating the primary network and
+	// the subnet
+	validatorIDs := make([]string, len(subnet.Validators))
+	for i, validator := range subnet.Validators {
+		validatorIDs[i] = validator.NodeID
+	}
+	err = health.Phase(ctx, health.ValidatorAddition, timeouts.ValidatorAddition, func(ctx context.Context) error {
+		if err := addPrimaryValidators(ctx, client, fundedAddress, validatorIDs); err != nil {
+			return err
 		}
-		color.Cyan("add subnet validator (%s) tx (%s) accepted", nodeID, txID)
+		return addSubnetValidators(ctx, client, fundedAddress, rSubnetID, subnet.Validators)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create blockchain
-	genesis, err := ioutil.ReadFile(vmGenesis)
+	var blockchainIDs []ids.ID
+	err = health.Phase(ctx, health.ChainBootstrap, timeouts.ChainBootstrap, func(ctx context.Context) error {
+		var err error
+		blockchainIDs, err = deployBlockchains(ctx, client, fundedAddress, nodeURLs, nodeIDs, rSubnetID, subnet)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("could not read genesis file (%s): %w", vmGenesis, err)
+		return nil, err
 	}
-	txID, err := client.CreateBlockchain(
-		userPass, []string{fundedAddress}, fundedAddress, rSubnetID,
-		constants.VMID, []string{}, constants.VMName, genesis,
-	)
+	return blockchainIDs, nil
+}
+
+// deployBlockchains creates the blockchains of [subnet] that do not exist yet
+// on the subnet [rSubnetID] and waits for its validators to validate and
+// bootstrap each of them, returning their IDs
+func deployBlockchains(ctx context.Context, client platformvm.Client, fundedAddress string, nodeURLs []string, nodeIDs []string, rSubnetID ids.ID, subnet Subnet) ([]ids.ID, error) {
+	// Create blockchains
+	blockchains, err := client.GetBlockchains()
 	if err != nil {
-		return fmt.Errorf("could not create blockchain: %w", err)
+		return nil, fmt.Errorf("could not query blockchains: %w", err)
 	}
-	for {
-		if ctx.Err() != nil {
-			return ctx.Err()
+	existing := make(map[string]ids.ID)
+	for _, blockchain := range blockchains {
+		if blockchain.SubnetID == rSubnetID {
+			existing[blockchain.Name] = blockchain.ID
 		}
-		status, _ := client.GetTxStatus(txID, true)
-		if status.Status == platformvm.Committed {
-			break
+	}
+	blockchainIDs := make([]ids.ID, len(subnet.Blockchains))
+	for i, blockchain := range subnet.Blockchains {
+		if blockchainID, ok := existing[blockchain.Name]; ok {
+			color.Cyan("blockchain %q already exists (%s)", blockchain.Name, blockchainID)
+			blockchainIDs[i] = blockchainID
+			continue
 		}
-		color.Yellow("waiting for create blockchain tx (%s) to be accepted", txID)
-		time.Sleep(waitTime)
+		txID, err := client.CreateBlockchain(
+			userPass, []string{fundedAddress}, fundedAddress, rSubnetID,
+			blockchain.VMID, []string{}, blockchain.Name, allocate(blockchain.Genesis),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not create blockchain %q: %w", blockchain.Name, err)
+		}
+		if err := waitTx(ctx, client, txID, "create blockchain", ""); err != nil {
+			return nil, err
+		}
+		blockchainIDs[i] = txID
 	}
-	color.Cyan("create blockchain tx (%s) accepted", txID)
 
-	// Validate blockchain exists
-	blockchains, err := client.GetBlockchains()
+	// Validate blockchains exist
+	blockchains, err = client.GetBlockchains()
 	if err != nil {
-		return fmt.Errorf("could not query blockchains: %w", err)
+		return nil, fmt.Errorf("could not query blockchains: %w", err)
 	}
-	var blockchainID ids.ID
+	created := make(map[ids.ID]struct{}, len(blockchains))
 	for _, blockchain := range blockchains {
 		if blockchain.SubnetID == rSubnetID {
-			blockchainID = blockchain.ID
-			break
+			created[blockchain.ID] = struct{}{}
 		}
 	}
-	if blockchainID == (ids.ID{}) {
-		return errors.New("could not find blockchain")
+	for _, blockchainID := range blockchainIDs {
+		if _, ok := created[blockchainID]; !ok {
+			return nil, fmt.Errorf("could not find blockchain %s", blockchainID)
+		}
 	}
 
-	// Ensure all nodes are validating subnet
-	for i, url := range nodeURLs {
-		nClient := platformvm.NewClient(url, constants.HTTPTimeout)
-		for {
-			if ctx.Err() != nil {
-				return ctx.Err()
+	validatorURLs := make([]string, len(subnet.Validators))
+	for i, validator := range subnet.Validators {
+		for j, nodeID := range nodeIDs {
+			if nodeID == validator.NodeID {
+				validatorURLs[i] = nodeURLs[j]
 			}
-			status, _ := nClient.GetBlockchainStatus(blockchainID.String())
-			if status == platformvm.Validating {
-				break
-			}
-			color.Yellow("waiting for validating status for %s", nodeIDs[i])
-			time.Sleep(longWaitTime)
 		}
-		color.Cyan("%s validating blockchain %s", nodeIDs[i], blockchainID)
+		if len(validatorURLs[i]) == 0 {
+			return nil, fmt.Errorf("validator %s is not part of the network", validator.NodeID)
+		}
 	}
 
-	// Ensure network bootstrapped
-	for i, url := range nodeURLs {
-		nClient := info.NewClient(url, constants.HTTPTimeout)
-		for {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			bootstrapped, _ := nClient.IsBootstrapped(blockchainID.String())
-			if bootstrapped {
-				break
-			}
-			color.Yellow("waiting for %s to bootstrap %s", nodeIDs[i], blockchainID.String())
-			time.Sleep(waitTime)
+	// Ensure all validators are validating and bootstrapped the blockchains
+	validating := make([]string, len(blockchainIDs))
+	for i, blockchainID := range blockchainIDs {
+		validating[i] = blockchainID.String()
+	}
+	for i, url := range validatorURLs {
+		nodeID := subnet.Validators[i].NodeID
+		if subnet.Validators[i].Mute {
+			color.Yellow("%s is muted on blockchains %v", nodeID, blockchainIDs)
+			continue
 		}
-		color.Cyan("%s bootstrapped %s", nodeIDs[i], blockchainID)
+		node := health.Node{ID: nodeID, URL: url}
+		if _, err := health.Wait(ctx, node, health.Requirements{Validating: validating}, waitTime); err != nil {
+			return nil, err
+		}
+		color.Cyan("%s validating and bootstrapped blockchains %v", nodeID, blockchainIDs)
 	}
 
 	// Print endpoints where VM is accessible
 	color.Green("Custom VM endpoints now accessible at:")
-	for i, url := range nodeURLs {
-		color.Green("%s: %s/ext/bc/%s", nodeIDs[i], url, blockchainID.String())
+	for _, blockchainID := range blockchainIDs {
+		for i, url := range validatorURLs {
+			if subnet.Validators[i].Mute {
+				continue
+			}
+			color.Green("%s: %s/ext/bc/%s", subnet.Validators[i].NodeID, url, blockchainID.String())
+		}
+	}
+	return blockchainIDs, nil
+}
+
+// AddValidator adds [nodeID] as a validator of the primary network and of
+// the subnets [subnetIDs] with [weight] on the network of [nodeURL], waiting
+// for the txs to be accepted. Nodes start validating shortly after their txs
+// are accepted. Validators that already exist are skipped. Fails once the
+// validator addition timeout of [timeouts] passed (see
+// [health.Timeouts.WithDefaults]).
+func AddValidator(ctx context.Context, nodeURL string, nodeID string, weight uint64, subnetIDs []ids.ID, timeouts health.Timeouts) error {
+	client, fundedAddress, err := importGenesisKey(nodeURL)
+	if err != nil {
+		return err
+	}
+	timeouts = timeouts.WithDefaults()
+	return health.Phase(ctx, health.ValidatorAddition, timeouts.ValidatorAddition, func(ctx context.Context) error {
+		if err := addPrimaryValidators(ctx, client, fundedAddress, []string{nodeID}); err != nil {
+			return err
+		}
+		for _, subnetID := range subnetIDs {
+			validators := []Validator{{NodeID: nodeID, Weight: weight}}
+			if err := addSubnetValidators(ctx, client, fundedAddress, subnetID, validators); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addPrimaryValidators adds any of [nodeIDs] that are not yet validating the
+// primary network as validators, staking the funds of [fundedAddress]
+func addPrimaryValidators(ctx context.Context, client platformvm.Client, fundedAddress string, nodeIDs []string) error {
+	validators, err := validatorSet(client, avalancheConstants.PrimaryNetworkID)
+	if err != nil {
+		return fmt.Errorf("cannot query primary network validators: %w", err)
+	}
+	for _, nodeID := range nodeIDs {
+		if _, ok := validators[nodeID]; ok {
+			continue
+		}
+		txID, err := client.AddValidator(
+			userPass, []string{fundedAddress}, fundedAddress,
+			fundedAddress, nodeID, primaryValidatorStake,
+			uint64(time.Now().Add(validatorStartDiff).Unix()),
+			uint64(time.Now().Add(primaryValidatorEndDiff).Unix()),
+			primaryValidatorFeeRate,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to add primary network validator: %w", err)
+		}
+
+		if err := waitTx(ctx, client, txID, "add validator", nodeID); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// addSubnetValidators adds any of [validators] that are not yet validating
+// [subnetID] as validators of the subnet, which is controlled by
+// [fundedAddress]
+func addSubnetValidators(ctx context.Context, client platformvm.Client, fundedAddress string, subnetID ids.ID, validators []Validator) error {
+	subnetValidators, err := validatorSet(client, subnetID)
+	if err != nil {
+		return fmt.Errorf("cannot query subnet validators: %w", err)
+	}
+	for _, validator := range validators {
+		nodeID := validator.NodeID
+		if _, ok := subnetValidators[nodeID]; ok {
+			color.Cyan("%s already validates subnet %s", nodeID, subnetID)
+			continue
+		}
+		txID, err := client.AddSubnetValidator(
+			userPass, []string{fundedAddress}, fundedAddress,
+			subnetID.String(), nodeID, validator.Weight,
+			uint64(time.Now().Add(validatorStartDiff).Unix()),
+			uint64(time.Now().Add(validatorEndDiff).Unix()),
+		)
+		if err != nil {
+			return fmt.Errorf("unable to add subnet validator: %w", err)
+		}
+
+		if err := waitTx(ctx, client, txID, "add subnet validator", nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitTx waits for the tx [txID] of kind [kind], concerning [subject] (e.g.
+// a node ID) if it is not empty, to be committed, failing if it is aborted or
+// dropped
+func waitTx(ctx context.Context, client platformvm.Client, txID ids.ID, kind string, subject string) error {
+	desc := kind
+	if len(subject) > 0 {
+		desc = fmt.Sprintf("%s (%s)", kind, subject)
+	}
+	issued := time.Now()
+	recordTx(txID, desc, "Issued", "")
+	last := platformvm.Unknown
+	err := health.Poll(ctx, waitTime, fmt.Sprintf("%s tx (%s) to be accepted", desc, txID), func() (bool, string, error) {
+		status, err := client.GetTxStatus(txID, true)
+		if err != nil {
+			return false, fmt.Sprintf("could not query tx status: %v", err), nil
+		}
+		if status.Status != last {
+			recordTx(txID, desc, status.Status.String(), status.Reason)
+			last = status.Status
+			switch last {
+			case platformvm.Committed, platformvm.Aborted, platformvm.Dropped:
+				metrics.ObserveTx(kind, last.String(), time.Since(issued))
+			}
+		}
+		switch status.Status {
+		case platformvm.Committed:
+			return true, "", nil
+		case platformvm.Aborted, platformvm.Dropped:
+			return false, "", fmt.Errorf("%s tx (%s) was %s: %s", desc, txID, status.Status, status.Reason)
+		}
+		return false, status.Status.String(), nil
+	})
+	if err != nil {
+		return err
+	}
+	color.Cyan("%s tx (%s) accepted", desc, txID)
+	return nil
+}
+
+// validatorSet returns the IDs of all current and pending validators of
+// [subnetID]
+func validatorSet(client platformvm.Client, subnetID ids.ID) (map[string]struct{}, error) {
+	current, err := client.GetCurrentValidators(subnetID, nil)
+	if err != nil {
+		return nil, err
+	}
+	pending, _, err := client.GetPendingValidators(subnetID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make(map[string]struct{})
+	for _, v := range append(current, pending...) {
+		validator, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected validator format %T", v)
+		}
+		nodeID, ok := validator["nodeID"].(string)
+		if !ok {
+			return nil, errors.New("validator is missing nodeID")
+		}
+		validators[nodeID] = struct{}{}
+	}
+	return validators, nil
+}
+
+// SubnetValidators returns the current and pending validators of [subnetID]
+// on the network of [nodeURL]. Their weights are not set.
+func SubnetValidators(nodeURL string, subnetID ids.ID) ([]Validator, error) {
+	client := platformvm.NewClient(nodeURL, constants.HTTPTimeout)
+	set, err := validatorSet(client, subnetID)
+	if err != nil {
+		return nil, err
+	}
+	validators := make([]Validator, 0, len(set))
+	for nodeID := range set {
+		validators = append(validators, Validator{NodeID: nodeID})
+	}
+	return validators, nil
+}
@@ -51,4 +51,4 @@ cat <<EOF > $subnetevm_genesis_path
 }
 EOF
 
-source "$MAIN_PATH"/scripts/run.sh $subnetevm_path $subnetevm_genesis_path
+source "$MAIN_PATH"/scripts/run.sh start --vm "$subnetevm_path" --vm-genesis "$subnetevm_genesis_path" "$@"
@@ -9,4 +9,4 @@ timestamp_genesis_path="${build_dir}/timestampvm/genesis.txt"
 touch $timestamp_genesis_path
 echo "fP1vxkpyLWnH9dD6BQA" > $timestamp_genesis_path
 
-source "$MAIN_PATH"/scripts/run.sh $timestampvm_path $timestamp_genesis_path
+source "$MAIN_PATH"/scripts/run.sh start --vm "$timestampvm_path" --vm-genesis "$timestamp_genesis_path" "$@"
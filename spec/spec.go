@@ -0,0 +1,365 @@
+package spec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/ava-sim/chaos"
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/manager"
+	"github.com/ava-labs/ava-sim/network"
+	"github.com/ava-labs/ava-sim/runner"
+	"github.com/ava-labs/ava-sim/utils"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"gopkg.in/yaml.v2"
+)
+
+// Spec declares the layout of a network: its nodes, the subnets to create and
+// the blockchains to deploy on them. Specs are written in YAML or JSON.
+type Spec struct {
+	NumNodes int `yaml:"numNodes"`
+	BasePort int `yaml:"basePort"`
+	// Pick free ports instead of deriving them from BasePort
+	DynamicPorts bool   `yaml:"dynamicPorts"`
+	LogLevel     string `yaml:"logLevel"`
+	// Route the staking connections between nodes through links that can
+	// inject faults
+	Faults bool `yaml:"faults"`
+	// Path to an avalanchego binary to run each node in a separate process
+	// with, relative to the spec file
+	AvalancheGoPath string `yaml:"avalanchegoPath"`
+	// Avalanchego flags applied to every node
+	NodeFlags map[string]interface{} `yaml:"nodeFlags"`
+	// Per-node settings keyed by node name (node1, node2, ...)
+	Nodes   map[string]Node `yaml:"nodes"`
+	Subnets []Subnet        `yaml:"subnets"`
+	// Disrupt the nodes at random once the subnets are set up
+	Chaos *Chaos `yaml:"chaos"`
+	// Test accounts to fund once the network is bootstrapped
+	Accounts *Accounts `yaml:"accounts"`
+}
+
+type Node struct {
+	// Avalanchego flags applied to this node, overriding [Spec.NodeFlags]
+	Flags map[string]interface{} `yaml:"flags"`
+	// Path to the avalanchego binary of this node, overriding
+	// [Spec.AvalancheGoPath]
+	AvalancheGoPath string `yaml:"avalanchegoPath"`
+	// Make the node misbehave once the network is bootstrapped
+	Byzantine *Byzantine `yaml:"byzantine"`
+}
+
+// Byzantine describes how a byzantine node misbehaves (see manager.Behavior)
+type Byzantine struct {
+	Unresponsive bool          `yaml:"unresponsive"`
+	Delay        time.Duration `yaml:"delay"`
+	// Names of spec subnets or IDs of subnets
+	MuteSubnets []string `yaml:"muteSubnets"`
+}
+
+type Subnet struct {
+	Name string `yaml:"name"`
+	// Defaults to all nodes with equal weight
+	Validators  []Validator  `yaml:"validators"`
+	Blockchains []Blockchain `yaml:"blockchains"`
+}
+
+type Validator struct {
+	Node   string `yaml:"node"`
+	Weight uint64 `yaml:"weight"`
+}
+
+// Chaos describes the disruptions of a chaos run (see chaos.Config)
+type Chaos struct {
+	// Defaults to a random seed
+	Seed     *int64        `yaml:"seed"`
+	Interval time.Duration `yaml:"interval"`
+	Downtime time.Duration `yaml:"downtime"`
+	Quorum   int           `yaml:"quorum"`
+	Actions  []string      `yaml:"actions"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Accounts describes the test accounts funded on the network (see
+// network.Options)
+type Accounts struct {
+	Count int `yaml:"count"`
+	// Defaults to accounts.DefaultMnemonic
+	Mnemonic string `yaml:"mnemonic"`
+	// Balance of each account on each chain, in AVAX. Defaults to
+	// accounts.DefaultBalance.
+	Balance uint64 `yaml:"balance"`
+}
+
+type Blockchain struct {
+	Name string `yaml:"name"`
+	// Paths to the VM binary and the blockchain genesis, relative to the spec
+	// file
+	VM      string `yaml:"vm"`
+	Genesis string `yaml:"genesis"`
+	// ID of the VM, either given explicitly or derived from its name.
+	// Defaults to the ID of the single custom VM supported by --vm.
+	VMID   string `yaml:"vmID"`
+	VMName string `yaml:"vmName"`
+}
+
+// Load reads and validates the spec at [path]
+func Load(path string) (*Spec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spec{}
+	if err := yaml.UnmarshalStrict(b, s); err != nil {
+		return nil, fmt.Errorf("could not parse spec %s: %w", path, err)
+	}
+
+	// Resolve paths relative to the spec file
+	dir := filepath.Dir(path)
+	s.AvalancheGoPath = resolvePath(dir, s.AvalancheGoPath)
+	for name, node := range s.Nodes {
+		node.AvalancheGoPath = resolvePath(dir, node.AvalancheGoPath)
+		s.Nodes[name] = node
+	}
+	for i := range s.Subnets {
+		for j := range s.Subnets[i].Blockchains {
+			b := &s.Subnets[i].Blockchains[j]
+			b.VM = resolvePath(dir, b.VM)
+			b.Genesis = resolvePath(dir, b.Genesis)
+		}
+	}
+	if err := s.verify(); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func resolvePath(dir, path string) string {
+	if len(path) == 0 || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func (s *Spec) verify() error {
+	if s.NumNodes == 0 {
+		s.NumNodes = constants.DefaultNumNodes
+	}
+	if s.NumNodes < 0 {
+		return fmt.Errorf("invalid numNodes %d", s.NumNodes)
+	}
+	for name, node := range s.Nodes {
+		if _, err := s.nodeIndex(name); err != nil {
+			return err
+		}
+		if node.Byzantine != nil {
+			if err := node.Byzantine.behavior().Verify(); err != nil {
+				return fmt.Errorf("invalid byzantine behavior of %s: %w", name, err)
+			}
+		}
+	}
+
+	// VM binaries keyed by VM ID
+	vms := make(map[string]string)
+	names := make(map[string]bool)
+	for i := range s.Subnets {
+		subnet := &s.Subnets[i]
+		if len(subnet.Name) == 0 {
+			return fmt.Errorf("subnet %d is missing a name", i)
+		}
+		if names[subnet.Name] {
+			return fmt.Errorf("duplicate subnet %s", subnet.Name)
+		}
+		names[subnet.Name] = true
+		for _, validator := range subnet.Validators {
+			if _, err := s.nodeIndex(validator.Node); err != nil {
+				return fmt.Errorf("subnet %s: %w", subnet.Name, err)
+			}
+		}
+		for j := range subnet.Blockchains {
+			blockchain := &subnet.Blockchains[j]
+			if len(blockchain.Name) == 0 {
+				return fmt.Errorf("subnet %s has a blockchain without a name", subnet.Name)
+			}
+			for _, file := range []string{blockchain.VM, blockchain.Genesis} {
+				if _, err := os.Stat(file); err != nil {
+					return fmt.Errorf("blockchain %s: %w", blockchain.Name, err)
+				}
+			}
+
+			switch {
+			case len(blockchain.VMID) > 0 && len(blockchain.VMName) > 0:
+				return fmt.Errorf("blockchain %s: vmID and vmName are mutually exclusive", blockchain.Name)
+			case len(blockchain.VMID) > 0:
+				if _, err := ids.FromString(blockchain.VMID); err != nil {
+					return fmt.Errorf("blockchain %s: invalid vmID: %w", blockchain.Name, err)
+				}
+			case len(blockchain.VMName) > 0:
+				vmID, err := utils.VMID(blockchain.VMName)
+				if err != nil {
+					return fmt.Errorf("blockchain %s: %w", blockchain.Name, err)
+				}
+				blockchain.VMID = vmID
+			default:
+				blockchain.VMID = constants.VMID
+			}
+			if vm, ok := vms[blockchain.VMID]; ok && vm != blockchain.VM {
+				return fmt.Errorf("VM %s is used with different binaries (%s and %s)", blockchain.VMID, vm, blockchain.VM)
+			}
+			vms[blockchain.VMID] = blockchain.VM
+		}
+	}
+	if s.Chaos != nil {
+		if _, err := s.ChaosConfig(); err != nil {
+			return err
+		}
+	}
+	if s.Accounts != nil && s.Accounts.Count < 0 {
+		return fmt.Errorf("invalid number of accounts %d", s.Accounts.Count)
+	}
+	return nil
+}
+
+// nodeIndex returns the index of the node named [name]
+func (s *Spec) nodeIndex(name string) (int, error) {
+	index, err := strconv.Atoi(strings.TrimPrefix(name, "node"))
+	if !strings.HasPrefix(name, "node") || err != nil || index < 1 || index > s.NumNodes {
+		return 0, fmt.Errorf("unknown node %q (expecting node1 to node%d)", name, s.NumNodes)
+	}
+	return index - 1, nil
+}
+
+// Options returns the options of the network
+func (s *Spec) Options() network.Options {
+	config := network.Options{
+		NumNodes:             s.NumNodes,
+		BasePort:             s.BasePort,
+		DynamicPorts:         s.DynamicPorts,
+		Faults:               s.Faults,
+		AvalancheGoPath:      s.AvalancheGoPath,
+		NodeAvalancheGoPaths: make(map[int]string),
+		LogLevel:             s.LogLevel,
+		Flags:                make(map[string]string),
+		NodeFlags:            make(map[int]map[string]string),
+	}
+	for name, value := range s.NodeFlags {
+		config.Flags[name] = fmt.Sprint(value)
+	}
+	for i := 0; i < s.NumNodes; i++ {
+		node := s.Nodes[fmt.Sprintf("node%d", i+1)]
+		flags := make(map[string]string)
+		for name, value := range node.Flags {
+			flags[name] = fmt.Sprint(value)
+		}
+		if len(flags) > 0 {
+			config.NodeFlags[i] = flags
+		}
+		if len(node.AvalancheGoPath) > 0 {
+			config.NodeAvalancheGoPaths[i] = node.AvalancheGoPath
+		}
+		if node.Byzantine != nil {
+			if config.Behaviors == nil {
+				config.Behaviors = make(map[int]manager.Behavior)
+			}
+			config.Behaviors[i] = node.Byzantine.behavior()
+		}
+	}
+	for _, subnet := range s.Subnets {
+		for _, blockchain := range subnet.Blockchains {
+			if config.VMs == nil {
+				config.VMs = make(map[string]string)
+			}
+			config.VMs[blockchain.VMID] = blockchain.VM
+		}
+	}
+	if s.Accounts != nil {
+		config.Accounts = s.Accounts.Count
+		config.Mnemonic = s.Accounts.Mnemonic
+		config.AccountBalance = s.Accounts.Balance * units.Avax
+	}
+	return config
+}
+
+func (b *Byzantine) behavior() manager.Behavior {
+	return manager.Behavior{
+		Unresponsive: b.Unresponsive,
+		Delay:        b.Delay,
+		MuteSubnets:  b.MuteSubnets,
+	}
+}
+
+// ChaosConfig returns the config of the chaos run of the network, if any
+func (s *Spec) ChaosConfig() (*chaos.Config, error) {
+	if s.Chaos == nil {
+		return nil, nil
+	}
+	config := &chaos.Config{
+		Seed:     time.Now().UnixNano(),
+		Interval: s.Chaos.Interval,
+		Downtime: s.Chaos.Downtime,
+		Quorum:   s.Chaos.Quorum,
+		Duration: s.Chaos.Duration,
+	}
+	if s.Chaos.Seed != nil {
+		config.Seed = *s.Chaos.Seed
+	}
+	if len(s.Chaos.Actions) > 0 {
+		actions, err := chaos.ParseActions(strings.Join(s.Chaos.Actions, ","))
+		if err != nil {
+			return nil, err
+		}
+		config.Actions = actions
+	}
+	return config, config.Verify()
+}
+
+// RunnerSubnets returns the subnets to create on the network of [nodeIDs]
+func (s *Spec) RunnerSubnets(nodeIDs []string) ([]runner.Subnet, error) {
+	subnets := make([]runner.Subnet, len(s.Subnets))
+	for i, subnet := range s.Subnets {
+		var validators []runner.Validator
+		for _, validator := range subnet.Validators {
+			index, err := s.nodeIndex(validator.Node)
+			if err != nil {
+				return nil, err
+			}
+			weight := validator.Weight
+			if weight == 0 {
+				weight = runner.DefaultValidatorWeight
+			}
+			validators = append(validators, runner.Validator{
+				NodeID: nodeIDs[index],
+				Weight: weight,
+			})
+		}
+		if len(validators) == 0 {
+			validators = runner.DefaultSubnet(nodeIDs, nil).Validators
+		}
+
+		blockchains := make([]runner.Blockchain, len(subnet.Blockchains))
+		for j, blockchain := range subnet.Blockchains {
+			genesis, err := ioutil.ReadFile(blockchain.Genesis)
+			if err != nil {
+				return nil, fmt.Errorf("could not read genesis file (%s): %w", blockchain.Genesis, err)
+			}
+			blockchains[j] = runner.Blockchain{
+				Name:    blockchain.Name,
+				VMID:    blockchain.VMID,
+				Genesis: genesis,
+			}
+		}
+		subnets[i] = runner.Subnet{
+			Name:        subnet.Name,
+			Validators:  validators,
+			Blockchains: blockchains,
+		}
+	}
+	return subnets, nil
+}
@@ -0,0 +1,130 @@
+package spec
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/ava-sim/constants"
+	"github.com/ava-labs/ava-sim/utils"
+)
+
+// writeSpec writes [content] to a spec file in [dir], along with the VM
+// binary and genesis files it may refer to, and returns its path
+func writeSpec(t *testing.T, dir string, content string) string {
+	for _, file := range []string{"vm", "genesis.json"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "spec.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSpec(t, dir, strings.ReplaceAll(`
+numNodes: 3
+nodes:
+  node2:
+    avalanchegoPath: bin/avalanchego
+    byzantine:
+      delay: 1s
+subnets:
+  - name: evm
+    validators:
+      - node: node1
+        weight: 30
+    blockchains:
+      - name: a
+        vm: vm
+        genesis: genesis.json
+      - name: b
+        vm: DIR/vm
+        genesis: genesis.json
+        vmName: subnetevm
+chaos:
+  seed: 7
+  actions: [restart, kill]
+accounts:
+  count: 2
+`, "DIR", dir))
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NumNodes != 3 {
+		t.Fatalf("expected 3 nodes but got %d", s.NumNodes)
+	}
+	if p := s.Nodes["node2"].AvalancheGoPath; p != filepath.Join(dir, "bin/avalanchego") {
+		t.Fatalf("expected the avalanchego path relative to the spec but got %s", p)
+	}
+	blockchains := s.Subnets[0].Blockchains
+	if blockchains[0].VM != filepath.Join(dir, "vm") || blockchains[0].Genesis != filepath.Join(dir, "genesis.json") {
+		t.Fatalf("expected paths relative to the spec but got %s and %s", blockchains[0].VM, blockchains[0].Genesis)
+	}
+	if blockchains[0].VMID != constants.VMID {
+		t.Fatalf("expected the default VM ID but got %s", blockchains[0].VMID)
+	}
+	if blockchains[1].VM != filepath.Join(dir, "vm") {
+		t.Fatalf("expected the absolute VM path to be kept but got %s", blockchains[1].VM)
+	}
+	if vmID, _ := utils.VMID("subnetevm"); blockchains[1].VMID != vmID {
+		t.Fatalf("expected the VM ID of subnetevm but got %s", blockchains[1].VMID)
+	}
+	config, err := s.ChaosConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Seed != 7 || len(config.Actions) != 2 {
+		t.Fatalf("unexpected chaos config %+v", config)
+	}
+
+	// The number of nodes defaults to constants.DefaultNumNodes
+	if s, err = Load(writeSpec(t, t.TempDir(), "faults: true\n")); err != nil {
+		t.Fatal(err)
+	}
+	if s.NumNodes != constants.DefaultNumNodes {
+		t.Fatalf("expected %d nodes but got %d", constants.DefaultNumNodes, s.NumNodes)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{"unknown field", "numNode: 3", "could not parse spec"},
+		{"negative numNodes", "numNodes: -1", "invalid numNodes -1"},
+		{"unknown node", "numNodes: 2\nnodes:\n  node3: {}", `unknown node "node3"`},
+		{"misnamed node", "nodes:\n  n1: {}", `unknown node "n1"`},
+		{"invalid byzantine delay", "nodes:\n  node1:\n    byzantine:\n      delay: -1s", "invalid byzantine behavior of node1"},
+		{"subnet without name", "subnets:\n  - validators: [{node: node1}]", "subnet 0 is missing a name"},
+		{"duplicate subnet", "subnets:\n  - name: a\n  - name: a", "duplicate subnet a"},
+		{"unknown validator", "subnets:\n  - name: a\n    validators: [{node: node9}]", `subnet a: unknown node "node9"`},
+		{"blockchain without name", "subnets:\n  - name: a\n    blockchains: [{vm: vm, genesis: genesis.json}]", "subnet a has a blockchain without a name"},
+		{"missing VM", "subnets:\n  - name: a\n    blockchains: [{name: b, vm: missing, genesis: genesis.json}]", "blockchain b"},
+		{"missing genesis", "subnets:\n  - name: a\n    blockchains: [{name: b, vm: vm}]", "blockchain b"},
+		{"vmID and vmName", "subnets:\n  - name: a\n    blockchains: [{name: b, vm: vm, genesis: genesis.json, vmID: x, vmName: y}]", "mutually exclusive"},
+		{"invalid vmID", "subnets:\n  - name: a\n    blockchains: [{name: b, vm: vm, genesis: genesis.json, vmID: x}]", "blockchain b: invalid vmID"},
+		{"long vmName", "subnets:\n  - name: a\n    blockchains: [{name: b, vm: vm, genesis: genesis.json, vmName: " + strings.Repeat("v", 33) + "}]", "longer than 32 bytes"},
+		{"VM with different binaries", "subnets:\n  - name: a\n    blockchains:\n      - {name: b, vm: vm, genesis: genesis.json}\n      - {name: c, vm: genesis.json, genesis: genesis.json}", "is used with different binaries"},
+		{"short chaos interval", "chaos:\n  interval: 10ms", "invalid chaos interval"},
+		{"negative chaos quorum", "chaos:\n  quorum: -1", "invalid chaos quorum"},
+		{"unknown chaos action", "chaos:\n  actions: [explode]", `unknown chaos action "explode"`},
+		{"negative accounts", "accounts:\n  count: -1", "invalid number of accounts -1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load(writeSpec(t, t.TempDir(), test.spec))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q but got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"syscall"
 
 	"github.com/ava-labs/ava-sim/constants"
 
@@ -54,3 +56,42 @@ func LoadNodeID(stakeCert []byte) (string, error) {
 
 	return id.PrefixedString(avalancheContants.NodeIDPrefix), nil
 }
+
+// ProcessRunning returns true if a process with [pid] exists
+func ProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// VMID returns the ID of the VM named [name], which is the name zero-padded
+// to 32 bytes (the same way avalanchego derives the IDs of its own VMs)
+func VMID(name string) (string, error) {
+	if len(name) > 32 {
+		return "", fmt.Errorf("VM name %q is longer than 32 bytes", name)
+	}
+	var id ids.ID
+	copy(id[:], name)
+	return id.String(), nil
+}
+
+// FreePorts returns [n] distinct ports that are free on localhost
+func FreePorts(n int) ([]int, error) {
+	ports := make([]int, n)
+	// Keep all listeners open until the end so the same port is not
+	// returned twice
+	for i := range ports {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("could not find a free port: %w", err)
+		}
+		defer l.Close()
+		ports[i] = l.Addr().(*net.TCPAddr).Port
+	}
+	return ports, nil
+}